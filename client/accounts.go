@@ -0,0 +1,91 @@
+package client
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/nerdgarten/mock-payment-service/types"
+)
+
+// GetAccount calls GET /accounts/{type}.
+func (c *Client) GetAccount(ctx context.Context, customerID string, paymentType types.PaymentType) (*types.Account, error) {
+	var resp types.Account
+	query := url.Values{"customer_id": {customerID}}
+	if err := c.get(ctx, "/accounts/"+url.PathEscape(string(paymentType)), query, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Deposit calls POST /deposit.
+func (c *Client) Deposit(ctx context.Context, req types.DepositRequest) (*types.DepositResponse, error) {
+	var resp types.DepositResponse
+	if err := c.post(ctx, "/deposit", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Withdraw calls POST /withdraw.
+func (c *Client) Withdraw(ctx context.Context, req types.WithdrawRequest) (*types.WithdrawResponse, error) {
+	var resp types.WithdrawResponse
+	if err := c.post(ctx, "/withdraw", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Refund calls POST /refund.
+func (c *Client) Refund(ctx context.Context, req types.RefundRequest) (*types.RefundResponse, error) {
+	var resp types.RefundResponse
+	if err := c.post(ctx, "/refund", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// ProcessPayment calls POST /process-payment.
+func (c *Client) ProcessPayment(ctx context.Context, req types.ProcessPaymentRequest) (*types.ProcessPaymentResponse, error) {
+	var resp types.ProcessPaymentResponse
+	if err := c.post(ctx, "/process-payment", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// GetTransaction calls GET /transactions/{id}.
+func (c *Client) GetTransaction(ctx context.Context, id string) (*types.Transaction, error) {
+	var resp types.RetrieveTransactionResponse
+	if err := c.get(ctx, "/transactions/"+url.PathEscape(id), nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.Transaction, nil
+}
+
+// ListTransactionsParams filters GET /accounts/{type}/transactions. Zero
+// fields are not sent; the created bounds are inclusive Unix timestamps.
+type ListTransactionsParams struct {
+	ListParams
+	CustomerID string
+	Kind       types.TransactionKind
+	CreatedGTE int64
+	CreatedLTE int64
+}
+
+// ListTransactions calls GET /accounts/{type}/transactions.
+func (c *Client) ListTransactions(ctx context.Context, paymentType types.PaymentType, params ListTransactionsParams) (*types.List[types.Transaction], error) {
+	query := url.Values{}
+	params.encode(query)
+	if params.CustomerID != "" {
+		query.Set("customer_id", params.CustomerID)
+	}
+	if params.Kind != "" {
+		query.Set("kind", string(params.Kind))
+	}
+	encodeCreated(query, params.CreatedGTE, params.CreatedLTE)
+	var resp types.List[types.Transaction]
+	if err := c.get(ctx, "/accounts/"+url.PathEscape(string(paymentType))+"/transactions", query, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
@@ -0,0 +1,106 @@
+package client
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/nerdgarten/mock-payment-service/types"
+)
+
+// AdminReset calls POST /admin/reset, restoring the seeded mock datasets.
+func (c *Client) AdminReset(ctx context.Context) (*types.Snapshot, error) {
+	var resp types.Snapshot
+	if err := c.post(ctx, "/admin/reset", struct{}{}, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// AdminSnapshot calls GET /admin/snapshot.
+func (c *Client) AdminSnapshot(ctx context.Context) (*types.Snapshot, error) {
+	var resp types.Snapshot
+	if err := c.get(ctx, "/admin/snapshot", nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// AdminRestore calls POST /admin/restore, replacing the service state with
+// snapshot.
+func (c *Client) AdminRestore(ctx context.Context, snapshot *types.Snapshot) (*types.Snapshot, error) {
+	var resp types.Snapshot
+	if err := c.post(ctx, "/admin/restore", snapshot, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// CreateTenant calls POST /admin/tenants. It requires a key of the default
+// tenant.
+func (c *Client) CreateTenant(ctx context.Context, req types.CreateTenantRequest) (*types.Tenant, error) {
+	var resp types.Tenant
+	if err := c.post(ctx, "/admin/tenants", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// ListTenants calls GET /admin/tenants.
+func (c *Client) ListTenants(ctx context.Context) ([]types.Tenant, error) {
+	var resp types.Tenants
+	if err := c.get(ctx, "/admin/tenants", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+// GetTenant calls GET /admin/tenants/{id}.
+func (c *Client) GetTenant(ctx context.Context, id string) (*types.Tenant, error) {
+	var resp types.Tenant
+	if err := c.get(ctx, "/admin/tenants/"+url.PathEscape(id), nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// ResetTenant calls POST /admin/tenants/{id}/reset, restoring the tenant's
+// fixtures.
+func (c *Client) ResetTenant(ctx context.Context, id string) (*types.Snapshot, error) {
+	var resp types.Snapshot
+	if err := c.post(ctx, "/admin/tenants/"+url.PathEscape(id)+"/reset", struct{}{}, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// ListFaultRules calls GET /admin/faults.
+func (c *Client) ListFaultRules(ctx context.Context) ([]types.FaultRule, error) {
+	var resp types.FaultRules
+	if err := c.get(ctx, "/admin/faults", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+// AddFaultRule calls POST /admin/faults.
+func (c *Client) AddFaultRule(ctx context.Context, rule types.FaultRule) (*types.FaultRule, error) {
+	var resp types.FaultRule
+	if err := c.post(ctx, "/admin/faults", rule, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// DeleteFaultRule calls DELETE /admin/faults/{id}.
+func (c *Client) DeleteFaultRule(ctx context.Context, id string) (*types.DeletedObject, error) {
+	var resp types.DeletedObject
+	if err := c.delete(ctx, "/admin/faults/"+url.PathEscape(id), &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// ClearFaultRules calls DELETE /admin/faults, removing every fault rule.
+func (c *Client) ClearFaultRules(ctx context.Context) error {
+	return c.delete(ctx, "/admin/faults", nil)
+}
@@ -0,0 +1,43 @@
+package client
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/nerdgarten/mock-payment-service/types"
+)
+
+// GetCharge calls GET /charges/{id}.
+func (c *Client) GetCharge(ctx context.Context, id string) (*types.Charge, error) {
+	var resp types.RetrieveChargeResponse
+	if err := c.get(ctx, "/charges/"+url.PathEscape(id), nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.Charge, nil
+}
+
+// ListChargesParams filters GET /charges. Zero fields are not sent; the
+// created bounds are inclusive Unix timestamps.
+type ListChargesParams struct {
+	ListParams
+	Customer      string
+	PaymentIntent string
+	Status        string
+	CreatedGTE    int64
+	CreatedLTE    int64
+}
+
+// ListCharges calls GET /charges.
+func (c *Client) ListCharges(ctx context.Context, params ListChargesParams) (*types.List[types.Charge], error) {
+	query := url.Values{}
+	params.encode(query)
+	setIfNotEmpty(query, "customer", params.Customer)
+	setIfNotEmpty(query, "payment_intent", params.PaymentIntent)
+	setIfNotEmpty(query, "status", params.Status)
+	encodeCreated(query, params.CreatedGTE, params.CreatedLTE)
+	var resp types.List[types.Charge]
+	if err := c.get(ctx, "/charges", query, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
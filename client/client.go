@@ -1,107 +1,147 @@
-package main
+// Package client is a Go SDK for the mock payment service REST API.
+package client
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
-	"os"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/nerdgarten/mock-payment-service/types"
 )
 
-func main() {
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "50051"
-	}
-	baseURL := fmt.Sprintf("http://localhost:%s", port)
-	client := &http.Client{Timeout: 5 * time.Second}
-
-	// Example 1: Create Customer
-	createCustomerReq := types.CreateCustomerRequest{Name: "Ruff", Email: "ruff@example.com"}
-	var createCustomerResp types.CreateCustomerResponse
-	if err := postJSON(client, baseURL+"/customers", createCustomerReq, &createCustomerResp); err != nil {
-		log.Printf("CreateCustomer failed: %v", err)
-	} else {
-		log.Printf("Created customer: %s (%s)", createCustomerResp.Customer.Name, createCustomerResp.Customer.ID)
-	}
-
-	// Example 2: Retrieve Customer
-	var retrieveCustomerResp types.RetrieveCustomerResponse
-	if err := getJSON(client, baseURL+"/customers/cus_mock_12345", &retrieveCustomerResp); err != nil {
-		log.Printf("RetrieveCustomer failed: %v", err)
-	} else {
-		log.Printf("Retrieved customer: %s (%s)", retrieveCustomerResp.Customer.Name, retrieveCustomerResp.Customer.Email)
-	}
-
-	// Example 3: Create Payment Intent
-	createIntentReq := types.CreatePaymentIntentRequest{
-		Amount:        1200,
-		Currency:      "thb",
-		PaymentMethod: "pm_mock_visa",
-		Description:   "Food delivery payment",
-	}
-	var createIntentResp types.CreatePaymentIntentResponse
-	if err := postJSON(client, baseURL+"/payment-intents", createIntentReq, &createIntentResp); err != nil {
-		log.Printf("CreatePaymentIntent failed: %v", err)
-	} else {
-		log.Printf("Created payment intent: %s, amount: %d %s", createIntentResp.PaymentIntent.ID, createIntentResp.PaymentIntent.Amount, createIntentResp.PaymentIntent.Currency)
-	}
-
-	// Example 4: Confirm Payment Intent
-	confirmIntentReq := types.ConfirmPaymentIntentRequest{ID: "pi_mock_98765"}
-	var confirmIntentResp types.ConfirmPaymentIntentResponse
-	if err := postJSON(client, baseURL+"/payment-intents/confirm", confirmIntentReq, &confirmIntentResp); err != nil {
-		log.Printf("ConfirmPaymentIntent failed: %v", err)
-	} else {
-		log.Printf("Confirmed payment intent: %s, status: %s", confirmIntentResp.PaymentIntent.ID, confirmIntentResp.PaymentIntent.Status)
-		if len(confirmIntentResp.Charges.Data) > 0 {
-			log.Printf("Charge: %s, amount: %d", confirmIntentResp.Charges.Data[0].ID, confirmIntentResp.Charges.Data[0].Amount)
-		}
+// DefaultTimeout bounds every request made by a Client created with New.
+const DefaultTimeout = 10 * time.Second
+
+// Client calls the mock payment service. The zero value is not usable; create
+// clients with New.
+type Client struct {
+	// BaseURL is the service root, e.g. "http://localhost:50051".
+	BaseURL string
+	// APIKey, when set, is sent as a bearer token.
+	APIKey string
+	// HTTPClient performs the requests; its Timeout bounds each call.
+	HTTPClient *http.Client
+}
+
+// New creates a Client for the service at baseURL with DefaultTimeout.
+func New(baseURL string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		HTTPClient: &http.Client{Timeout: DefaultTimeout},
 	}
+}
 
-	// Example 5: Create Refund
-	createRefundReq := types.CreateRefundRequest{PaymentIntent: "pi_mock_98765", Amount: 600}
-	var createRefundResp types.CreateRefundResponse
-	if err := postJSON(client, baseURL+"/refunds", createRefundReq, &createRefundResp); err != nil {
-		log.Printf("CreateRefund failed: %v", err)
-	} else {
-		log.Printf("Created refund: %s, amount: %d %s", createRefundResp.Refund.ID, createRefundResp.Refund.Amount, createRefundResp.Refund.Currency)
+// APIError is returned when the service responds with a 4xx or 5xx status.
+// It is decoded from both the error envelope and the legacy error body.
+type APIError struct {
+	StatusCode    int
+	Type          types.ErrorType
+	Code          string
+	DeclineCode   string
+	Param         string
+	Message       string
+	RequestID     string
+	Charge        string
+	PaymentIntent *types.PaymentIntent
+}
+
+func (e *APIError) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("request failed (%d %s): %s", e.StatusCode, e.Code, e.Message)
 	}
+	return fmt.Sprintf("request failed (%d): %s", e.StatusCode, e.Message)
+}
+
+type idempotencyKeyContextKey struct{}
 
-	// Example 6: Test Webhook
-	testWebhookReq := types.TestWebhookRequest{
-		Type: "payment_intent.succeeded",
-		Data: `{"object": {"id": "pi_mock_98765", "amount": 1200, "currency": "thb"}}`,
+// WithIdempotencyKey returns a context that makes the POST request it is used
+// for carry key in the Idempotency-Key header.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyContextKey{}, key)
+}
+
+// ListParams selects a page of a list endpoint.
+type ListParams struct {
+	Limit         int
+	StartingAfter string
+	EndingBefore  string
+}
+
+func (p ListParams) encode(query url.Values) {
+	if p.Limit > 0 {
+		query.Set("limit", strconv.Itoa(p.Limit))
+	}
+	if p.StartingAfter != "" {
+		query.Set("starting_after", p.StartingAfter)
 	}
-	var testWebhookResp types.TestWebhookResponse
-	if err := postJSON(client, baseURL+"/webhooks/test", testWebhookReq, &testWebhookResp); err != nil {
-		log.Printf("TestWebhook failed: %v", err)
-	} else {
-		log.Printf("Webhook test received: %t", testWebhookResp.Received)
+	if p.EndingBefore != "" {
+		query.Set("ending_before", p.EndingBefore)
 	}
 }
 
-func postJSON(client *http.Client, url string, payload any, out any) error {
-	body, err := json.Marshal(payload)
-	if err != nil {
-		return fmt.Errorf("marshal request: %w", err)
+// encodeCreated sets the inclusive created[gte] and created[lte] filters;
+// zero bounds are not sent.
+func encodeCreated(query url.Values, gte, lte int64) {
+	if gte != 0 {
+		query.Set("created[gte]", strconv.FormatInt(gte, 10))
 	}
-	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
-	if err != nil {
-		return fmt.Errorf("perform request: %w", err)
+	if lte != 0 {
+		query.Set("created[lte]", strconv.FormatInt(lte, 10))
 	}
-	defer resp.Body.Close()
-	return decodeResponse(resp, out)
 }
 
-func getJSON(client *http.Client, url string, out any) error {
-	resp, err := client.Get(url)
+// setIfNotEmpty sets key in query unless value is empty.
+func setIfNotEmpty(query url.Values, key, value string) {
+	if value != "" {
+		query.Set(key, value)
+	}
+}
+
+func (c *Client) get(ctx context.Context, path string, query url.Values, out any) error {
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	return c.do(ctx, http.MethodGet, path, nil, out)
+}
+
+func (c *Client) post(ctx context.Context, path string, payload, out any) error {
+	return c.do(ctx, http.MethodPost, path, payload, out)
+}
+
+func (c *Client) delete(ctx context.Context, path string, out any) error {
+	return c.do(ctx, http.MethodDelete, path, nil, out)
+}
+
+func (c *Client) do(ctx context.Context, method, path string, payload, out any) error {
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("marshal request: %w", err)
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, body)
+	if err != nil {
+		return fmt.Errorf("build request: %w", err)
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.APIKey)
+	}
+	if key, ok := ctx.Value(idempotencyKeyContextKey{}).(string); ok && method == http.MethodPost {
+		req.Header.Set("Idempotency-Key", key)
+	}
+	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("perform request: %w", err)
 	}
@@ -112,11 +152,29 @@ func getJSON(client *http.Client, url string, out any) error {
 func decodeResponse(resp *http.Response, out any) error {
 	if resp.StatusCode >= http.StatusBadRequest {
 		data, _ := io.ReadAll(resp.Body)
-		var apiErr types.ErrorResponse
-		if err := json.Unmarshal(data, &apiErr); err == nil && apiErr.Error != "" {
-			return fmt.Errorf("request failed (%d): %s", resp.StatusCode, apiErr.Error)
+		apiErr := &APIError{StatusCode: resp.StatusCode, RequestID: resp.Header.Get("Request-Id")}
+		var envelope types.ErrorEnvelope
+		var legacy types.ErrorResponse
+		switch {
+		case json.Unmarshal(data, &envelope) == nil && envelope.Error.Message != "":
+			body := envelope.Error
+			apiErr.Type = body.Type
+			apiErr.Code = body.Code
+			apiErr.DeclineCode = body.DeclineCode
+			apiErr.Param = body.Param
+			apiErr.Message = body.Message
+			apiErr.Charge = body.Charge
+			apiErr.PaymentIntent = body.PaymentIntent
+		case json.Unmarshal(data, &legacy) == nil && legacy.Error != "":
+			apiErr.Code = legacy.Code
+			apiErr.DeclineCode = legacy.DeclineCode
+			apiErr.Param = legacy.Param
+			apiErr.Message = legacy.Error
+			apiErr.PaymentIntent = legacy.PaymentIntent
+		default:
+			apiErr.Message = strings.TrimSpace(string(data))
 		}
-		return fmt.Errorf("request failed (%d): %s", resp.StatusCode, strings.TrimSpace(string(data)))
+		return apiErr
 	}
 	if out == nil {
 		return nil
@@ -0,0 +1,117 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nerdgarten/mock-payment-service/types"
+)
+
+// stub serves handler and returns a Client for it.
+func stub(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+	ts := httptest.NewServer(handler)
+	t.Cleanup(ts.Close)
+	c := New(ts.URL + "/")
+	c.APIKey = "sk_test_123"
+	return c
+}
+
+func TestClientRequests(t *testing.T) {
+	var got *http.Request
+	var body string
+	c := stub(t, func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		got, body = r, string(raw)
+		switch r.URL.Path {
+		case "/charges":
+			io.WriteString(w, `{"object":"list","data":[{"id":"ch_1"}],"has_more":true,"url":"/charges"}`)
+		default:
+			io.WriteString(w, `{"payment_intent":{"id":"pi_1","status":"requires_confirmation"}}`)
+		}
+	})
+
+	ctx := WithIdempotencyKey(context.Background(), "key-1")
+	intent, err := c.CreatePaymentIntent(ctx, types.CreatePaymentIntentRequest{Amount: 1200, Currency: "thb"})
+	if err != nil {
+		t.Fatalf("CreatePaymentIntent: %v", err)
+	}
+	if intent.ID != "pi_1" || intent.Status != types.PaymentIntentStatusRequiresConfirmation {
+		t.Errorf("intent = %+v", intent)
+	}
+	if got.Method != http.MethodPost || got.URL.Path != "/payment-intents" {
+		t.Errorf("request = %s %s, want POST /payment-intents", got.Method, got.URL.Path)
+	}
+	if got.Header.Get("Authorization") != "Bearer sk_test_123" || got.Header.Get("Idempotency-Key") != "key-1" || got.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("headers = %v", got.Header)
+	}
+	var sent types.CreatePaymentIntentRequest
+	if err := json.Unmarshal([]byte(body), &sent); err != nil || sent.Amount != 1200 || sent.Currency != "thb" {
+		t.Errorf("body = %s", body)
+	}
+
+	// Idempotency keys are only sent with POST requests.
+	list, err := c.ListCharges(ctx, ListChargesParams{ListParams: ListParams{Limit: 2, StartingAfter: "ch_0"}, Customer: "cus_1", CreatedGTE: 100})
+	if err != nil {
+		t.Fatalf("ListCharges: %v", err)
+	}
+	if len(list.Data) != 1 || list.Data[0].ID != "ch_1" || !list.HasMore {
+		t.Errorf("list = %+v", list)
+	}
+	if got.Header.Get("Idempotency-Key") != "" {
+		t.Errorf("GET sent Idempotency-Key %q", got.Header.Get("Idempotency-Key"))
+	}
+	if want := "created%5Bgte%5D=100&customer=cus_1&limit=2&starting_after=ch_0"; got.URL.RawQuery != want {
+		t.Errorf("query = %s, want %s", got.URL.RawQuery, want)
+	}
+}
+
+func TestClientDecodesErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   APIError
+	}{
+		{
+			"envelope",
+			http.StatusPaymentRequired,
+			`{"error":{"type":"card_error","code":"card_declined","decline_code":"generic_decline","message":"Your card was declined.","charge":"ch_1"}}`,
+			APIError{StatusCode: 402, Type: types.ErrorTypeCard, Code: "card_declined", DeclineCode: "generic_decline", Message: "Your card was declined.", Charge: "ch_1", RequestID: "req_1"},
+		},
+		{
+			"legacy",
+			http.StatusNotFound,
+			`{"error":"Customer not found","code":"resource_missing","param":"customer_id"}`,
+			APIError{StatusCode: 404, Code: "resource_missing", Param: "customer_id", Message: "Customer not found", RequestID: "req_1"},
+		},
+		{
+			"plain text",
+			http.StatusBadGateway,
+			"bad gateway\n",
+			APIError{StatusCode: 502, Message: "bad gateway", RequestID: "req_1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := stub(t, func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Request-Id", "req_1")
+				w.WriteHeader(tt.status)
+				io.WriteString(w, tt.body)
+			})
+			_, err := c.GetCustomer(context.Background(), "cus_1")
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("error = %v, want *APIError", err)
+			}
+			if *apiErr != tt.want {
+				t.Errorf("error = %+v, want %+v", *apiErr, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package client
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+
+	"github.com/nerdgarten/mock-payment-service/types"
+)
+
+// CreateCustomer calls POST /customers.
+func (c *Client) CreateCustomer(ctx context.Context, req types.CreateCustomerRequest) (*types.Customer, error) {
+	var resp types.CreateCustomerResponse
+	if err := c.post(ctx, "/customers", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.Customer, nil
+}
+
+// GetCustomer calls GET /customers/{id}.
+func (c *Client) GetCustomer(ctx context.Context, id string) (*types.Customer, error) {
+	var resp types.RetrieveCustomerResponse
+	if err := c.get(ctx, "/customers/"+url.PathEscape(id), nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.Customer, nil
+}
+
+// ListCustomerAccounts calls GET /customers/{id}/accounts.
+func (c *Client) ListCustomerAccounts(ctx context.Context, customerID string) ([]types.Account, error) {
+	var resp types.Accounts
+	if err := c.get(ctx, "/customers/"+url.PathEscape(customerID)+"/accounts", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+// ListCustomersParams filters GET /customers. Zero fields are not sent; the
+// created bounds are inclusive Unix timestamps.
+type ListCustomersParams struct {
+	ListParams
+	Email      string
+	CreatedGTE int64
+	CreatedLTE int64
+}
+
+// ListCustomers calls GET /customers.
+func (c *Client) ListCustomers(ctx context.Context, params ListCustomersParams) (*types.List[types.Customer], error) {
+	query := url.Values{}
+	params.encode(query)
+	setIfNotEmpty(query, "email", params.Email)
+	encodeCreated(query, params.CreatedGTE, params.CreatedLTE)
+	var resp types.List[types.Customer]
+	if err := c.get(ctx, "/customers", query, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// UpdateCustomer calls POST /customers/{id}, changing the fields set in req.
+func (c *Client) UpdateCustomer(ctx context.Context, id string, req types.UpdateCustomerRequest) (*types.Customer, error) {
+	var resp types.UpdateCustomerResponse
+	if err := c.post(ctx, "/customers/"+url.PathEscape(id), req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.Customer, nil
+}
+
+// DeleteCustomer calls DELETE /customers/{id}.
+func (c *Client) DeleteCustomer(ctx context.Context, id string) (*types.DeletedObject, error) {
+	var resp types.DeletedObject
+	if err := c.delete(ctx, "/customers/"+url.PathEscape(id), &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// SearchCustomersParams selects a page of GET /customers/search. Page is the
+// NextPage of the previous result.
+type SearchCustomersParams struct {
+	Query string
+	Limit int
+	Page  string
+}
+
+// SearchCustomers calls GET /customers/search, e.g. with the query
+// "email:'ruff@example.com'".
+func (c *Client) SearchCustomers(ctx context.Context, params SearchCustomersParams) (*types.SearchResult[types.Customer], error) {
+	query := url.Values{"query": {params.Query}}
+	if params.Limit > 0 {
+		query.Set("limit", strconv.Itoa(params.Limit))
+	}
+	setIfNotEmpty(query, "page", params.Page)
+	var resp types.SearchResult[types.Customer]
+	if err := c.get(ctx, "/customers/search", query, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
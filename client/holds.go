@@ -0,0 +1,70 @@
+package client
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/nerdgarten/mock-payment-service/types"
+)
+
+// CreateHold calls POST /holds.
+func (c *Client) CreateHold(ctx context.Context, req types.CreateHoldRequest) (*types.CreateHoldResponse, error) {
+	var resp types.CreateHoldResponse
+	if err := c.post(ctx, "/holds", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// GetHold calls GET /holds/{id}.
+func (c *Client) GetHold(ctx context.Context, id string) (*types.Hold, error) {
+	var resp types.RetrieveHoldResponse
+	if err := c.get(ctx, "/holds/"+url.PathEscape(id), nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.Hold, nil
+}
+
+// ListHoldsParams filters GET /holds. Zero fields are not sent; the created
+// bounds are inclusive Unix timestamps.
+type ListHoldsParams struct {
+	ListParams
+	CustomerID string
+	Type       types.PaymentType
+	Status     types.HoldStatus
+	CreatedGTE int64
+	CreatedLTE int64
+}
+
+// ListHolds calls GET /holds.
+func (c *Client) ListHolds(ctx context.Context, params ListHoldsParams) (*types.List[types.Hold], error) {
+	query := url.Values{}
+	params.encode(query)
+	setIfNotEmpty(query, "customer_id", params.CustomerID)
+	setIfNotEmpty(query, "type", string(params.Type))
+	setIfNotEmpty(query, "status", string(params.Status))
+	encodeCreated(query, params.CreatedGTE, params.CreatedLTE)
+	var resp types.List[types.Hold]
+	if err := c.get(ctx, "/holds", query, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// CaptureHold calls POST /holds/{id}/capture.
+func (c *Client) CaptureHold(ctx context.Context, id string, req types.CaptureHoldRequest) (*types.CaptureHoldResponse, error) {
+	var resp types.CaptureHoldResponse
+	if err := c.post(ctx, "/holds/"+url.PathEscape(id)+"/capture", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// ReleaseHold calls POST /holds/{id}/release.
+func (c *Client) ReleaseHold(ctx context.Context, id string) (*types.ReleaseHoldResponse, error) {
+	var resp types.ReleaseHoldResponse
+	if err := c.post(ctx, "/holds/"+url.PathEscape(id)+"/release", struct{}{}, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
@@ -0,0 +1,114 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/nerdgarten/mock-payment-service/types"
+)
+
+// CreatePaymentIntent calls POST /payment-intents.
+func (c *Client) CreatePaymentIntent(ctx context.Context, req types.CreatePaymentIntentRequest) (*types.PaymentIntent, error) {
+	var resp types.CreatePaymentIntentResponse
+	if err := c.post(ctx, "/payment-intents", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.PaymentIntent, nil
+}
+
+// ConfirmPaymentIntent calls POST /payment-intents/confirm. A declined
+// payment is reported as an *APIError carrying the updated payment intent.
+func (c *Client) ConfirmPaymentIntent(ctx context.Context, req types.ConfirmPaymentIntentRequest) (*types.ConfirmPaymentIntentResponse, error) {
+	var resp types.ConfirmPaymentIntentResponse
+	if err := c.post(ctx, "/payment-intents/confirm", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// CancelPaymentIntent calls POST /payment-intents/{id}/cancel.
+func (c *Client) CancelPaymentIntent(ctx context.Context, id string, req types.CancelPaymentIntentRequest) (*types.PaymentIntent, error) {
+	var resp types.CancelPaymentIntentResponse
+	if err := c.post(ctx, "/payment-intents/"+url.PathEscape(id)+"/cancel", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.PaymentIntent, nil
+}
+
+// CapturePaymentIntent calls POST /payment-intents/{id}/capture.
+func (c *Client) CapturePaymentIntent(ctx context.Context, id string, req types.CapturePaymentIntentRequest) (*types.CapturePaymentIntentResponse, error) {
+	var resp types.CapturePaymentIntentResponse
+	if err := c.post(ctx, "/payment-intents/"+url.PathEscape(id)+"/capture", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// GetPaymentIntent calls GET /payment-intents/{id}.
+func (c *Client) GetPaymentIntent(ctx context.Context, id string) (*types.PaymentIntent, error) {
+	var resp types.RetrievePaymentIntentResponse
+	if err := c.get(ctx, "/payment-intents/"+url.PathEscape(id), nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.PaymentIntent, nil
+}
+
+// ListPaymentIntentsParams filters GET /payment-intents. Zero fields are not
+// sent; the created bounds are inclusive Unix timestamps.
+type ListPaymentIntentsParams struct {
+	ListParams
+	Customer   string
+	Status     types.PaymentIntentStatus
+	CreatedGTE int64
+	CreatedLTE int64
+}
+
+// ListPaymentIntents calls GET /payment-intents.
+func (c *Client) ListPaymentIntents(ctx context.Context, params ListPaymentIntentsParams) (*types.List[types.PaymentIntent], error) {
+	query := url.Values{}
+	params.encode(query)
+	setIfNotEmpty(query, "customer", params.Customer)
+	setIfNotEmpty(query, "status", string(params.Status))
+	encodeCreated(query, params.CreatedGTE, params.CreatedLTE)
+	var resp types.List[types.PaymentIntent]
+	if err := c.get(ctx, "/payment-intents", query, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// SubmitThreeDSecure answers the 3-D Secure challenge of an intent in
+// requires_action, as the customer would by pressing Complete or Fail on the
+// challenge page. It returns the URL the customer is redirected to, which is
+// empty when the intent has no return URL.
+func (c *Client) SubmitThreeDSecure(ctx context.Context, intent *types.PaymentIntent, complete bool) (string, error) {
+	if intent.NextAction == nil || intent.NextAction.RedirectToURL == nil {
+		return "", fmt.Errorf("payment intent %s has no 3-D Secure challenge", intent.ID)
+	}
+	result := "fail"
+	if complete {
+		result = "complete"
+	}
+	form := url.Values{"result": {result}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, intent.NextAction.RedirectToURL.URL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	httpClient := *c.HTTPClient
+	httpClient.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("perform request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		return "", decodeResponse(resp, nil)
+	}
+	return resp.Header.Get("Location"), nil
+}
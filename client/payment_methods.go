@@ -0,0 +1,68 @@
+package client
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/nerdgarten/mock-payment-service/types"
+)
+
+// CreatePaymentMethod calls POST /payment-methods.
+func (c *Client) CreatePaymentMethod(ctx context.Context, req types.CreatePaymentMethodRequest) (*types.PaymentMethod, error) {
+	var resp types.CreatePaymentMethodResponse
+	if err := c.post(ctx, "/payment-methods", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.PaymentMethod, nil
+}
+
+// GetPaymentMethod calls GET /payment-methods/{id}.
+func (c *Client) GetPaymentMethod(ctx context.Context, id string) (*types.PaymentMethod, error) {
+	var resp types.RetrievePaymentMethodResponse
+	if err := c.get(ctx, "/payment-methods/"+url.PathEscape(id), nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.PaymentMethod, nil
+}
+
+// ListPaymentMethodsParams filters GET /payment-methods. Zero fields are not
+// sent.
+type ListPaymentMethodsParams struct {
+	ListParams
+	Customer    string
+	Type        types.PaymentMethodType
+	Fingerprint string
+}
+
+// ListPaymentMethods calls GET /payment-methods.
+func (c *Client) ListPaymentMethods(ctx context.Context, params ListPaymentMethodsParams) (*types.List[types.PaymentMethod], error) {
+	query := url.Values{}
+	params.encode(query)
+	setIfNotEmpty(query, "customer", params.Customer)
+	setIfNotEmpty(query, "type", string(params.Type))
+	setIfNotEmpty(query, "fingerprint", params.Fingerprint)
+	var resp types.List[types.PaymentMethod]
+	if err := c.get(ctx, "/payment-methods", query, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// AttachPaymentMethod calls POST /payment-methods/{id}/attach.
+func (c *Client) AttachPaymentMethod(ctx context.Context, id, customerID string) (*types.PaymentMethod, error) {
+	var resp types.AttachPaymentMethodResponse
+	req := types.AttachPaymentMethodRequest{Customer: customerID}
+	if err := c.post(ctx, "/payment-methods/"+url.PathEscape(id)+"/attach", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.PaymentMethod, nil
+}
+
+// DetachPaymentMethod calls POST /payment-methods/{id}/detach.
+func (c *Client) DetachPaymentMethod(ctx context.Context, id string) (*types.PaymentMethod, error) {
+	var resp types.DetachPaymentMethodResponse
+	if err := c.post(ctx, "/payment-methods/"+url.PathEscape(id)+"/detach", struct{}{}, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.PaymentMethod, nil
+}
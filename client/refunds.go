@@ -0,0 +1,52 @@
+package client
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/nerdgarten/mock-payment-service/types"
+)
+
+// CreateRefund calls POST /refunds.
+func (c *Client) CreateRefund(ctx context.Context, req types.CreateRefundRequest) (*types.Refund, error) {
+	var resp types.CreateRefundResponse
+	if err := c.post(ctx, "/refunds", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.Refund, nil
+}
+
+// GetRefund calls GET /refunds/{id}.
+func (c *Client) GetRefund(ctx context.Context, id string) (*types.Refund, error) {
+	var resp types.RetrieveRefundResponse
+	if err := c.get(ctx, "/refunds/"+url.PathEscape(id), nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.Refund, nil
+}
+
+// ListRefundsParams filters GET /refunds. Zero fields are not sent; the
+// created bounds are inclusive Unix timestamps.
+type ListRefundsParams struct {
+	ListParams
+	PaymentIntent string
+	Charge        string
+	Status        string
+	CreatedGTE    int64
+	CreatedLTE    int64
+}
+
+// ListRefunds calls GET /refunds.
+func (c *Client) ListRefunds(ctx context.Context, params ListRefundsParams) (*types.List[types.Refund], error) {
+	query := url.Values{}
+	params.encode(query)
+	setIfNotEmpty(query, "payment_intent", params.PaymentIntent)
+	setIfNotEmpty(query, "charge", params.Charge)
+	setIfNotEmpty(query, "status", params.Status)
+	encodeCreated(query, params.CreatedGTE, params.CreatedLTE)
+	var resp types.List[types.Refund]
+	if err := c.get(ctx, "/refunds", query, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
@@ -0,0 +1,53 @@
+package client
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/nerdgarten/mock-payment-service/types"
+)
+
+// TestWebhook calls POST /webhooks/test.
+func (c *Client) TestWebhook(ctx context.Context, req types.TestWebhookRequest) (*types.TestWebhookResponse, error) {
+	var resp types.TestWebhookResponse
+	if err := c.post(ctx, "/webhooks/test", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// CreateWebhookEndpoint calls POST /webhook-endpoints.
+func (c *Client) CreateWebhookEndpoint(ctx context.Context, req types.CreateWebhookEndpointRequest) (*types.WebhookEndpoint, error) {
+	var resp types.WebhookEndpointResponse
+	if err := c.post(ctx, "/webhook-endpoints", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.WebhookEndpoint, nil
+}
+
+// ListWebhookEndpoints calls GET /webhook-endpoints.
+func (c *Client) ListWebhookEndpoints(ctx context.Context) ([]types.WebhookEndpoint, error) {
+	var resp types.WebhookEndpoints
+	if err := c.get(ctx, "/webhook-endpoints", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+// GetWebhookEndpoint calls GET /webhook-endpoints/{id}.
+func (c *Client) GetWebhookEndpoint(ctx context.Context, id string) (*types.WebhookEndpoint, error) {
+	var resp types.WebhookEndpointResponse
+	if err := c.get(ctx, "/webhook-endpoints/"+url.PathEscape(id), nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.WebhookEndpoint, nil
+}
+
+// DeleteWebhookEndpoint calls DELETE /webhook-endpoints/{id}.
+func (c *Client) DeleteWebhookEndpoint(ctx context.Context, id string) (*types.DeletedObject, error) {
+	var resp types.DeletedObject
+	if err := c.delete(ctx, "/webhook-endpoints/"+url.PathEscape(id), &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
@@ -0,0 +1,90 @@
+// Command democlient exercises the mock payment service through the client SDK.
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/nerdgarten/mock-payment-service/client"
+	"github.com/nerdgarten/mock-payment-service/types"
+)
+
+func main() {
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "50051"
+	}
+	c := client.New(fmt.Sprintf("http://localhost:%s", port))
+	c.APIKey = os.Getenv("API_KEY")
+	ctx := context.Background()
+
+	// Example 1: Create Customer
+	customer, err := c.CreateCustomer(ctx, types.CreateCustomerRequest{Name: "Ruff", Email: "ruff@example.com"})
+	if err != nil {
+		log.Printf("CreateCustomer failed: %v", err)
+	} else {
+		log.Printf("Created customer: %s (%s)", customer.Name, customer.ID)
+	}
+
+	// Example 2: Retrieve Customer
+	customer, err = c.GetCustomer(ctx, "cus_mock_12345")
+	if err != nil {
+		log.Printf("RetrieveCustomer failed: %v", err)
+	} else {
+		log.Printf("Retrieved customer: %s (%s)", customer.Name, customer.Email)
+	}
+
+	// Example 3: Create Payment Intent
+	intent, err := c.CreatePaymentIntent(ctx, types.CreatePaymentIntentRequest{
+		Amount:        1200,
+		Currency:      "thb",
+		PaymentMethod: "pm_card_visa",
+		Description:   "Food delivery payment",
+	})
+	if err != nil {
+		log.Printf("CreatePaymentIntent failed: %v", err)
+	} else {
+		log.Printf("Created payment intent: %s, amount: %d %s", intent.ID, intent.Amount, intent.Currency)
+	}
+
+	// Example 4: Confirm Payment Intent
+	confirmed, err := c.ConfirmPaymentIntent(ctx, types.ConfirmPaymentIntentRequest{ID: "pi_mock_98765"})
+	if err != nil {
+		log.Printf("ConfirmPaymentIntent failed: %v", err)
+	} else {
+		log.Printf("Confirmed payment intent: %s, status: %s", confirmed.PaymentIntent.ID, confirmed.PaymentIntent.Status)
+		if len(confirmed.Charges.Data) > 0 {
+			log.Printf("Charge: %s, amount: %d", confirmed.Charges.Data[0].ID, confirmed.Charges.Data[0].Amount)
+		}
+	}
+
+	// Example 5: Create Refund
+	refundAmount := types.Amount(600)
+	refund, err := c.CreateRefund(ctx, types.CreateRefundRequest{PaymentIntent: "pi_mock_98765", Amount: &refundAmount})
+	if err != nil {
+		log.Printf("CreateRefund failed: %v", err)
+	} else {
+		log.Printf("Created refund: %s, amount: %d %s", refund.ID, refund.Amount, refund.Currency)
+	}
+
+	// Example 6: Test Webhook
+	webhook, err := c.TestWebhook(ctx, types.TestWebhookRequest{
+		Type: "payment_intent.succeeded",
+		Data: `{"object": {"id": "pi_mock_98765", "amount": 1200, "currency": "thb"}}`,
+	})
+	if err != nil {
+		log.Printf("TestWebhook failed: %v", err)
+	} else {
+		log.Printf("Webhook test received: %t", webhook.Received)
+	}
+
+	// Example 7: Deposit into a wallet account
+	deposit, err := c.Deposit(ctx, types.DepositRequest{CustomerID: "cus_mock_12345", Type: types.PaymentTypeMobileBanking, Amount: 10000})
+	if err != nil {
+		log.Printf("Deposit failed: %v", err)
+	} else {
+		log.Printf("Deposit success: %t, balance: %d", deposit.Success, deposit.Account.Balance)
+	}
+}
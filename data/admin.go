@@ -0,0 +1,251 @@
+package data
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/nerdgarten/mock-payment-service/types"
+)
+
+// TakeSnapshot exports the full state of store.
+func TakeSnapshot(store Store) *types.Snapshot {
+	snapshot := &types.Snapshot{
+		Object:         "snapshot",
+		Customers:      []types.Customer{},
+		PaymentMethods: []types.PaymentMethod{},
+		PaymentIntents: []types.PaymentIntent{},
+		Charges:        []types.Charge{},
+		Refunds:        []types.Refund{},
+		Holds:          []types.Hold{},
+		Accounts:       []types.Account{},
+		Transactions:   []types.Transaction{},
+	}
+	_ = store.View(func(tx ReadTx) error {
+		for _, customer := range tx.Customers() {
+			snapshot.Customers = append(snapshot.Customers, *customer)
+			for _, account := range tx.Accounts(customer.ID) {
+				snapshot.Accounts = append(snapshot.Accounts, *account)
+			}
+		}
+		for _, method := range tx.PaymentMethods() {
+			snapshot.PaymentMethods = append(snapshot.PaymentMethods, *clonePaymentMethod(method))
+		}
+		for _, intent := range tx.PaymentIntents() {
+			snapshot.PaymentIntents = append(snapshot.PaymentIntents, *intent)
+		}
+		for _, charge := range tx.Charges() {
+			snapshot.Charges = append(snapshot.Charges, *charge)
+		}
+		for _, refund := range tx.Refunds() {
+			snapshot.Refunds = append(snapshot.Refunds, *refund)
+		}
+		for _, hold := range tx.Holds() {
+			snapshot.Holds = append(snapshot.Holds, *hold)
+		}
+		for _, txn := range tx.Transactions() {
+			snapshot.Transactions = append(snapshot.Transactions, *txn)
+		}
+		return nil
+	})
+	return snapshot
+}
+
+// RestoreSnapshot replaces the state of store with snapshot. The snapshot is
+// validated first; on error the store is left unchanged. Webhook endpoints
+// are kept.
+func RestoreSnapshot(store Store, snapshot *types.Snapshot) error {
+	return restoreSnapshot(store, snapshot, false)
+}
+
+// ResetStore restores the fixtures a store started with, like
+// RestoreSnapshot, and restarts its ID sequence so that a seeded store issues
+// the same IDs as after a restart.
+func ResetStore(store Store, fixtures *types.Snapshot) error {
+	return restoreSnapshot(store, fixtures, true)
+}
+
+func restoreSnapshot(store Store, snapshot *types.Snapshot, resetIDs bool) error {
+	if err := validateSnapshot(snapshot); err != nil {
+		return err
+	}
+	return store.Update(func(tx Tx) error {
+		tx.Clear()
+		if resetIDs {
+			tx.ResetIDs()
+		}
+		for _, customer := range snapshot.Customers {
+			tx.PutCustomer(&customer)
+		}
+		for _, method := range snapshot.PaymentMethods {
+			tx.PutPaymentMethod(clonePaymentMethod(&method))
+		}
+		for _, intent := range snapshot.PaymentIntents {
+			tx.PutPaymentIntent(&intent)
+		}
+		for _, charge := range snapshot.Charges {
+			tx.PutCharge(&charge)
+		}
+		for _, refund := range snapshot.Refunds {
+			tx.PutRefund(&refund)
+		}
+		for _, hold := range snapshot.Holds {
+			tx.PutHold(&hold)
+		}
+		for _, account := range snapshot.Accounts {
+			tx.PutAccount(&account)
+		}
+		for _, txn := range snapshot.Transactions {
+			tx.AppendTransaction(&txn)
+		}
+		return nil
+	})
+}
+
+// validateSnapshot checks that every object has a unique ID and that
+// references between objects resolve within the snapshot.
+func validateSnapshot(snapshot *types.Snapshot) error {
+	customers := make(map[string]bool)
+	emails := make(map[string]string)
+	for i, customer := range snapshot.Customers {
+		if err := checkSnapshotID(customers, customer.ID, "customers", i); err != nil {
+			return err
+		}
+		if customer.Email == "" || customer.Deleted {
+			continue
+		}
+		if err := validateEmail(customer.Email); err != nil {
+			return snapshotError(fmt.Sprintf("customers[%d].email", i), err.Message)
+		}
+		email := strings.ToLower(customer.Email)
+		if other, ok := emails[email]; ok {
+			return snapshotError(fmt.Sprintf("customers[%d].email", i), fmt.Sprintf("email %s is already used by customer %s", customer.Email, other))
+		}
+		emails[email] = customer.ID
+	}
+	methods := make(map[string]bool)
+	owners := make(map[string]string)
+	for i, method := range snapshot.PaymentMethods {
+		if err := checkSnapshotID(methods, method.ID, "payment_methods", i); err != nil {
+			return err
+		}
+		if !slices.Contains(types.PaymentMethodTypes, method.Type) {
+			return snapshotError(fmt.Sprintf("payment_methods[%d].type", i), fmt.Sprintf("unsupported payment method type %q", method.Type))
+		}
+		if method.Customer != "" && !customers[method.Customer] {
+			return snapshotError(fmt.Sprintf("payment_methods[%d].customer", i), "unknown customer "+method.Customer)
+		}
+		owners[method.ID] = method.Customer
+	}
+	for i, customer := range snapshot.Customers {
+		if customer.DefaultPaymentMethod == "" {
+			continue
+		}
+		if !methods[customer.DefaultPaymentMethod] || owners[customer.DefaultPaymentMethod] != customer.ID {
+			return snapshotError(fmt.Sprintf("customers[%d].default_payment_method", i), fmt.Sprintf("payment method %s is not attached to customer %s", customer.DefaultPaymentMethod, customer.ID))
+		}
+	}
+	intents := make(map[string]bool)
+	for i, intent := range snapshot.PaymentIntents {
+		if err := checkSnapshotID(intents, intent.ID, "payment_intents", i); err != nil {
+			return err
+		}
+		if err := (types.Money{Amount: intent.Amount, Currency: intent.Currency}).Validate(); err != nil {
+			return snapshotError(fmt.Sprintf("payment_intents[%d].currency", i), err.Error())
+		}
+		if intent.Customer != "" && !customers[intent.Customer] {
+			return snapshotError(fmt.Sprintf("payment_intents[%d].customer", i), "unknown customer "+intent.Customer)
+		}
+	}
+	charges := make(map[string]bool)
+	for i, charge := range snapshot.Charges {
+		if err := checkSnapshotID(charges, charge.ID, "charges", i); err != nil {
+			return err
+		}
+		if charge.PaymentIntent != "" && !intents[charge.PaymentIntent] {
+			return snapshotError(fmt.Sprintf("charges[%d].payment_intent", i), "unknown payment intent "+charge.PaymentIntent)
+		}
+		if charge.Customer != "" && !customers[charge.Customer] {
+			return snapshotError(fmt.Sprintf("charges[%d].customer", i), "unknown customer "+charge.Customer)
+		}
+	}
+	refunds := make(map[string]bool)
+	for i, refund := range snapshot.Refunds {
+		if err := checkSnapshotID(refunds, refund.ID, "refunds", i); err != nil {
+			return err
+		}
+		if !intents[refund.PaymentIntent] {
+			return snapshotError(fmt.Sprintf("refunds[%d].payment_intent", i), "unknown payment intent "+refund.PaymentIntent)
+		}
+		if refund.Charge != "" && !charges[refund.Charge] {
+			return snapshotError(fmt.Sprintf("refunds[%d].charge", i), "unknown charge "+refund.Charge)
+		}
+	}
+	holds := make(map[string]bool)
+	held := make(map[accountKey]types.Amount)
+	for i, hold := range snapshot.Holds {
+		if err := checkSnapshotID(holds, hold.ID, "holds", i); err != nil {
+			return err
+		}
+		if !customers[hold.CustomerID] {
+			return snapshotError(fmt.Sprintf("holds[%d].customer_id", i), "unknown customer "+hold.CustomerID)
+		}
+		if !slices.Contains(types.HoldStatuses, hold.Status) {
+			return snapshotError(fmt.Sprintf("holds[%d].status", i), fmt.Sprintf("unknown hold status %q", hold.Status))
+		}
+		if hold.Status == types.HoldStatusActive {
+			held[accountKey{hold.CustomerID, hold.Type}] += hold.Amount
+		}
+	}
+	accounts := make(map[accountKey]bool)
+	for i, account := range snapshot.Accounts {
+		if !customers[account.CustomerID] {
+			return snapshotError(fmt.Sprintf("accounts[%d].customer_id", i), "unknown customer "+account.CustomerID)
+		}
+		if !slices.Contains(types.PaymentTypes, account.Type) {
+			return snapshotError(fmt.Sprintf("accounts[%d].type", i), fmt.Sprintf("unsupported payment type %q", account.Type))
+		}
+		key := accountKey{account.CustomerID, account.Type}
+		if accounts[key] {
+			return snapshotError(fmt.Sprintf("accounts[%d]", i), fmt.Sprintf("duplicate %s account for customer %s", account.Type, account.CustomerID))
+		}
+		accounts[key] = true
+		if account.Held != held[key] {
+			return snapshotError(fmt.Sprintf("accounts[%d].held", i), fmt.Sprintf("held amount %d does not match the %d reserved by active holds", account.Held, held[key]))
+		}
+		if err := (types.Money{Amount: account.Balance, Currency: account.Currency}).Validate(); err != nil {
+			return snapshotError(fmt.Sprintf("accounts[%d].currency", i), err.Error())
+		}
+	}
+	for i, hold := range snapshot.Holds {
+		if !accounts[accountKey{hold.CustomerID, hold.Type}] {
+			return snapshotError(fmt.Sprintf("holds[%d].type", i), fmt.Sprintf("customer %s has no %s account", hold.CustomerID, hold.Type))
+		}
+	}
+	transactions := make(map[string]bool)
+	for i, txn := range snapshot.Transactions {
+		if err := checkSnapshotID(transactions, txn.ID, "transactions", i); err != nil {
+			return err
+		}
+		if !customers[txn.CustomerID] {
+			return snapshotError(fmt.Sprintf("transactions[%d].customer_id", i), "unknown customer "+txn.CustomerID)
+		}
+	}
+	return nil
+}
+
+func checkSnapshotID(seen map[string]bool, id, list string, index int) error {
+	param := fmt.Sprintf("%s[%d].id", list, index)
+	if id == "" {
+		return snapshotError(param, "id is required")
+	}
+	if seen[id] {
+		return snapshotError(param, "duplicate id "+id)
+	}
+	seen[id] = true
+	return nil
+}
+
+func snapshotError(param, message string) *Error {
+	return &Error{Kind: ErrorKindInvalid, Code: CodeParameterInvalid, Param: param, Message: param + ": " + message}
+}
@@ -0,0 +1,78 @@
+package data
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/nerdgarten/mock-payment-service/types"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	store := NewMemoryStore()
+	if _, _, err := CreateMockHold(store, "cus_mock_12345", types.PaymentTypeMeowthWallet, types.Money{Amount: 100}, "", ""); err != nil {
+		t.Fatalf("CreateMockHold: %v", err)
+	}
+	if _, err := CreateWebhookEndpoint(store, "http://localhost/hook", []string{"*"}, "whsec_test"); err != nil {
+		t.Fatalf("CreateWebhookEndpoint: %v", err)
+	}
+	snapshot := TakeSnapshot(store)
+
+	if _, err := CreateMockCustomer(store, "Mia", "mia@example.com"); err != nil {
+		t.Fatalf("CreateMockCustomer: %v", err)
+	}
+	if _, err := Deposit(store, "cus_mock_12345", types.PaymentTypeCash, types.Money{Amount: 100}); err != nil {
+		t.Fatalf("Deposit: %v", err)
+	}
+	if err := RestoreSnapshot(store, snapshot); err != nil {
+		t.Fatalf("RestoreSnapshot: %v", err)
+	}
+	if got := TakeSnapshot(store); !reflect.DeepEqual(got, snapshot) {
+		t.Errorf("restored snapshot = %+v, want %+v", got, snapshot)
+	}
+	if endpoints := ListWebhookEndpoints(store); len(endpoints) != 1 {
+		t.Errorf("restore left %d webhook endpoints, want 1", len(endpoints))
+	}
+
+	// A restored store can be restored into an empty one.
+	empty := NewEmptyMemoryStore()
+	if err := RestoreSnapshot(empty, snapshot); err != nil {
+		t.Fatalf("RestoreSnapshot into an empty store: %v", err)
+	}
+	if got := TakeSnapshot(empty); !reflect.DeepEqual(got, snapshot) {
+		t.Errorf("snapshot of the empty store = %+v, want %+v", got, snapshot)
+	}
+}
+
+func TestRestoreSnapshotRejectsInvalidSnapshots(t *testing.T) {
+	customer := types.Customer{ID: "cus_1", Object: "customer", Email: "a@example.com"}
+	account := types.Account{CustomerID: "cus_1", Type: types.PaymentTypeCash, Currency: "thb", Balance: 100}
+	tests := []struct {
+		name     string
+		snapshot types.Snapshot
+		param    string
+	}{
+		{"missing id", types.Snapshot{Customers: []types.Customer{{Object: "customer"}}}, "customers[0].id"},
+		{"duplicate id", types.Snapshot{Customers: []types.Customer{customer, {ID: "cus_1"}}}, "customers[1].id"},
+		{"duplicate email", types.Snapshot{Customers: []types.Customer{customer, {ID: "cus_2", Email: "A@example.com"}}}, "customers[1].email"},
+		{"unknown intent customer", types.Snapshot{PaymentIntents: []types.PaymentIntent{{ID: "pi_1", Amount: 100, Currency: "thb", Customer: "cus_2"}}}, "payment_intents[0].customer"},
+		{"unknown refund intent", types.Snapshot{Refunds: []types.Refund{{ID: "re_1", PaymentIntent: "pi_1"}}}, "refunds[0].payment_intent"},
+		{"unsupported account type", types.Snapshot{Customers: []types.Customer{customer}, Accounts: []types.Account{{CustomerID: "cus_1", Type: "bitcoin", Currency: "thb"}}}, "accounts[0].type"},
+		{"duplicate account", types.Snapshot{Customers: []types.Customer{customer}, Accounts: []types.Account{account, account}}, "accounts[1]"},
+		{"held without holds", types.Snapshot{Customers: []types.Customer{customer}, Accounts: []types.Account{{CustomerID: "cus_1", Type: types.PaymentTypeCash, Currency: "thb", Balance: 100, Held: 50}}}, "accounts[0].held"},
+		{"unknown transaction customer", types.Snapshot{Transactions: []types.Transaction{{ID: "txn_1", CustomerID: "cus_2"}}}, "transactions[0].customer_id"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewMemoryStore()
+			before := TakeSnapshot(store)
+			err := RestoreSnapshot(store, &tt.snapshot)
+			wantDataError(t, err, ErrorKindInvalid, CodeParameterInvalid)
+			if dataErr := err.(*Error); dataErr.Param != tt.param {
+				t.Errorf("param = %q, want %q", dataErr.Param, tt.param)
+			}
+			if after := TakeSnapshot(store); !reflect.DeepEqual(after, before) {
+				t.Error("a rejected snapshot changed the store")
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package data
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nerdgarten/mock-payment-service/types"
+)
+
+// binRange maps card numbers starting with a prefix between start and end,
+// both inclusive and of the same length, to a brand.
+type binRange struct {
+	brand      string
+	start, end string
+}
+
+// binRanges are the issuer identification ranges of the supported brands.
+var binRanges = []binRange{
+	{"amex", "34", "34"},
+	{"amex", "37", "37"},
+	{"diners", "300", "305"},
+	{"diners", "36", "36"},
+	{"diners", "38", "39"},
+	{"jcb", "3528", "3589"},
+	{"visa", "4", "4"},
+	{"mastercard", "2221", "2720"},
+	{"mastercard", "51", "55"},
+	{"discover", "6011", "6011"},
+	{"discover", "644", "649"},
+	{"discover", "65", "65"},
+	{"unionpay", "62", "62"},
+}
+
+// cardBrandSpec is the valid number lengths and CVC lengths of a brand.
+type cardBrandSpec struct {
+	lengths    []int
+	cvcLengths []int
+}
+
+var cardBrandSpecs = map[string]cardBrandSpec{
+	"visa":       {[]int{13, 16, 19}, []int{3}},
+	"mastercard": {[]int{16}, []int{3}},
+	"amex":       {[]int{15}, []int{4}},
+	"diners":     {[]int{14, 16, 19}, []int{3}},
+	"jcb":        {[]int{16, 17, 18, 19}, []int{3}},
+	"discover":   {[]int{16, 17, 18, 19}, []int{3}},
+	"unionpay":   {[]int{16, 17, 18, 19}, []int{3}},
+	"unknown":    {[]int{12, 13, 14, 15, 16, 17, 18, 19}, []int{3, 4}},
+}
+
+// cardBrand detects the brand of a card number from its BIN range.
+func cardBrand(number string) string {
+	for _, r := range binRanges {
+		if len(number) < len(r.start) {
+			continue
+		}
+		// Equal-length digit strings compare like the numbers they spell.
+		if prefix := number[:len(r.start)]; prefix >= r.start && prefix <= r.end {
+			return r.brand
+		}
+	}
+	return "unknown"
+}
+
+// luhnValid reports whether the check digit of a card number is correct.
+func luhnValid(number string) bool {
+	sum := 0
+	for i := len(number) - 1; i >= 0; i-- {
+		digit := int(number[i] - '0')
+		if (len(number)-i)%2 == 0 {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+	}
+	return sum%10 == 0
+}
+
+// cardFingerprint identifies a card number without revealing it. The same
+// number always has the same fingerprint, so duplicate cards can be detected.
+func cardFingerprint(number string) string {
+	sum := sha256.Sum256([]byte("mock-payment-service:card:" + number))
+	return hex.EncodeToString(sum[:8])
+}
+
+// cardError reports card details that would not be accepted by the card
+// network.
+func cardError(code, param, message string) *Error {
+	return &Error{Kind: ErrorKindCardError, Code: code, Param: param, Message: message}
+}
+
+// newCard validates raw card details and keeps only what may be stored: the
+// brand, last four digits, expiry and fingerprint. Two-digit expiry years are
+// taken to be in this century.
+func newCard(params *types.CardParams, now time.Time) (*types.Card, error) {
+	if params == nil || params.Number == "" {
+		return nil, &Error{Kind: ErrorKindInvalid, Code: CodeParameterMissing, Param: "card[number]", Message: "card[number] is required"}
+	}
+	number := normalizeCardNumber(params.Number)
+	brand := cardBrand(number)
+	spec := cardBrandSpecs[brand]
+	if strings.Trim(number, "0123456789") != "" || !slices.Contains(spec.lengths, len(number)) || !luhnValid(number) {
+		return nil, cardError(CodeIncorrectNumber, "card[number]", "Your card number is incorrect.")
+	}
+	if params.ExpMonth < 1 || params.ExpMonth > 12 {
+		return nil, &Error{Kind: ErrorKindInvalid, Code: CodeParameterInvalid, Param: "card[exp_month]", Message: "card[exp_month] must be between 1 and 12"}
+	}
+	expYear := params.ExpYear
+	if expYear >= 0 && expYear < 100 {
+		expYear += 2000
+	}
+	if expYear < now.Year() || expYear == now.Year() && params.ExpMonth < int(now.Month()) {
+		return nil, cardError(CodeExpiredCard, "card[exp_year]", "Your card has expired.")
+	}
+	if params.CVC != "" && (strings.Trim(params.CVC, "0123456789") != "" || !slices.Contains(spec.cvcLengths, len(params.CVC))) {
+		return nil, cardError(CodeInvalidCVC, "card[cvc]", "Your card's security code is invalid.")
+	}
+	return &types.Card{
+		Brand:       brand,
+		Last4:       number[len(number)-4:],
+		ExpMonth:    params.ExpMonth,
+		ExpYear:     expYear,
+		Fingerprint: cardFingerprint(number),
+	}, nil
+}
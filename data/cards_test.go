@@ -0,0 +1,110 @@
+package data
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nerdgarten/mock-payment-service/types"
+)
+
+func TestLuhnValid(t *testing.T) {
+	tests := []struct {
+		number string
+		want   bool
+	}{
+		{"4242424242424242", true},
+		{"4242424242424241", false},
+		{"378282246310005", true},
+		{"378282246310006", false},
+		{"36227206271667", true},
+		{"0", true},
+		{"18", true},
+		{"19", false},
+	}
+	for _, tt := range tests {
+		if got := luhnValid(tt.number); got != tt.want {
+			t.Errorf("luhnValid(%s) = %t, want %t", tt.number, got, tt.want)
+		}
+	}
+}
+
+func TestCardBrand(t *testing.T) {
+	tests := []struct {
+		number string
+		want   string
+	}{
+		{"4242424242424242", "visa"},
+		{"5555555555554444", "mastercard"},
+		{"2221000000000009", "mastercard"},
+		{"2720990000000007", "mastercard"},
+		{"2721000000000000", "unknown"},
+		{"378282246310005", "amex"},
+		{"341111111111111", "amex"},
+		{"3056930009020004", "diners"},
+		{"36227206271667", "diners"},
+		{"3566002020360505", "jcb"},
+		{"3527000000000000", "unknown"},
+		{"6011111111111117", "discover"},
+		{"6445644564456445", "discover"},
+		{"6200000000000005", "unionpay"},
+		{"9999999999999995", "unknown"},
+		{"3", "unknown"},
+	}
+	for _, tt := range tests {
+		if got := cardBrand(tt.number); got != tt.want {
+			t.Errorf("cardBrand(%s) = %s, want %s", tt.number, got, tt.want)
+		}
+	}
+}
+
+func TestNewCard(t *testing.T) {
+	now := time.Date(2026, time.June, 15, 0, 0, 0, 0, time.UTC)
+	card, err := newCard(&types.CardParams{Number: "4242 4242 4242 4242", ExpMonth: 6, ExpYear: 26, CVC: "123"}, now)
+	if err != nil {
+		t.Fatalf("newCard: %v", err)
+	}
+	want := types.Card{Brand: "visa", Last4: "4242", ExpMonth: 6, ExpYear: 2026, Fingerprint: cardFingerprint("4242424242424242")}
+	if *card != want {
+		t.Errorf("newCard = %+v, want %+v", *card, want)
+	}
+	if amex, err := newCard(&types.CardParams{Number: "378282246310005", ExpMonth: 1, ExpYear: 2030, CVC: "1234"}, now); err != nil || amex.Brand != "amex" {
+		t.Errorf("newCard(amex) = %+v, %v", amex, err)
+	}
+}
+
+func TestNewCardRejectsInvalidDetails(t *testing.T) {
+	now := time.Date(2026, time.June, 15, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		params *types.CardParams
+		kind   ErrorKind
+		code   string
+		param  string
+	}{
+		{"missing", nil, ErrorKindInvalid, CodeParameterMissing, "card[number]"},
+		{"empty number", &types.CardParams{ExpMonth: 1, ExpYear: 2030}, ErrorKindInvalid, CodeParameterMissing, "card[number]"},
+		{"check digit", &types.CardParams{Number: "4242424242424241", ExpMonth: 1, ExpYear: 2030}, ErrorKindCardError, CodeIncorrectNumber, "card[number]"},
+		{"letters", &types.CardParams{Number: "4242x24242424242", ExpMonth: 1, ExpYear: 2030}, ErrorKindCardError, CodeIncorrectNumber, "card[number]"},
+		{"brand length", &types.CardParams{Number: "55555555555544", ExpMonth: 1, ExpYear: 2030}, ErrorKindCardError, CodeIncorrectNumber, "card[number]"},
+		{"month zero", &types.CardParams{Number: "4242424242424242", ExpMonth: 0, ExpYear: 2030}, ErrorKindInvalid, CodeParameterInvalid, "card[exp_month]"},
+		{"month 13", &types.CardParams{Number: "4242424242424242", ExpMonth: 13, ExpYear: 2030}, ErrorKindInvalid, CodeParameterInvalid, "card[exp_month]"},
+		{"last year", &types.CardParams{Number: "4242424242424242", ExpMonth: 12, ExpYear: 2025}, ErrorKindCardError, CodeExpiredCard, "card[exp_year]"},
+		{"last month", &types.CardParams{Number: "4242424242424242", ExpMonth: 5, ExpYear: 26}, ErrorKindCardError, CodeExpiredCard, "card[exp_year]"},
+		{"short cvc", &types.CardParams{Number: "4242424242424242", ExpMonth: 1, ExpYear: 2030, CVC: "12"}, ErrorKindCardError, CodeInvalidCVC, "card[cvc]"},
+		{"amex cvc", &types.CardParams{Number: "378282246310005", ExpMonth: 1, ExpYear: 2030, CVC: "123"}, ErrorKindCardError, CodeInvalidCVC, "card[cvc]"},
+		{"cvc letters", &types.CardParams{Number: "4242424242424242", ExpMonth: 1, ExpYear: 2030, CVC: "12a"}, ErrorKindCardError, CodeInvalidCVC, "card[cvc]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newCard(tt.params, now)
+			var dataErr *Error
+			if !errors.As(err, &dataErr) {
+				t.Fatalf("newCard error = %v, want *Error", err)
+			}
+			if dataErr.Kind != tt.kind || dataErr.Code != tt.code || dataErr.Param != tt.param {
+				t.Errorf("newCard error = %+v, want kind %d code %s param %s", dataErr, tt.kind, tt.code, tt.param)
+			}
+		})
+	}
+}
@@ -0,0 +1,272 @@
+package data
+
+import (
+	"fmt"
+	"net/mail"
+	"strconv"
+	"strings"
+
+	"github.com/nerdgarten/mock-payment-service/types"
+)
+
+// activeCustomer returns the customer with id unless it is missing or deleted.
+func activeCustomer(tx ReadTx, id string) *types.Customer {
+	if customer := tx.Customer(id); customer != nil && !customer.Deleted {
+		return customer
+	}
+	return nil
+}
+
+// validateEmail rejects anything but a bare address such as "ruff@example.com".
+func validateEmail(email string) *Error {
+	if address, err := mail.ParseAddress(email); err != nil || address.Address != email {
+		return &Error{Kind: ErrorKindInvalid, Code: CodeEmailInvalid, Param: "email", Message: fmt.Sprintf("invalid email address %q", email)}
+	}
+	return nil
+}
+
+// checkCustomerEmail validates a non-empty email and rejects it when another
+// customer than id uses it. Emails are compared case-insensitively and deleted
+// customers are ignored.
+func checkCustomerEmail(tx ReadTx, id, email string) error {
+	if email == "" {
+		return nil
+	}
+	if err := validateEmail(email); err != nil {
+		return err
+	}
+	for _, customer := range tx.Customers() {
+		if customer.ID != id && !customer.Deleted && strings.EqualFold(customer.Email, email) {
+			return &Error{
+				Kind:    ErrorKindConflict,
+				Code:    CodeResourceAlreadyExists,
+				Param:   "email",
+				Message: fmt.Sprintf("a customer with email %s already exists: %s", email, customer.ID),
+			}
+		}
+	}
+	return nil
+}
+
+// UpdateMockCustomer changes the non-nil fields of a customer. A non-empty
+// defaultPaymentMethod must be attached to the customer.
+func UpdateMockCustomer(store Store, id string, name, email, defaultPaymentMethod *string) (*types.Customer, error) {
+	var out types.Customer
+	err := store.Update(func(tx Tx) error {
+		customer := activeCustomer(tx, id)
+		if customer == nil {
+			return notFoundError("customer", id)
+		}
+		if email != nil {
+			if err := checkCustomerEmail(tx, id, *email); err != nil {
+				return err
+			}
+		}
+		if defaultPaymentMethod != nil && *defaultPaymentMethod != "" {
+			if method := tx.PaymentMethod(*defaultPaymentMethod); method == nil || method.Customer != id {
+				return &Error{
+					Kind:    ErrorKindInvalid,
+					Code:    CodeParameterInvalid,
+					Param:   "default_payment_method",
+					Message: fmt.Sprintf("payment method %s is not attached to customer %s", *defaultPaymentMethod, id),
+				}
+			}
+		}
+		if email != nil {
+			customer.Email = *email
+		}
+		if defaultPaymentMethod != nil {
+			customer.DefaultPaymentMethod = *defaultPaymentMethod
+		}
+		if name != nil {
+			customer.Name = *name
+		}
+		emit(tx, types.EventCustomerUpdated, customer)
+		out = *customer
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteMockCustomer marks a customer deleted. The customer and its accounts
+// stay in the store for the objects referring to them, but can no longer be
+// retrieved through the wallet endpoints, listed, searched or paid with.
+func DeleteMockCustomer(store Store, id string) error {
+	return store.Update(func(tx Tx) error {
+		customer := activeCustomer(tx, id)
+		if customer == nil {
+			return notFoundError("customer", id)
+		}
+		customer.Deleted = true
+		emit(tx, types.EventCustomerDeleted, types.DeletedObject{ID: id, Object: "customer", Deleted: true})
+		return nil
+	})
+}
+
+// customerQuery is a parsed customer search query: clauses joined by AND or,
+// when or is set, by OR.
+type customerQuery struct {
+	clauses []queryClause
+	or      bool
+}
+
+// queryClause compares a field with a value. op is ":" for equality, "~" for
+// substring matches and "<", "<=", ">" or ">=" for numeric comparisons.
+type queryClause struct {
+	field  string
+	op     string
+	value  string
+	number int64
+	negate bool
+}
+
+// parseCustomerQuery parses the supported subset of the Stripe search query
+// language: email and name compared with ':' or '~' against a quoted string,
+// created compared with ':', '<', '<=', '>' or '>=' against a Unix timestamp,
+// '-' to negate a clause, and AND or OR (not both) between clauses.
+func parseCustomerQuery(query string) (*customerQuery, error) {
+	invalid := func(format string, args ...any) error {
+		return &Error{Kind: ErrorKindInvalid, Code: CodeParameterInvalid, Param: "query", Message: "invalid search query: " + fmt.Sprintf(format, args...)}
+	}
+	var (
+		parsed    customerQuery
+		connector string
+	)
+	rest := strings.TrimSpace(query)
+	if rest == "" {
+		return nil, invalid("query is empty")
+	}
+	for {
+		var clause queryClause
+		if after, ok := strings.CutPrefix(rest, "-"); ok {
+			clause.negate = true
+			rest = after
+		}
+		end := strings.IndexAny(rest, ":~<>")
+		if end <= 0 {
+			return nil, invalid("expected a field followed by ':', '~', '<' or '>' in %q", rest)
+		}
+		clause.field = rest[:end]
+		rest = rest[end:]
+		switch {
+		case strings.HasPrefix(rest, "<="), strings.HasPrefix(rest, ">="):
+			clause.op, rest = rest[:2], rest[2:]
+		default:
+			clause.op, rest = rest[:1], rest[1:]
+		}
+
+		switch clause.field {
+		case "email", "name":
+			if clause.op != ":" && clause.op != "~" {
+				return nil, invalid("%s only supports ':' and '~'", clause.field)
+			}
+			if !strings.HasPrefix(rest, "'") && !strings.HasPrefix(rest, `"`) {
+				return nil, invalid("%s value must be quoted", clause.field)
+			}
+			closing := strings.IndexByte(rest[1:], rest[0])
+			if closing < 0 {
+				return nil, invalid("unterminated string in %q", rest)
+			}
+			clause.value, rest = rest[1:closing+1], rest[closing+2:]
+			if clause.op == "~" && len(clause.value) < 3 {
+				return nil, invalid("'~' needs at least 3 characters")
+			}
+		case "created":
+			if clause.op == "~" {
+				return nil, invalid("created does not support '~'")
+			}
+			value, after, _ := strings.Cut(rest, " ")
+			number, err := strconv.ParseInt(strings.Trim(value, `'"`), 10, 64)
+			if err != nil {
+				return nil, invalid("created must be compared with a Unix timestamp")
+			}
+			clause.number, rest = number, " "+after
+		default:
+			return nil, invalid("unsupported field %q; use email, name or created", clause.field)
+		}
+		parsed.clauses = append(parsed.clauses, clause)
+
+		rest = strings.TrimSpace(rest)
+		if rest == "" {
+			return &parsed, nil
+		}
+		word, after, _ := strings.Cut(rest, " ")
+		if word != "AND" && word != "OR" {
+			return nil, invalid("expected AND or OR before %q", rest)
+		}
+		if connector != "" && word != connector {
+			return nil, invalid("AND and OR cannot be combined")
+		}
+		connector = word
+		parsed.or = word == "OR"
+		rest = strings.TrimSpace(after)
+	}
+}
+
+func (q *customerQuery) matches(customer *types.Customer) bool {
+	for _, clause := range q.clauses {
+		if clause.matches(customer) == q.or {
+			return q.or
+		}
+	}
+	return !q.or
+}
+
+func (c queryClause) matches(customer *types.Customer) bool {
+	var match bool
+	switch c.field {
+	case "email", "name":
+		value := customer.Email
+		if c.field == "name" {
+			value = customer.Name
+		}
+		if c.op == "~" {
+			match = strings.Contains(strings.ToLower(value), strings.ToLower(c.value))
+		} else {
+			match = strings.EqualFold(value, c.value)
+		}
+	case "created":
+		switch c.op {
+		case ":":
+			match = customer.Created == c.number
+		case "<":
+			match = customer.Created < c.number
+		case "<=":
+			match = customer.Created <= c.number
+		case ">":
+			match = customer.Created > c.number
+		case ">=":
+			match = customer.Created >= c.number
+		}
+	}
+	return match != c.negate
+}
+
+// SearchMockCustomers returns the customers matching query, newest first, in
+// pages of limit starting after the customer ID page, and whether more follow.
+// Deleted customers are never returned.
+func SearchMockCustomers(store Store, query string, limit int, page string) ([]types.Customer, bool, error) {
+	parsed, err := parseCustomerQuery(query)
+	if err != nil {
+		return nil, false, err
+	}
+	var (
+		results []types.Customer
+		hasMore bool
+	)
+	err = store.View(func(tx ReadTx) error {
+		if page != "" && tx.Customer(page) == nil {
+			return &Error{Kind: ErrorKindInvalid, Code: CodeParameterInvalid, Param: "page", Message: "invalid page " + page}
+		}
+		match := func(customer *types.Customer) bool {
+			return !customer.Deleted && parsed.matches(customer)
+		}
+		var err error
+		results, hasMore, err = listPage(tx.Customers(), func(c *types.Customer) string { return c.ID }, match, ListParams{Limit: limit, StartingAfter: page})
+		return err
+	})
+	return results, hasMore, err
+}
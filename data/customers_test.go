@@ -0,0 +1,194 @@
+package data
+
+import (
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/nerdgarten/mock-payment-service/types"
+)
+
+func TestParseCustomerQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  customerQuery
+	}{
+		{`email:'ruff@example.com'`, customerQuery{clauses: []queryClause{{field: "email", op: ":", value: "ruff@example.com"}}}},
+		{`name:"John Doe"`, customerQuery{clauses: []queryClause{{field: "name", op: ":", value: "John Doe"}}}},
+		{`name~"it's"`, customerQuery{clauses: []queryClause{{field: "name", op: "~", value: "it's"}}}},
+		{`  -email:'a@b.c'  `, customerQuery{clauses: []queryClause{{field: "email", op: ":", value: "a@b.c", negate: true}}}},
+		{`created>=1734567800`, customerQuery{clauses: []queryClause{{field: "created", op: ">=", number: 1734567800}}}},
+		{`created<'1734567890'`, customerQuery{clauses: []queryClause{{field: "created", op: "<", number: 1734567890}}}},
+		{
+			`name~'ruf' AND created>1734567800 AND -email:'x@y.z'`,
+			customerQuery{clauses: []queryClause{
+				{field: "name", op: "~", value: "ruf"},
+				{field: "created", op: ">", number: 1734567800},
+				{field: "email", op: ":", value: "x@y.z", negate: true},
+			}},
+		},
+		{
+			`email:'a@b.c' OR email:"d@e.f"`,
+			customerQuery{or: true, clauses: []queryClause{
+				{field: "email", op: ":", value: "a@b.c"},
+				{field: "email", op: ":", value: "d@e.f"},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			got, err := parseCustomerQuery(tt.query)
+			if err != nil {
+				t.Fatalf("parseCustomerQuery: %v", err)
+			}
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("parseCustomerQuery = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCustomerQueryRejectsMalformedQueries(t *testing.T) {
+	for _, query := range []string{
+		``,
+		`   `,
+		`ruff`,
+		`:'ruff'`,
+		`email:ruff@example.com`,
+		`email:'ruff@example.com`,
+		`name<'ruff'`,
+		`name~'ru'`,
+		`created~1734567800`,
+		`created>yesterday`,
+		`phone:'123'`,
+		`email:'a@b.c' email:'d@e.f'`,
+		`email:'a@b.c' AND`,
+		`email:'a@b.c' AND email:'d@e.f' OR name:'x'`,
+		`email:'a@b.c' and name:'x'`,
+	} {
+		_, err := parseCustomerQuery(query)
+		var dataErr *Error
+		if !errors.As(err, &dataErr) {
+			t.Errorf("parseCustomerQuery(%q) error = %v, want *Error", query, err)
+			continue
+		}
+		if dataErr.Kind != ErrorKindInvalid || dataErr.Code != CodeParameterInvalid || dataErr.Param != "query" {
+			t.Errorf("parseCustomerQuery(%q) = %+v, want invalid query parameter", query, dataErr)
+		}
+	}
+}
+
+func TestSearchMockCustomers(t *testing.T) {
+	store := NewEmptyMemoryStore()
+	_ = store.Update(func(tx Tx) error {
+		for _, customer := range []types.Customer{
+			{ID: "cus_a", Name: "Ruff", Email: "ruff@example.com", Created: 100},
+			{ID: "cus_b", Name: "John Doe", Email: "john@example.com", Created: 200},
+			{ID: "cus_c", Name: "Jane Doe", Email: "jane@example.com", Created: 300},
+			{ID: "cus_d", Name: "Gone Doe", Email: "gone@example.com", Created: 400, Deleted: true},
+		} {
+			tx.PutCustomer(&customer)
+		}
+		return nil
+	})
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{`name~'doe'`, []string{"cus_c", "cus_b"}},
+		{`email:'RUFF@example.com'`, []string{"cus_a"}},
+		{`-name~'doe'`, []string{"cus_a"}},
+		{`created>=200 AND created<300`, []string{"cus_b"}},
+		{`created:100 OR name:'jane doe'`, []string{"cus_c", "cus_a"}},
+		{`name:'gone doe'`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			results, hasMore, err := SearchMockCustomers(store, tt.query, 10, "")
+			if err != nil {
+				t.Fatalf("SearchMockCustomers: %v", err)
+			}
+			var ids []string
+			for _, customer := range results {
+				ids = append(ids, customer.ID)
+			}
+			if !slices.Equal(ids, tt.want) || hasMore {
+				t.Errorf("SearchMockCustomers = %v (has_more %t), want %v", ids, hasMore, tt.want)
+			}
+		})
+	}
+
+	page, hasMore, err := SearchMockCustomers(store, `name~'doe'`, 1, "")
+	if err != nil || len(page) != 1 || page[0].ID != "cus_c" || !hasMore {
+		t.Fatalf("first page = %v, %t, %v, want cus_c with more", page, hasMore, err)
+	}
+	page, hasMore, err = SearchMockCustomers(store, `name~'doe'`, 1, page[0].ID)
+	if err != nil || len(page) != 1 || page[0].ID != "cus_b" || hasMore {
+		t.Errorf("second page = %v, %t, %v, want cus_b and no more", page, hasMore, err)
+	}
+	if _, _, err := SearchMockCustomers(store, `name~'doe'`, 1, "cus_missing"); err == nil {
+		t.Error("SearchMockCustomers accepted an unknown page")
+	}
+}
+
+func TestUpdateMockCustomer(t *testing.T) {
+	store := NewEmptyMemoryStore()
+	alice, err := CreateMockCustomer(store, "Alice", "alice@example.com")
+	if err != nil {
+		t.Fatalf("CreateMockCustomer: %v", err)
+	}
+	if _, err := CreateMockCustomer(store, "Bob", "bob@example.com"); err != nil {
+		t.Fatalf("CreateMockCustomer: %v", err)
+	}
+
+	name, email := "Alice Smith", "alice.smith@example.com"
+	updated, err := UpdateMockCustomer(store, alice.ID, &name, &email, nil)
+	if err != nil {
+		t.Fatalf("UpdateMockCustomer: %v", err)
+	}
+	if updated.Name != name || updated.Email != email {
+		t.Errorf("updated customer = %+v", updated)
+	}
+	// Nil fields are left unchanged.
+	updated, err = UpdateMockCustomer(store, alice.ID, nil, nil, nil)
+	if err != nil || updated.Name != name || updated.Email != email {
+		t.Errorf("UpdateMockCustomer without fields = %+v, %v", updated, err)
+	}
+
+	taken, invalid := "BOB@example.com", "Bob <bob@example.com>"
+	_, err = UpdateMockCustomer(store, alice.ID, nil, &taken, nil)
+	wantDataError(t, err, ErrorKindConflict, CodeResourceAlreadyExists)
+	_, err = UpdateMockCustomer(store, alice.ID, nil, &invalid, nil)
+	wantDataError(t, err, ErrorKindInvalid, CodeEmailInvalid)
+	_, err = UpdateMockCustomer(store, "cus_missing", &name, nil, nil)
+	wantDataError(t, err, ErrorKindNotFound, CodeResourceMissing)
+	if got := GetMockCustomer(store, alice.ID); got.Email != email {
+		t.Errorf("rejected updates changed the email to %q", got.Email)
+	}
+}
+
+func TestDeleteMockCustomer(t *testing.T) {
+	store := NewEmptyMemoryStore()
+	alice, err := CreateMockCustomer(store, "Alice", "alice@example.com")
+	if err != nil {
+		t.Fatalf("CreateMockCustomer: %v", err)
+	}
+	if err := DeleteMockCustomer(store, alice.ID); err != nil {
+		t.Fatalf("DeleteMockCustomer: %v", err)
+	}
+	if got := GetMockCustomer(store, alice.ID); got == nil || !got.Deleted {
+		t.Errorf("deleted customer = %+v, want it kept as deleted", got)
+	}
+	wantDataError(t, DeleteMockCustomer(store, alice.ID), ErrorKindNotFound, CodeResourceMissing)
+	_, err = Deposit(store, alice.ID, types.PaymentTypeCash, types.Money{Amount: 100})
+	wantDataError(t, err, ErrorKindNotFound, CodeResourceMissing)
+	name := "Alice"
+	_, err = UpdateMockCustomer(store, alice.ID, &name, nil, nil)
+	wantDataError(t, err, ErrorKindNotFound, CodeResourceMissing)
+
+	// The email of a deleted customer can be reused.
+	if _, err := CreateMockCustomer(store, "Alice", "alice@example.com"); err != nil {
+		t.Errorf("CreateMockCustomer with a deleted customer's email: %v", err)
+	}
+}
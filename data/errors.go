@@ -0,0 +1,84 @@
+package data
+
+import (
+	"fmt"
+
+	"github.com/nerdgarten/mock-payment-service/types"
+)
+
+// ErrorKind classifies a data layer failure so callers can choose a response.
+type ErrorKind int
+
+const (
+	// ErrorKindInvalid reports a request the data layer cannot act on.
+	ErrorKindInvalid ErrorKind = iota + 1
+	// ErrorKindNotFound reports a missing object.
+	ErrorKindNotFound
+	// ErrorKindConflict reports a request that clashes with an object's current state.
+	ErrorKindConflict
+	// ErrorKindPaymentFailed reports a payment declined by the payment method.
+	ErrorKindPaymentFailed
+	// ErrorKindCardError reports card details that fail validation, such as
+	// a number with a wrong check digit.
+	ErrorKindCardError
+)
+
+// Error codes returned alongside data layer errors.
+const (
+	CodeResourceMissing              = "resource_missing"
+	CodeParameterMissing             = "parameter_missing"
+	CodeParameterInvalid             = "parameter_invalid"
+	CodeAmountTooLarge               = "amount_too_large"
+	CodeChargeAlreadyRefunded        = "charge_already_refunded"
+	CodePaymentIntentUnexpectedState = "payment_intent_unexpected_state"
+	CodeBalanceInsufficient          = "balance_insufficient"
+	CodeEmailInvalid                 = "email_invalid"
+	CodeResourceAlreadyExists        = "resource_already_exists"
+	CodePaymentMethodUnexpectedState = "payment_method_unexpected_state"
+	CodeIncorrectNumber              = "incorrect_number"
+	CodeExpiredCard                  = "expired_card"
+	CodeInvalidCVC                   = "invalid_cvc"
+	CodeHoldUnexpectedState          = "hold_unexpected_state"
+)
+
+// Error is returned by data layer operations that reject a request.
+type Error struct {
+	Kind        ErrorKind
+	Code        string
+	DeclineCode string
+	Param       string
+	Message     string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func notFoundError(object, id string) *Error {
+	return &Error{
+		Kind:    ErrorKindNotFound,
+		Code:    CodeResourceMissing,
+		Message: fmt.Sprintf("%s not found: %s", object, id),
+	}
+}
+
+func paymentFailedError(paymentErr *types.PaymentError) *Error {
+	return &Error{
+		Kind:        ErrorKindPaymentFailed,
+		Code:        paymentErr.Code,
+		DeclineCode: paymentErr.DeclineCode,
+		Message:     paymentErr.Message,
+	}
+}
+
+// insufficientBalanceError reports a wallet account that cannot cover a debit.
+// It is an invalid request rather than a card decline: no payment method was
+// charged, so there is no decline code.
+func insufficientBalanceError() *Error {
+	return &Error{
+		Kind:    ErrorKindInvalid,
+		Code:    CodeBalanceInsufficient,
+		Param:   "amount",
+		Message: "Insufficient balance",
+	}
+}
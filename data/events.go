@@ -0,0 +1,133 @@
+package data
+
+import (
+	"encoding/json"
+	"net/url"
+	"time"
+
+	"github.com/nerdgarten/mock-payment-service/types"
+)
+
+// emit queues an event of eventType carrying object on tx.
+func emit(tx Tx, eventType string, object any) {
+	raw, err := json.Marshal(object)
+	if err != nil {
+		raw = json.RawMessage("null")
+	}
+	emitRaw(tx, eventType, raw)
+}
+
+func emitRaw(tx Tx, eventType string, raw json.RawMessage) types.Event {
+	event := types.Event{
+		ID:      tx.NewID("evt"),
+		Object:  "event",
+		Type:    eventType,
+		Created: time.Now().Unix(),
+		Data:    types.EventData{Object: raw},
+	}
+	tx.Emit(event)
+	return event
+}
+
+// EmitTestEvent emits an arbitrary event to the registered webhook endpoints.
+// payload is embedded verbatim when it is valid JSON and as a string otherwise.
+func EmitTestEvent(store Store, eventType, payload string) (*types.Event, error) {
+	if eventType == "" {
+		return nil, &Error{Kind: ErrorKindInvalid, Code: CodeParameterMissing, Message: "type is required"}
+	}
+	raw := json.RawMessage(payload)
+	if !json.Valid(raw) {
+		raw, _ = json.Marshal(payload)
+	}
+	var event types.Event
+	err := store.Update(func(tx Tx) error {
+		event = emitRaw(tx, eventType, raw)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+// CreateWebhookEndpoint registers an endpoint that receives events. A signing
+// secret is generated when secret is empty.
+func CreateWebhookEndpoint(store Store, endpointURL string, enabledEvents []string, secret string) (*types.WebhookEndpoint, error) {
+	parsed, err := url.Parse(endpointURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, &Error{Kind: ErrorKindInvalid, Code: CodeParameterInvalid, Message: "url must be an absolute http or https URL"}
+	}
+	if len(enabledEvents) == 0 {
+		enabledEvents = []string{types.WebhookEndpointAllEvents}
+	}
+	var out types.WebhookEndpoint
+	err = store.Update(func(tx Tx) error {
+		if secret == "" {
+			secret = tx.NewID("whsec")
+		}
+		endpoint := &types.WebhookEndpoint{
+			ID:            tx.NewID("we"),
+			Object:        "webhook_endpoint",
+			URL:           endpointURL,
+			EnabledEvents: enabledEvents,
+			Secret:        secret,
+			Created:       time.Now().Unix(),
+		}
+		tx.PutWebhookEndpoint(endpoint)
+		out = *endpoint
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetWebhookEndpoint retrieves a webhook endpoint by ID
+func GetWebhookEndpoint(store Store, id string) *types.WebhookEndpoint {
+	var out *types.WebhookEndpoint
+	_ = store.View(func(tx ReadTx) error {
+		if endpoint := tx.WebhookEndpoint(id); endpoint != nil {
+			e := *endpoint
+			out = &e
+		}
+		return nil
+	})
+	return out
+}
+
+// ListWebhookEndpoints returns the registered webhook endpoints in creation order.
+func ListWebhookEndpoints(store Store) []types.WebhookEndpoint {
+	var out []types.WebhookEndpoint
+	_ = store.View(func(tx ReadTx) error {
+		for _, endpoint := range tx.WebhookEndpoints() {
+			out = append(out, *endpoint)
+		}
+		return nil
+	})
+	return out
+}
+
+// DeleteWebhookEndpoint removes a webhook endpoint.
+func DeleteWebhookEndpoint(store Store, id string) error {
+	return store.Update(func(tx Tx) error {
+		if tx.WebhookEndpoint(id) == nil {
+			return notFoundError("webhook endpoint", id)
+		}
+		tx.DeleteWebhookEndpoint(id)
+		return nil
+	})
+}
+
+// WebhookEndpointEnabled reports whether endpoint subscribes to eventType.
+func WebhookEndpointEnabled(endpoint *types.WebhookEndpoint, eventType string) bool {
+	if len(endpoint.EnabledEvents) == 0 {
+		return true
+	}
+	for _, enabled := range endpoint.EnabledEvents {
+		if enabled == types.WebhookEndpointAllEvents || enabled == eventType {
+			return true
+		}
+	}
+	return false
+}
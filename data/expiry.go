@@ -0,0 +1,56 @@
+package data
+
+import "errors"
+
+// expireDue expires every object due returns and reports how many there were.
+// Most requests find nothing to expire, so it looks under the read lock before
+// taking the write lock.
+func expireDue[T any](store Store, due func(tx ReadTx) []T, expire func(tx Tx, object T)) (int, error) {
+	var pending bool
+	_ = store.View(func(tx ReadTx) error {
+		pending = len(due(tx)) > 0
+		return nil
+	})
+	if !pending {
+		return 0, nil
+	}
+	var expired int
+	err := store.Update(func(tx Tx) error {
+		for _, object := range due(tx) {
+			expire(tx, object)
+			expired++
+		}
+		return nil
+	})
+	return expired, err
+}
+
+// expiredError fails an operation on an object that turned out to be past its
+// deadline and was expired instead.
+type expiredError struct {
+	err error
+}
+
+func (e *expiredError) Error() string { return e.err.Error() }
+
+func (e *expiredError) Unwrap() error { return e.err }
+
+// updateExpiring runs fn in a store update. If fn returns an *expiredError,
+// the expiry it wrote is committed and the wrapped error returned; any other
+// error rolls the update back.
+func updateExpiring(store Store, fn func(tx Tx) error) error {
+	var expired *expiredError
+	err := store.Update(func(tx Tx) error {
+		if err := fn(tx); !errors.As(err, &expired) {
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if expired != nil {
+		return expired.err
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+package data
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/nerdgarten/mock-payment-service/types"
+)
+
+// DefaultHoldTTL is how long a hold reserves funds before it expires and the
+// funds become available again, unless the store sets another lifetime.
+const DefaultHoldTTL = 24 * time.Hour
+
+// CreateMockHold reserves money on a customer's meowth-wallet or credit card
+// account. The held amount stays in the ledger balance but is no longer
+// available. A declining test card rejects a credit card hold.
+func CreateMockHold(store Store, customerID string, paymentType types.PaymentType, money types.Money, orderID, paymentMethod string) (*types.Hold, *types.Account, error) {
+	if paymentType != types.PaymentTypeMeowthWallet && paymentType != types.PaymentTypeCreditCard {
+		return nil, nil, &Error{
+			Kind:    ErrorKindInvalid,
+			Code:    CodeParameterInvalid,
+			Param:   "type",
+			Message: fmt.Sprintf("holds are only supported on %s and %s accounts", types.PaymentTypeMeowthWallet, types.PaymentTypeCreditCard),
+		}
+	}
+	var (
+		hold    types.Hold
+		account types.Account
+	)
+	err := store.Update(func(tx Tx) error {
+		stored, err := walletAccount(tx, customerID, paymentType, money, "Invalid hold amount")
+		if err != nil {
+			return err
+		}
+		if paymentType == types.PaymentTypeCreditCard {
+			if paymentMethod != "" {
+				if err := checkPaymentMethod(tx, paymentMethod, customerID); err != nil {
+					return err
+				}
+			}
+			if paymentErr := paymentMethodDecline(tx, paymentMethod); paymentErr != nil {
+				return paymentFailedError(paymentErr)
+			}
+		}
+		if stored.Available() < money.Amount {
+			return insufficientBalanceError()
+		}
+		now := time.Now()
+		created := &types.Hold{
+			ID:         tx.NewID("hold"),
+			Object:     "hold",
+			CustomerID: customerID,
+			Type:       paymentType,
+			Amount:     money.Amount,
+			Currency:   stored.Currency,
+			Status:     types.HoldStatusActive,
+			OrderID:    orderID,
+			ExpiresAt:  now.Add(tx.HoldTTL()).Unix(),
+			Created:    now.Unix(),
+		}
+		tx.PutHold(created)
+		stored.Held += money.Amount
+		recordTransaction(tx, types.TransactionKindHold, stored, money.Amount, created.ID, orderID)
+		emit(tx, types.EventHoldCreated, created)
+		hold, account = *created, *stored
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return &hold, &account, nil
+}
+
+// GetMockHold retrieves a hold by ID
+func GetMockHold(store Store, id string) *types.Hold {
+	var out *types.Hold
+	_ = store.View(func(tx ReadTx) error {
+		if hold := tx.Hold(id); hold != nil {
+			h := *hold
+			out = &h
+		}
+		return nil
+	})
+	return out
+}
+
+// CaptureMockHold debits an active hold's account. A nil amountToCapture
+// captures the whole hold; a smaller amount releases the rest. A hold past its
+// expiry is expired instead and an ErrorKindConflict error returned.
+func CaptureMockHold(store Store, id string, amountToCapture *types.Amount) (*types.Hold, *types.Account, error) {
+	var (
+		hold    types.Hold
+		account types.Account
+	)
+	err := updateExpiring(store, func(tx Tx) error {
+		stored, err := activeHold(tx, id, "capture")
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		if now.Unix() >= stored.ExpiresAt {
+			expireHold(tx, stored)
+			return &expiredError{&Error{
+				Kind:    ErrorKindConflict,
+				Code:    CodeHoldUnexpectedState,
+				Message: fmt.Sprintf("hold %s expired at %s", id, time.Unix(stored.ExpiresAt, 0).UTC().Format(time.RFC3339)),
+			}}
+		}
+		amount := stored.Amount
+		if amountToCapture != nil {
+			amount = *amountToCapture
+		}
+		if amount <= 0 {
+			return &Error{Kind: ErrorKindInvalid, Code: CodeParameterInvalid, Param: "amount_to_capture", Message: "amount_to_capture must be greater than zero"}
+		}
+		if amount > stored.Amount {
+			return &Error{
+				Kind:    ErrorKindInvalid,
+				Code:    CodeAmountTooLarge,
+				Param:   "amount_to_capture",
+				Message: fmt.Sprintf("amount_to_capture %d is greater than the held amount %d of hold %s", amount, stored.Amount, id),
+			}
+		}
+		held := tx.Account(stored.CustomerID, stored.Type)
+		held.Held -= stored.Amount
+		recordTransaction(tx, types.TransactionKindRelease, held, stored.Amount, stored.ID, stored.OrderID)
+		held.Balance -= amount
+		txn := recordTransaction(tx, types.TransactionKindPayment, held, amount, stored.ID, stored.OrderID)
+		stored.Status = types.HoldStatusCaptured
+		stored.AmountCaptured = amount
+		stored.TransactionID = txn.ID
+		emit(tx, types.EventHoldCaptured, stored)
+		hold, account = *stored, *held
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return &hold, &account, nil
+}
+
+// ReleaseMockHold cancels an active hold, making its funds available again.
+func ReleaseMockHold(store Store, id string) (*types.Hold, *types.Account, error) {
+	var (
+		hold    types.Hold
+		account types.Account
+	)
+	err := store.Update(func(tx Tx) error {
+		stored, err := activeHold(tx, id, "release")
+		if err != nil {
+			return err
+		}
+		held := tx.Account(stored.CustomerID, stored.Type)
+		held.Held -= stored.Amount
+		recordTransaction(tx, types.TransactionKindRelease, held, stored.Amount, stored.ID, stored.OrderID)
+		stored.Status = types.HoldStatusReleased
+		emit(tx, types.EventHoldReleased, stored)
+		hold, account = *stored, *held
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return &hold, &account, nil
+}
+
+// ExpireMockHolds expires every active hold whose expiry passed at now,
+// making its funds available again. It returns the number of expired holds.
+func ExpireMockHolds(store Store, now time.Time) (int, error) {
+	due := func(tx ReadTx) []*types.Hold {
+		var holds []*types.Hold
+		for _, hold := range tx.Holds() {
+			if hold.Status == types.HoldStatusActive && now.Unix() >= hold.ExpiresAt {
+				holds = append(holds, hold)
+			}
+		}
+		return holds
+	}
+	return expireDue(store, due, expireHold)
+}
+
+// activeHold returns the hold that action applies to, rejecting missing holds
+// and holds that no longer reserve funds.
+func activeHold(tx Tx, id, action string) (*types.Hold, error) {
+	hold := tx.Hold(id)
+	if hold == nil {
+		return nil, notFoundError("hold", id)
+	}
+	if hold.Status != types.HoldStatusActive {
+		return nil, &Error{
+			Kind:    ErrorKindConflict,
+			Code:    CodeHoldUnexpectedState,
+			Message: fmt.Sprintf("cannot %s hold %s with status %s", action, id, hold.Status),
+		}
+	}
+	return hold, nil
+}
+
+// expireHold marks an active hold expired and frees its funds.
+func expireHold(tx Tx, hold *types.Hold) {
+	if account := tx.Account(hold.CustomerID, hold.Type); account != nil {
+		account.Held -= hold.Amount
+		recordTransaction(tx, types.TransactionKindRelease, account, hold.Amount, hold.ID, hold.OrderID)
+	}
+	hold.Status = types.HoldStatusExpired
+	emit(tx, types.EventHoldExpired, hold)
+}
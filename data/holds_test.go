@@ -0,0 +1,237 @@
+package data
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nerdgarten/mock-payment-service/types"
+)
+
+const holdCustomer = "cus_mock_12345"
+
+// holdStore returns a seeded store whose hold customer's meowth-wallet holds
+// 1000.
+func holdStore(t *testing.T) Store {
+	t.Helper()
+	store := NewMemoryStore()
+	if _, err := SetAccountBalance(store, holdCustomer, types.PaymentTypeMeowthWallet, 1000); err != nil {
+		t.Fatalf("SetAccountBalance: %v", err)
+	}
+	return store
+}
+
+func createHold(t *testing.T, store Store, amount types.Amount) *types.Hold {
+	t.Helper()
+	hold, _, err := CreateMockHold(store, holdCustomer, types.PaymentTypeMeowthWallet, types.Money{Amount: amount}, "order_1", "")
+	if err != nil {
+		t.Fatalf("CreateMockHold: %v", err)
+	}
+	return hold
+}
+
+func wantAccount(t *testing.T, store Store, balance, held types.Amount) {
+	t.Helper()
+	account := GetAccount(store, holdCustomer, types.PaymentTypeMeowthWallet)
+	if account.Balance != balance || account.Held != held {
+		t.Errorf("account balance %d held %d, want %d %d", account.Balance, account.Held, balance, held)
+	}
+}
+
+func TestCreateMockHoldReservesFunds(t *testing.T) {
+	store := holdStore(t)
+	before := time.Now()
+	hold := createHold(t, store, 600)
+	if hold.Status != types.HoldStatusActive || hold.Amount != 600 {
+		t.Errorf("hold status %s amount %d, want active 600", hold.Status, hold.Amount)
+	}
+	if deadline := before.Add(DefaultHoldTTL).Unix(); hold.ExpiresAt < deadline || hold.ExpiresAt > deadline+5 {
+		t.Errorf("expires_at = %d, want about %d", hold.ExpiresAt, deadline)
+	}
+	wantAccount(t, store, 1000, 600)
+
+	// Held funds can be neither held again nor withdrawn.
+	_, _, err := CreateMockHold(store, holdCustomer, types.PaymentTypeMeowthWallet, types.Money{Amount: 500}, "", "")
+	wantDataError(t, err, ErrorKindInvalid, CodeBalanceInsufficient)
+	_, err = Withdraw(store, holdCustomer, types.PaymentTypeMeowthWallet, types.Money{Amount: 500})
+	wantDataError(t, err, ErrorKindInvalid, CodeBalanceInsufficient)
+	_, err = SetAccountBalance(store, holdCustomer, types.PaymentTypeMeowthWallet, 599)
+	wantDataError(t, err, ErrorKindInvalid, CodeParameterInvalid)
+	if _, err := Withdraw(store, holdCustomer, types.PaymentTypeMeowthWallet, types.Money{Amount: 400}); err != nil {
+		t.Fatalf("Withdraw of available funds: %v", err)
+	}
+	wantAccount(t, store, 600, 600)
+}
+
+func TestCreateMockHoldUsesTheStoreTTL(t *testing.T) {
+	store := NewMemoryStore()
+	store.SetHoldTTL(time.Minute)
+	if _, err := SetAccountBalance(store, holdCustomer, types.PaymentTypeMeowthWallet, 1000); err != nil {
+		t.Fatalf("SetAccountBalance: %v", err)
+	}
+	before := time.Now()
+	hold := createHold(t, store, 100)
+	if deadline := before.Add(time.Minute).Unix(); hold.ExpiresAt < deadline || hold.ExpiresAt > deadline+5 {
+		t.Errorf("expires_at = %d, want about %d", hold.ExpiresAt, deadline)
+	}
+}
+
+func TestCreateMockHoldRejectsUnsupportedTypes(t *testing.T) {
+	store := holdStore(t)
+	_, _, err := CreateMockHold(store, holdCustomer, types.PaymentTypeCash, types.Money{Amount: 100}, "", "")
+	wantDataError(t, err, ErrorKindInvalid, CodeParameterInvalid)
+}
+
+func TestCreateMockHoldRejectsUnknownPaymentMethod(t *testing.T) {
+	store := holdStore(t)
+	_, _, err := CreateMockHold(store, holdCustomer, types.PaymentTypeCreditCard, types.Money{Amount: 100}, "", "pm_missing")
+	wantDataError(t, err, ErrorKindNotFound, CodeResourceMissing)
+	if holds := len(TakeSnapshot(store).Holds); holds != 0 {
+		t.Errorf("store has %d holds, want 0", holds)
+	}
+}
+
+func TestCaptureMockHold(t *testing.T) {
+	store := holdStore(t)
+	hold := createHold(t, store, 600)
+
+	tooLarge := types.Amount(601)
+	_, _, err := CaptureMockHold(store, hold.ID, &tooLarge)
+	wantDataError(t, err, ErrorKindInvalid, CodeAmountTooLarge)
+
+	amount := types.Amount(250)
+	captured, account, err := CaptureMockHold(store, hold.ID, &amount)
+	if err != nil {
+		t.Fatalf("CaptureMockHold: %v", err)
+	}
+	if captured.Status != types.HoldStatusCaptured || captured.AmountCaptured != 250 || captured.TransactionID == "" {
+		t.Errorf("captured hold = %+v", captured)
+	}
+	// The uncaptured 350 is released with the capture.
+	if account.Balance != 750 || account.Held != 0 {
+		t.Errorf("account balance %d held %d, want 750 0", account.Balance, account.Held)
+	}
+	wantAccount(t, store, 750, 0)
+
+	_, _, err = CaptureMockHold(store, hold.ID, nil)
+	wantDataError(t, err, ErrorKindConflict, CodeHoldUnexpectedState)
+	_, _, err = ReleaseMockHold(store, hold.ID)
+	wantDataError(t, err, ErrorKindConflict, CodeHoldUnexpectedState)
+	_, _, err = CaptureMockHold(store, "hold_missing", nil)
+	wantDataError(t, err, ErrorKindNotFound, CodeResourceMissing)
+}
+
+func TestReleaseMockHold(t *testing.T) {
+	store := holdStore(t)
+	hold := createHold(t, store, 600)
+	released, account, err := ReleaseMockHold(store, hold.ID)
+	if err != nil {
+		t.Fatalf("ReleaseMockHold: %v", err)
+	}
+	if released.Status != types.HoldStatusReleased || released.AmountCaptured != 0 {
+		t.Errorf("released hold status %s amount_captured %d", released.Status, released.AmountCaptured)
+	}
+	if account.Balance != 1000 || account.Available() != 1000 {
+		t.Errorf("account balance %d available %d, want 1000 1000", account.Balance, account.Available())
+	}
+}
+
+func TestExpireMockHolds(t *testing.T) {
+	store := holdStore(t)
+	hold := createHold(t, store, 600)
+	other := createHold(t, store, 300)
+	if _, _, err := ReleaseMockHold(store, other.ID); err != nil {
+		t.Fatalf("ReleaseMockHold: %v", err)
+	}
+	expiresAt := time.Unix(hold.ExpiresAt, 0)
+
+	if n, err := ExpireMockHolds(store, expiresAt.Add(-time.Second)); err != nil || n != 0 {
+		t.Fatalf("ExpireMockHolds before expiry = %d, %v, want 0", n, err)
+	}
+	wantAccount(t, store, 1000, 600)
+
+	n, err := ExpireMockHolds(store, expiresAt)
+	if err != nil || n != 1 {
+		t.Fatalf("ExpireMockHolds at expiry = %d, %v, want 1", n, err)
+	}
+	if got := GetMockHold(store, hold.ID); got.Status != types.HoldStatusExpired {
+		t.Errorf("hold status = %s, want expired", got.Status)
+	}
+	if got := GetMockHold(store, other.ID); got.Status != types.HoldStatusReleased {
+		t.Errorf("released hold status = %s, want released", got.Status)
+	}
+	wantAccount(t, store, 1000, 0)
+
+	if n, err := ExpireMockHolds(store, expiresAt.Add(time.Hour)); err != nil || n != 0 {
+		t.Errorf("second ExpireMockHolds = %d, %v, want 0", n, err)
+	}
+	_, _, err = CaptureMockHold(store, hold.ID, nil)
+	wantDataError(t, err, ErrorKindConflict, CodeHoldUnexpectedState)
+}
+
+func TestCaptureAfterExpiryExpiresHold(t *testing.T) {
+	store := holdStore(t)
+	hold := createHold(t, store, 600)
+	_ = store.Update(func(tx Tx) error {
+		tx.Hold(hold.ID).ExpiresAt = time.Now().Add(-time.Minute).Unix()
+		return nil
+	})
+	_, _, err := CaptureMockHold(store, hold.ID, nil)
+	wantDataError(t, err, ErrorKindConflict, CodeHoldUnexpectedState)
+	if got := GetMockHold(store, hold.ID); got.Status != types.HoldStatusExpired {
+		t.Errorf("hold status = %s, want expired", got.Status)
+	}
+	wantAccount(t, store, 1000, 0)
+}
+
+// TestHoldsPostLedgerEntries checks that holds and their releases are posted
+// to the ledger, so that the ledger and available balances of an account can
+// both be reconciled with its transactions.
+func TestHoldsPostLedgerEntries(t *testing.T) {
+	store := holdStore(t)
+	captured := createHold(t, store, 600)
+	released := createHold(t, store, 300)
+	amount := types.Amount(250)
+	if _, _, err := CaptureMockHold(store, captured.ID, &amount); err != nil {
+		t.Fatalf("CaptureMockHold: %v", err)
+	}
+	if _, _, err := ReleaseMockHold(store, released.ID); err != nil {
+		t.Fatalf("ReleaseMockHold: %v", err)
+	}
+	open := createHold(t, store, 100)
+
+	account := GetAccount(store, holdCustomer, types.PaymentTypeMeowthWallet)
+	if account.Balance != 750 || account.Available() != 650 {
+		t.Fatalf("account ledger %d available %d, want 750 and 650", account.Balance, account.Available())
+	}
+	txns, _, err := ListTransactions(store, TransactionFilter{CustomerID: holdCustomer, AccountType: types.PaymentTypeMeowthWallet}, ListParams{Limit: MaxListLimit})
+	if err != nil {
+		t.Fatalf("ListTransactions: %v", err)
+	}
+	// Replay oldest first: the balance starts at the adjustment to 1000.
+	var ledger, held types.Amount
+	for i := len(txns) - 1; i >= 0; i-- {
+		txn := txns[i]
+		own := txn.Entries[0]
+		switch txn.Kind {
+		case types.TransactionKindAdjustment:
+			ledger = *own.BalanceAfter
+		case types.TransactionKindPayment:
+			ledger -= txn.Amount
+		case types.TransactionKindHold:
+			held += txn.Amount
+		case types.TransactionKindRelease:
+			held -= txn.Amount
+		}
+		if txn.Kind == types.TransactionKindHold || txn.Kind == types.TransactionKindRelease {
+			if want := "customer:" + holdCustomer + ":meowth-wallet:available"; own.Account != want {
+				t.Errorf("%s %s: entry account %q, want %q", txn.Kind, txn.ID, own.Account, want)
+			}
+			if *own.BalanceAfter != ledger-held {
+				t.Errorf("%s %s: balance_after %d, want the available %d", txn.Kind, txn.ID, *own.BalanceAfter, ledger-held)
+			}
+		}
+	}
+	if ledger != account.Balance || held != account.Held || held != open.Amount {
+		t.Errorf("replayed ledger %d held %d, want %d and %d", ledger, held, account.Balance, account.Held)
+	}
+}
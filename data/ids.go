@@ -0,0 +1,92 @@
+package data
+
+import (
+	crand "crypto/rand"
+	"fmt"
+	"math/rand"
+	"sync"
+)
+
+// idAlphabet is the character set of generated IDs.
+const idAlphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// idTokenLength is the length of the random part of generated IDs, matching
+// Stripe's IDs such as "pi_3MtwBwLkdIwHu7ix28a3tqPa".
+const idTokenLength = 24
+
+// maxIDAttempts bounds how often a colliding ID is regenerated before a
+// generator is considered broken.
+const maxIDAttempts = 10
+
+// IDGenerator produces object IDs. Uniqueness is checked by the store, which
+// asks for another ID when one collides.
+type IDGenerator interface {
+	// NewID returns an ID of the form "<prefix>_<token>", e.g. "cus_...".
+	NewID(prefix string) string
+}
+
+// NewRandomIDGenerator returns a generator of cryptographically random
+// Stripe-like IDs. It is the default of every MemoryStore.
+func NewRandomIDGenerator() IDGenerator {
+	return randomIDGenerator{}
+}
+
+type randomIDGenerator struct{}
+
+// maxUnbiasedByte is the number of byte values that map evenly onto
+// idAlphabet; larger bytes are discarded so every character is equally likely.
+const maxUnbiasedByte = 256 - 256%len(idAlphabet)
+
+func (randomIDGenerator) NewID(prefix string) string {
+	token := make([]byte, 0, idTokenLength)
+	buf := make([]byte, idTokenLength)
+	for len(token) < idTokenLength {
+		if _, err := crand.Read(buf); err != nil {
+			panic(fmt.Sprintf("data: read random ID: %v", err))
+		}
+		for _, b := range buf {
+			if int(b) < maxUnbiasedByte && len(token) < idTokenLength {
+				token = append(token, idAlphabet[int(b)%len(idAlphabet)])
+			}
+		}
+	}
+	return prefix + "_" + string(token)
+}
+
+// ResettableIDGenerator is an IDGenerator that can restart its sequence.
+// A store resets its generator when it is reset to its fixtures, so the IDs
+// after a reset match the IDs after a restart.
+type ResettableIDGenerator interface {
+	IDGenerator
+	Reset()
+}
+
+// NewSeededIDGenerator returns a generator whose IDs are determined by seed:
+// two stores given the same seed and the same sequence of requests produce
+// the same IDs, which keeps golden test output stable.
+func NewSeededIDGenerator(seed int64) ResettableIDGenerator {
+	return &seededIDGenerator{seed: seed, rng: rand.New(rand.NewSource(seed))}
+}
+
+type seededIDGenerator struct {
+	mu   sync.Mutex
+	seed int64
+	rng  *rand.Rand
+}
+
+// Reset restarts the sequence from the generator's seed.
+func (g *seededIDGenerator) Reset() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.rng = rand.New(rand.NewSource(g.seed))
+}
+
+func (g *seededIDGenerator) NewID(prefix string) string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	b := make([]byte, idTokenLength)
+	for i := range b {
+		b[i] = idAlphabet[g.rng.Intn(len(idAlphabet))]
+	}
+	return prefix + "_" + string(b)
+}
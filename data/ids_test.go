@@ -0,0 +1,132 @@
+package data
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRandomIDGenerator(t *testing.T) {
+	ids := NewRandomIDGenerator()
+	seen := make(map[string]bool)
+	for range 1000 {
+		id := ids.NewID("cus")
+		token, ok := strings.CutPrefix(id, "cus_")
+		if !ok || len(token) != idTokenLength {
+			t.Fatalf("NewID = %q, want cus_ and %d characters", id, idTokenLength)
+		}
+		if i := strings.IndexFunc(token, func(r rune) bool { return !strings.ContainsRune(idAlphabet, r) }); i >= 0 {
+			t.Fatalf("NewID = %q has a character outside the alphabet", id)
+		}
+		if seen[id] {
+			t.Fatalf("NewID repeated %s", id)
+		}
+		seen[id] = true
+	}
+}
+
+func TestSeededIDGeneratorReset(t *testing.T) {
+	a, b := NewSeededIDGenerator(42), NewSeededIDGenerator(42)
+	first := []string{a.NewID("cus"), a.NewID("pi")}
+	if got := []string{b.NewID("cus"), b.NewID("pi")}; got[0] != first[0] || got[1] != first[1] {
+		t.Fatalf("same seed produced %v and %v", first, got)
+	}
+	a.Reset()
+	if got := a.NewID("cus"); got != first[0] {
+		t.Errorf("NewID after Reset = %s, want %s", got, first[0])
+	}
+	if other := NewSeededIDGenerator(43).NewID("cus"); other == first[0] {
+		t.Errorf("seeds 42 and 43 produced the same ID %s", other)
+	}
+}
+
+// sequenceIDGenerator returns its IDs in order, then repeats the last one.
+type sequenceIDGenerator struct {
+	ids []string
+}
+
+func (g *sequenceIDGenerator) NewID(string) string {
+	id := g.ids[0]
+	if len(g.ids) > 1 {
+		g.ids = g.ids[1:]
+	}
+	return id
+}
+
+func TestNewIDSkipsLiveObjects(t *testing.T) {
+	store := NewEmptyMemoryStore()
+	store.SetIDGenerator(&sequenceIDGenerator{ids: []string{"we_a", "we_a", "we_b"}})
+	first, err := CreateWebhookEndpoint(store, "http://example.com/a", nil, "whsec_test")
+	if err != nil {
+		t.Fatalf("CreateWebhookEndpoint: %v", err)
+	}
+	second, err := CreateWebhookEndpoint(store, "http://example.com/b", nil, "whsec_test")
+	if err != nil {
+		t.Fatalf("CreateWebhookEndpoint: %v", err)
+	}
+	if first.ID != "we_a" || second.ID != "we_b" {
+		t.Errorf("IDs = %s, %s, want we_a, we_b", first.ID, second.ID)
+	}
+
+	// Once the object is gone its ID is no longer in use.
+	if err := DeleteWebhookEndpoint(store, first.ID); err != nil {
+		t.Fatalf("DeleteWebhookEndpoint: %v", err)
+	}
+	store.SetIDGenerator(&sequenceIDGenerator{ids: []string{"we_a"}})
+	third, err := CreateWebhookEndpoint(store, "http://example.com/c", nil, "whsec_test")
+	if err != nil {
+		t.Fatalf("CreateWebhookEndpoint: %v", err)
+	}
+	if third.ID != "we_a" {
+		t.Errorf("ID after delete = %s, want we_a", third.ID)
+	}
+}
+
+func TestResetStoreRestartsIDs(t *testing.T) {
+	store := NewMemoryStore()
+	store.SetIDGenerator(NewSeededIDGenerator(7))
+	fixtures := TakeSnapshot(store)
+
+	first, err := CreateMockCustomer(store, "Mia", "mia@example.com")
+	if err != nil {
+		t.Fatalf("CreateMockCustomer: %v", err)
+	}
+	if err := ResetStore(store, fixtures); err != nil {
+		t.Fatalf("ResetStore: %v", err)
+	}
+	if GetMockCustomer(store, first.ID) != nil {
+		t.Fatalf("customer %s survived the reset", first.ID)
+	}
+	again, err := CreateMockCustomer(store, "Mia", "mia@example.com")
+	if err != nil {
+		t.Fatalf("CreateMockCustomer: %v", err)
+	}
+	if again.ID != first.ID {
+		t.Errorf("customer ID after reset = %s, want %s", again.ID, first.ID)
+	}
+
+	// Restoring a snapshot keeps the sequence going.
+	if err := RestoreSnapshot(store, fixtures); err != nil {
+		t.Fatalf("RestoreSnapshot: %v", err)
+	}
+	restored, err := CreateMockCustomer(store, "Mia", "mia@example.com")
+	if err != nil {
+		t.Fatalf("CreateMockCustomer: %v", err)
+	}
+	if restored.ID == first.ID {
+		t.Errorf("RestoreSnapshot restarted the ID sequence")
+	}
+}
+
+func TestNewIDFailsWhenExhausted(t *testing.T) {
+	store := NewEmptyMemoryStore()
+	store.SetIDGenerator(&sequenceIDGenerator{ids: []string{"we_a"}})
+	if _, err := CreateWebhookEndpoint(store, "http://example.com/a", nil, "whsec_test"); err != nil {
+		t.Fatalf("CreateWebhookEndpoint: %v", err)
+	}
+	if _, err := CreateWebhookEndpoint(store, "http://example.com/b", nil, "whsec_test"); err == nil {
+		t.Fatal("CreateWebhookEndpoint succeeded without a unique ID")
+	}
+	if endpoints := ListWebhookEndpoints(store); len(endpoints) != 1 {
+		t.Errorf("store has %d webhook endpoints, want 1", len(endpoints))
+	}
+}
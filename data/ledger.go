@@ -0,0 +1,131 @@
+package data
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/nerdgarten/mock-payment-service/types"
+)
+
+// TransactionFilter narrows a ledger listing. Zero fields match everything;
+// CreatedGTE and CreatedLTE are inclusive Unix timestamps.
+type TransactionFilter struct {
+	CustomerID  string
+	AccountType types.PaymentType
+	Kind        types.TransactionKind
+	CreatedGTE  int64
+	CreatedLTE  int64
+}
+
+func (f TransactionFilter) matches(txn *types.Transaction) bool {
+	switch {
+	case f.CustomerID != "" && txn.CustomerID != f.CustomerID:
+		return false
+	case f.AccountType != "" && txn.AccountType != f.AccountType:
+		return false
+	case f.Kind != "" && txn.Kind != f.Kind:
+		return false
+	case f.CreatedGTE != 0 && txn.Created < f.CreatedGTE:
+		return false
+	case f.CreatedLTE != 0 && txn.Created > f.CreatedLTE:
+		return false
+	}
+	return true
+}
+
+// recordTransaction writes a balance movement on account to the ledger. The
+// account's balance and held amount must already reflect the movement.
+// Deposits and refunds credit the customer account; withdrawals and payments
+// debit it. Adjustments credit a positive amount and debit a negative one.
+// Holds and releases move funds between the account's available and held
+// sub-accounts, leaving its ledger balance unchanged.
+func recordTransaction(tx Tx, kind types.TransactionKind, account *types.Account, amount types.Amount, reference, orderID string) *types.Transaction {
+	customerSide, counterSide := types.EntryDirectionCredit, types.EntryDirectionDebit
+	if kind == types.TransactionKindWithdrawal || kind == types.TransactionKindPayment || kind == types.TransactionKindHold ||
+		(kind == types.TransactionKindAdjustment && amount < 0) {
+		customerSide, counterSide = types.EntryDirectionDebit, types.EntryDirectionCredit
+	}
+	if amount < 0 {
+		amount = -amount
+	}
+	customerAccount := fmt.Sprintf("customer:%s:%s", account.CustomerID, account.Type)
+	counterAccount := fmt.Sprintf("external:%s", account.Type)
+	balance := account.Balance
+	switch kind {
+	case types.TransactionKindPayment, types.TransactionKindRefund:
+		counterAccount = fmt.Sprintf("merchant:%s", account.Type)
+	case types.TransactionKindAdjustment:
+		counterAccount = fmt.Sprintf("adjustment:%s", account.Type)
+	case types.TransactionKindHold, types.TransactionKindRelease:
+		counterAccount = customerAccount + ":held"
+		customerAccount += ":available"
+		balance = account.Available()
+	}
+	txn := &types.Transaction{
+		ID:          tx.NewID("txn"),
+		Object:      "transaction",
+		Kind:        kind,
+		CustomerID:  account.CustomerID,
+		AccountType: account.Type,
+		Amount:      amount,
+		Reference:   reference,
+		OrderID:     orderID,
+		Created:     time.Now().Unix(),
+		Entries: []types.LedgerEntry{
+			{
+				Account:      customerAccount,
+				Direction:    customerSide,
+				Amount:       amount,
+				BalanceAfter: &balance,
+			},
+			{
+				Account:   counterAccount,
+				Direction: counterSide,
+				Amount:    amount,
+			},
+		},
+	}
+	tx.AppendTransaction(txn)
+	return txn
+}
+
+// GetTransaction retrieves a ledger transaction by ID
+func GetTransaction(store Store, id string) *types.Transaction {
+	var out *types.Transaction
+	_ = store.View(func(tx ReadTx) error {
+		if txn := tx.Transaction(id); txn != nil {
+			t := *txn
+			out = &t
+		}
+		return nil
+	})
+	return out
+}
+
+// ListTransactions returns a page of ledger transactions matching filter,
+// newest first, and whether more transactions follow.
+func ListTransactions(store Store, filter TransactionFilter, params ListParams) ([]types.Transaction, bool, error) {
+	var (
+		page    []types.Transaction
+		hasMore bool
+	)
+	err := store.View(func(tx ReadTx) error {
+		ledger := slices.Clone(tx.Transactions())
+		slices.Reverse(ledger)
+		matched, more, err := paginate(ledger, func(t *types.Transaction) string { return t.ID }, filter.matches, params)
+		if err != nil {
+			return err
+		}
+		page = make([]types.Transaction, 0, len(matched))
+		for _, txn := range matched {
+			page = append(page, *txn)
+		}
+		hasMore = more
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return page, hasMore, nil
+}
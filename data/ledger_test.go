@@ -0,0 +1,94 @@
+package data
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/nerdgarten/mock-payment-service/types"
+)
+
+// TestLedgerEntriesBalance replays every transaction written by the balance
+// operations and checks that each has a customer leg and a counter leg of the
+// same amount in opposite directions, with a running balance_after.
+func TestLedgerEntriesBalance(t *testing.T) {
+	store := NewEmptyMemoryStore()
+	customer, err := CreateMockCustomer(store, "Ruff", "ruff@example.com")
+	if err != nil {
+		t.Fatalf("CreateMockCustomer: %v", err)
+	}
+	money := func(amount types.Amount) types.Money {
+		return types.Money{Amount: amount, Currency: types.DefaultCurrency}
+	}
+	steps := []struct {
+		name string
+		run  func() error
+	}{
+		{"deposit", func() error { _, err := Deposit(store, customer.ID, types.PaymentTypeCash, money(2500)); return err }},
+		{"withdraw", func() error { _, err := Withdraw(store, customer.ID, types.PaymentTypeCash, money(700)); return err }},
+		{"payment", func() error {
+			_, err := ProcessPayment(store, customer.ID, types.PaymentTypeCash, money(1200), "order-1", "")
+			return err
+		}},
+		{"refund", func() error {
+			_, err := Refund(store, customer.ID, types.PaymentTypeCash, money(300), "order-1")
+			return err
+		}},
+		{"adjust down", func() error { _, err := SetAccountBalance(store, customer.ID, types.PaymentTypeCash, 1000); return err }},
+		{"adjust up", func() error { _, err := SetAccountBalance(store, customer.ID, types.PaymentTypeCash, 4000); return err }},
+	}
+	for _, step := range steps {
+		if err := step.run(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+	}
+
+	counterparties := map[types.TransactionKind]string{
+		types.TransactionKindDeposit:    "external",
+		types.TransactionKindWithdrawal: "external",
+		types.TransactionKindPayment:    "merchant",
+		types.TransactionKindRefund:     "merchant",
+		types.TransactionKindAdjustment: "adjustment",
+	}
+	customerAccount := fmt.Sprintf("customer:%s:%s", customer.ID, types.PaymentTypeCash)
+	balance := DefaultAccountBalances[types.PaymentTypeCash]
+	var ledger []*types.Transaction
+	_ = store.View(func(tx ReadTx) error {
+		ledger = tx.Transactions()
+		return nil
+	})
+	if len(ledger) != len(steps) {
+		t.Fatalf("ledger has %d transactions, want %d", len(ledger), len(steps))
+	}
+	for _, txn := range ledger {
+		if len(txn.Entries) != 2 {
+			t.Fatalf("%s %s has %d entries, want 2", txn.Kind, txn.ID, len(txn.Entries))
+		}
+		own, counter := txn.Entries[0], txn.Entries[1]
+		if own.Amount != txn.Amount || counter.Amount != txn.Amount || txn.Amount <= 0 {
+			t.Errorf("%s %s: leg amounts %d and %d, transaction amount %d", txn.Kind, txn.ID, own.Amount, counter.Amount, txn.Amount)
+		}
+		if own.Direction == counter.Direction {
+			t.Errorf("%s %s: both legs are %s", txn.Kind, txn.ID, own.Direction)
+		}
+		if own.Account != customerAccount {
+			t.Errorf("%s %s: customer leg account %q, want %q", txn.Kind, txn.ID, own.Account, customerAccount)
+		}
+		if want := counterparties[txn.Kind] + ":" + string(types.PaymentTypeCash); counter.Account != want {
+			t.Errorf("%s %s: counter leg account %q, want %q", txn.Kind, txn.ID, counter.Account, want)
+		}
+		if counter.BalanceAfter != nil {
+			t.Errorf("%s %s: counter leg has a balance_after", txn.Kind, txn.ID)
+		}
+		if own.Direction == types.EntryDirectionCredit {
+			balance += own.Amount
+		} else {
+			balance -= own.Amount
+		}
+		if own.BalanceAfter == nil || *own.BalanceAfter != balance {
+			t.Errorf("%s %s: balance_after = %v, want %d", txn.Kind, txn.ID, own.BalanceAfter, balance)
+		}
+	}
+	if account := GetAccount(store, customer.ID, types.PaymentTypeCash); account.Balance != balance {
+		t.Errorf("account balance %d does not match the replayed ledger %d", account.Balance, balance)
+	}
+}
@@ -0,0 +1,56 @@
+package data
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/nerdgarten/mock-payment-service/types"
+)
+
+// paymentIntentTransitions lists the statuses reachable from each PaymentIntent
+// status. Succeeded and canceled intents are terminal.
+var paymentIntentTransitions = map[types.PaymentIntentStatus][]types.PaymentIntentStatus{
+	types.PaymentIntentStatusRequiresPaymentMethod: {
+		types.PaymentIntentStatusRequiresConfirmation,
+		types.PaymentIntentStatusCanceled,
+	},
+	types.PaymentIntentStatusRequiresConfirmation: {
+		types.PaymentIntentStatusRequiresPaymentMethod,
+		types.PaymentIntentStatusRequiresAction,
+		types.PaymentIntentStatusProcessing,
+		types.PaymentIntentStatusCanceled,
+	},
+	types.PaymentIntentStatusRequiresAction: {
+		types.PaymentIntentStatusRequiresPaymentMethod,
+		types.PaymentIntentStatusProcessing,
+		types.PaymentIntentStatusCanceled,
+	},
+	types.PaymentIntentStatusProcessing: {
+		types.PaymentIntentStatusRequiresPaymentMethod,
+		types.PaymentIntentStatusRequiresCapture,
+		types.PaymentIntentStatusSucceeded,
+	},
+	types.PaymentIntentStatusRequiresCapture: {
+		types.PaymentIntentStatusSucceeded,
+		types.PaymentIntentStatusCanceled,
+	},
+}
+
+// CanTransitionPaymentIntent reports whether an intent may move from one status to another.
+func CanTransitionPaymentIntent(from, to types.PaymentIntentStatus) bool {
+	return slices.Contains(paymentIntentTransitions[from], to)
+}
+
+// transitionPaymentIntent moves intent to status, rejecting illegal transitions
+// with a conflict error that names the attempted action.
+func transitionPaymentIntent(intent *types.PaymentIntent, action string, status types.PaymentIntentStatus) error {
+	if !CanTransitionPaymentIntent(intent.Status, status) {
+		return &Error{
+			Kind:    ErrorKindConflict,
+			Code:    CodePaymentIntentUnexpectedState,
+			Message: fmt.Sprintf("cannot %s payment intent %s with status %s", action, intent.ID, intent.Status),
+		}
+	}
+	intent.Status = status
+	return nil
+}
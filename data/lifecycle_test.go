@@ -0,0 +1,119 @@
+package data
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/nerdgarten/mock-payment-service/types"
+)
+
+func TestCanTransitionPaymentIntent(t *testing.T) {
+	allowed := map[types.PaymentIntentStatus][]types.PaymentIntentStatus{
+		types.PaymentIntentStatusRequiresPaymentMethod: {
+			types.PaymentIntentStatusRequiresConfirmation,
+			types.PaymentIntentStatusCanceled,
+		},
+		types.PaymentIntentStatusRequiresConfirmation: {
+			types.PaymentIntentStatusRequiresPaymentMethod,
+			types.PaymentIntentStatusRequiresAction,
+			types.PaymentIntentStatusProcessing,
+			types.PaymentIntentStatusCanceled,
+		},
+		types.PaymentIntentStatusRequiresAction: {
+			types.PaymentIntentStatusRequiresPaymentMethod,
+			types.PaymentIntentStatusProcessing,
+			types.PaymentIntentStatusCanceled,
+		},
+		types.PaymentIntentStatusProcessing: {
+			types.PaymentIntentStatusRequiresPaymentMethod,
+			types.PaymentIntentStatusRequiresCapture,
+			types.PaymentIntentStatusSucceeded,
+		},
+		types.PaymentIntentStatusRequiresCapture: {
+			types.PaymentIntentStatusSucceeded,
+			types.PaymentIntentStatusCanceled,
+		},
+		types.PaymentIntentStatusSucceeded: nil,
+		types.PaymentIntentStatusCanceled:  nil,
+	}
+	for _, from := range types.PaymentIntentStatuses {
+		for _, to := range types.PaymentIntentStatuses {
+			want := slices.Contains(allowed[from], to)
+			if got := CanTransitionPaymentIntent(from, to); got != want {
+				t.Errorf("CanTransitionPaymentIntent(%s, %s) = %t, want %t", from, to, got, want)
+			}
+		}
+	}
+}
+
+func TestTransitionPaymentIntentRejectsIllegalTransitions(t *testing.T) {
+	tests := []struct {
+		from   types.PaymentIntentStatus
+		action string
+		to     types.PaymentIntentStatus
+	}{
+		{types.PaymentIntentStatusSucceeded, "confirm", types.PaymentIntentStatusProcessing},
+		{types.PaymentIntentStatusSucceeded, "cancel", types.PaymentIntentStatusCanceled},
+		{types.PaymentIntentStatusCanceled, "confirm", types.PaymentIntentStatusProcessing},
+		{types.PaymentIntentStatusRequiresConfirmation, "capture", types.PaymentIntentStatusSucceeded},
+		{types.PaymentIntentStatusProcessing, "cancel", types.PaymentIntentStatusCanceled},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.from)+"/"+tt.action, func(t *testing.T) {
+			intent := &types.PaymentIntent{ID: "pi_test", Status: tt.from}
+			err := transitionPaymentIntent(intent, tt.action, tt.to)
+			var dataErr *Error
+			if !errors.As(err, &dataErr) {
+				t.Fatalf("transitionPaymentIntent error = %v, want *Error", err)
+			}
+			if dataErr.Kind != ErrorKindConflict || dataErr.Code != CodePaymentIntentUnexpectedState {
+				t.Errorf("error kind %d code %q, want conflict %q", dataErr.Kind, dataErr.Code, CodePaymentIntentUnexpectedState)
+			}
+			if intent.Status != tt.from {
+				t.Errorf("status changed to %s on a rejected transition", intent.Status)
+			}
+		})
+	}
+}
+
+func TestPaymentIntentLifecycle(t *testing.T) {
+	store := NewEmptyMemoryStore()
+	intent, err := CreateMockPaymentIntent(store, types.Money{Amount: 1200, Currency: "thb"}, "", "", "", "")
+	if err != nil {
+		t.Fatalf("CreateMockPaymentIntent: %v", err)
+	}
+	if intent.Status != types.PaymentIntentStatusRequiresPaymentMethod {
+		t.Fatalf("new intent status = %s, want requires_payment_method", intent.Status)
+	}
+	intent, _, err = ConfirmMockPaymentIntent(store, intent.ID, ConfirmParams{PaymentMethod: "pm_card_visa"})
+	if err != nil {
+		t.Fatalf("ConfirmMockPaymentIntent: %v", err)
+	}
+	if intent.Status != types.PaymentIntentStatusSucceeded {
+		t.Fatalf("confirmed intent status = %s, want succeeded", intent.Status)
+	}
+
+	for name, call := range map[string]func() error{
+		"confirm": func() error {
+			_, _, err := ConfirmMockPaymentIntent(store, intent.ID, ConfirmParams{})
+			return err
+		},
+		"cancel": func() error {
+			_, err := CancelMockPaymentIntent(store, intent.ID, "")
+			return err
+		},
+		"capture": func() error {
+			_, _, err := CaptureMockPaymentIntent(store, intent.ID, nil)
+			return err
+		},
+	} {
+		var dataErr *Error
+		if err := call(); !errors.As(err, &dataErr) || dataErr.Kind != ErrorKindConflict || dataErr.Code != CodePaymentIntentUnexpectedState {
+			t.Errorf("%s succeeded intent: error = %v, want %s conflict", name, err, CodePaymentIntentUnexpectedState)
+		}
+	}
+	if got := GetMockPaymentIntent(store, intent.ID); got.Status != types.PaymentIntentStatusSucceeded {
+		t.Errorf("status after rejected actions = %s, want succeeded", got.Status)
+	}
+}
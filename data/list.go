@@ -0,0 +1,325 @@
+package data
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/nerdgarten/mock-payment-service/types"
+)
+
+// Pagination limits shared by every list endpoint.
+const (
+	DefaultListLimit = 10
+	MaxListLimit     = 100
+)
+
+// ListParams selects a page of a list ordered newest first. StartingAfter and
+// EndingBefore are object IDs acting as cursors; at most one may be set. A zero
+// Limit selects DefaultListLimit.
+type ListParams struct {
+	Limit         int
+	StartingAfter string
+	EndingBefore  string
+}
+
+// paginate returns the page of items matching match, ordered newest first,
+// selected by params, and whether more matching items follow in the direction
+// of travel. Cursors are located among all items, so a cursor object does not
+// have to match the filter itself.
+func paginate[T any](items []T, id func(T) string, match func(T) bool, params ListParams) ([]T, bool, error) {
+	limit := params.Limit
+	if limit == 0 {
+		limit = DefaultListLimit
+	}
+	if limit < 1 || limit > MaxListLimit {
+		return nil, false, &Error{
+			Kind:    ErrorKindInvalid,
+			Code:    CodeParameterInvalid,
+			Message: fmt.Sprintf("limit must be between 1 and %d", MaxListLimit),
+		}
+	}
+	if params.StartingAfter != "" && params.EndingBefore != "" {
+		return nil, false, &Error{
+			Kind:    ErrorKindInvalid,
+			Code:    CodeParameterInvalid,
+			Message: "starting_after and ending_before cannot be combined",
+		}
+	}
+
+	indexOf := func(cursor, param string) (int, error) {
+		for i, item := range items {
+			if id(item) == cursor {
+				return i, nil
+			}
+		}
+		return 0, &Error{
+			Kind:    ErrorKindInvalid,
+			Code:    CodeResourceMissing,
+			Message: fmt.Sprintf("%s object not found: %s", param, cursor),
+		}
+	}
+
+	page := []T{}
+	if params.EndingBefore != "" {
+		end, err := indexOf(params.EndingBefore, "ending_before")
+		if err != nil {
+			return nil, false, err
+		}
+		for i := end - 1; i >= 0; i-- {
+			if !match(items[i]) {
+				continue
+			}
+			if len(page) == limit {
+				slices.Reverse(page)
+				return page, true, nil
+			}
+			page = append(page, items[i])
+		}
+		slices.Reverse(page)
+		return page, false, nil
+	}
+
+	start := 0
+	if params.StartingAfter != "" {
+		i, err := indexOf(params.StartingAfter, "starting_after")
+		if err != nil {
+			return nil, false, err
+		}
+		start = i + 1
+	}
+	for _, item := range items[start:] {
+		if !match(item) {
+			continue
+		}
+		if len(page) == limit {
+			return page, true, nil
+		}
+		page = append(page, item)
+	}
+	return page, false, nil
+}
+
+// listPage pages through items, given in creation order, newest first and
+// copies the page out of the transaction.
+func listPage[T any](items []*T, id func(*T) string, match func(*T) bool, params ListParams) ([]T, bool, error) {
+	items = slices.Clone(items)
+	slices.Reverse(items)
+	matched, hasMore, err := paginate(items, id, match, params)
+	if err != nil {
+		return nil, false, err
+	}
+	page := make([]T, 0, len(matched))
+	for _, item := range matched {
+		page = append(page, *item)
+	}
+	return page, hasMore, nil
+}
+
+// createdBetween reports whether created lies within the inclusive bounds;
+// zero bounds are open.
+func createdBetween(created, gte, lte int64) bool {
+	return (gte == 0 || created >= gte) && (lte == 0 || created <= lte)
+}
+
+// CustomerFilter narrows a customer listing. Zero fields match everything;
+// CreatedGTE and CreatedLTE are inclusive Unix timestamps.
+type CustomerFilter struct {
+	Email      string
+	CreatedGTE int64
+	CreatedLTE int64
+}
+
+func (f CustomerFilter) matches(customer *types.Customer) bool {
+	return !customer.Deleted && (f.Email == "" || strings.EqualFold(customer.Email, f.Email)) &&
+		createdBetween(customer.Created, f.CreatedGTE, f.CreatedLTE)
+}
+
+// ListMockCustomers returns a page of customers, newest first, and whether
+// more customers follow. Deleted customers are left out.
+func ListMockCustomers(store Store, filter CustomerFilter, params ListParams) ([]types.Customer, bool, error) {
+	var (
+		page    []types.Customer
+		hasMore bool
+	)
+	err := store.View(func(tx ReadTx) error {
+		var err error
+		page, hasMore, err = listPage(tx.Customers(), func(c *types.Customer) string { return c.ID }, filter.matches, params)
+		return err
+	})
+	return page, hasMore, err
+}
+
+// PaymentIntentFilter narrows a payment intent listing. Zero fields match
+// everything; CreatedGTE and CreatedLTE are inclusive Unix timestamps.
+type PaymentIntentFilter struct {
+	Customer   string
+	Status     types.PaymentIntentStatus
+	CreatedGTE int64
+	CreatedLTE int64
+}
+
+func (f PaymentIntentFilter) matches(intent *types.PaymentIntent) bool {
+	return (f.Status == "" || intent.Status == f.Status) &&
+		createdBetween(intent.Created, f.CreatedGTE, f.CreatedLTE)
+}
+
+// ListMockPaymentIntents returns a page of payment intents, newest first, and
+// whether more intents follow.
+func ListMockPaymentIntents(store Store, filter PaymentIntentFilter, params ListParams) ([]types.PaymentIntent, bool, error) {
+	if filter.Status != "" && !slices.Contains(types.PaymentIntentStatuses, filter.Status) {
+		return nil, false, &Error{Kind: ErrorKindInvalid, Code: CodeParameterInvalid, Param: "status", Message: fmt.Sprintf("unknown status %q", filter.Status)}
+	}
+	var (
+		page    []types.PaymentIntent
+		hasMore bool
+	)
+	err := store.View(func(tx ReadTx) error {
+		intents := tx.PaymentIntents()
+		if filter.Customer != "" {
+			intents = tx.PaymentIntentsByCustomer(filter.Customer)
+		}
+		var err error
+		page, hasMore, err = listPage(intents, func(pi *types.PaymentIntent) string { return pi.ID }, filter.matches, params)
+		return err
+	})
+	return page, hasMore, err
+}
+
+// ChargeFilter narrows a charge listing. Zero fields match everything;
+// CreatedGTE and CreatedLTE are inclusive Unix timestamps.
+type ChargeFilter struct {
+	Customer      string
+	PaymentIntent string
+	Status        string
+	CreatedGTE    int64
+	CreatedLTE    int64
+}
+
+func (f ChargeFilter) matches(charge *types.Charge) bool {
+	return (f.PaymentIntent == "" || charge.PaymentIntent == f.PaymentIntent) &&
+		(f.Status == "" || charge.Status == f.Status) &&
+		createdBetween(charge.Created, f.CreatedGTE, f.CreatedLTE)
+}
+
+// ListMockCharges returns a page of charges, newest first, and whether more
+// charges follow.
+func ListMockCharges(store Store, filter ChargeFilter, params ListParams) ([]types.Charge, bool, error) {
+	var (
+		page    []types.Charge
+		hasMore bool
+	)
+	err := store.View(func(tx ReadTx) error {
+		charges := tx.Charges()
+		if filter.Customer != "" {
+			charges = tx.ChargesByCustomer(filter.Customer)
+		}
+		var err error
+		page, hasMore, err = listPage(charges, func(c *types.Charge) string { return c.ID }, filter.matches, params)
+		return err
+	})
+	return page, hasMore, err
+}
+
+// RefundFilter narrows a refund listing. Zero fields match everything;
+// CreatedGTE and CreatedLTE are inclusive Unix timestamps.
+type RefundFilter struct {
+	PaymentIntent string
+	Charge        string
+	Status        string
+	CreatedGTE    int64
+	CreatedLTE    int64
+}
+
+func (f RefundFilter) matches(refund *types.Refund) bool {
+	return (f.Charge == "" || refund.Charge == f.Charge) &&
+		(f.Status == "" || refund.Status == f.Status) &&
+		createdBetween(refund.Created, f.CreatedGTE, f.CreatedLTE)
+}
+
+// ListMockRefunds returns a page of refunds, newest first, and whether more
+// refunds follow.
+func ListMockRefunds(store Store, filter RefundFilter, params ListParams) ([]types.Refund, bool, error) {
+	var (
+		page    []types.Refund
+		hasMore bool
+	)
+	err := store.View(func(tx ReadTx) error {
+		refunds := tx.Refunds()
+		if filter.PaymentIntent != "" {
+			refunds = tx.RefundsByPaymentIntent(filter.PaymentIntent)
+		}
+		var err error
+		page, hasMore, err = listPage(refunds, func(r *types.Refund) string { return r.ID }, filter.matches, params)
+		return err
+	})
+	return page, hasMore, err
+}
+
+// PaymentMethodFilter narrows a payment method listing. Zero fields match
+// everything.
+// Fingerprint only matches cards created from the same card number.
+type PaymentMethodFilter struct {
+	Customer    string
+	Type        types.PaymentMethodType
+	Fingerprint string
+}
+
+func (f PaymentMethodFilter) matches(method *types.PaymentMethod) bool {
+	return (f.Customer == "" || method.Customer == f.Customer) &&
+		(f.Type == "" || method.Type == f.Type) &&
+		(f.Fingerprint == "" || method.Card != nil && method.Card.Fingerprint == f.Fingerprint)
+}
+
+// ListMockPaymentMethods returns a page of payment methods, newest first, and
+// whether more payment methods follow.
+func ListMockPaymentMethods(store Store, filter PaymentMethodFilter, params ListParams) ([]types.PaymentMethod, bool, error) {
+	if filter.Type != "" && !slices.Contains(types.PaymentMethodTypes, filter.Type) {
+		return nil, false, &Error{Kind: ErrorKindInvalid, Code: CodeParameterInvalid, Param: "type", Message: fmt.Sprintf("unsupported payment method type %q", filter.Type)}
+	}
+	var (
+		page    []types.PaymentMethod
+		hasMore bool
+	)
+	err := store.View(func(tx ReadTx) error {
+		var err error
+		page, hasMore, err = listPage(tx.PaymentMethods(), func(pm *types.PaymentMethod) string { return pm.ID }, filter.matches, params)
+		return err
+	})
+	return page, hasMore, err
+}
+
+// HoldFilter narrows a hold listing. Zero fields match everything;
+// CreatedGTE and CreatedLTE are inclusive Unix timestamps.
+type HoldFilter struct {
+	CustomerID string
+	Type       types.PaymentType
+	Status     types.HoldStatus
+	CreatedGTE int64
+	CreatedLTE int64
+}
+
+func (f HoldFilter) matches(hold *types.Hold) bool {
+	return (f.CustomerID == "" || hold.CustomerID == f.CustomerID) &&
+		(f.Type == "" || hold.Type == f.Type) &&
+		(f.Status == "" || hold.Status == f.Status) &&
+		createdBetween(hold.Created, f.CreatedGTE, f.CreatedLTE)
+}
+
+// ListMockHolds returns a page of holds, newest first, and whether more holds
+// follow.
+func ListMockHolds(store Store, filter HoldFilter, params ListParams) ([]types.Hold, bool, error) {
+	if filter.Status != "" && !slices.Contains(types.HoldStatuses, filter.Status) {
+		return nil, false, &Error{Kind: ErrorKindInvalid, Code: CodeParameterInvalid, Param: "status", Message: fmt.Sprintf("unknown hold status %q", filter.Status)}
+	}
+	var (
+		page    []types.Hold
+		hasMore bool
+	)
+	err := store.View(func(tx ReadTx) error {
+		var err error
+		page, hasMore, err = listPage(tx.Holds(), func(h *types.Hold) string { return h.ID }, filter.matches, params)
+		return err
+	})
+	return page, hasMore, err
+}
@@ -0,0 +1,63 @@
+package data
+
+import (
+	"slices"
+	"strconv"
+	"testing"
+)
+
+func TestPaginate(t *testing.T) {
+	// Items are newest first; odd numbers are filtered out.
+	items := []int{9, 8, 7, 6, 5, 4, 3, 2, 1}
+	id := func(i int) string { return strconv.Itoa(i) }
+	even := func(i int) bool { return i%2 == 0 }
+	tests := []struct {
+		name    string
+		params  ListParams
+		want    []int
+		hasMore bool
+	}{
+		{"first page", ListParams{Limit: 2}, []int{8, 6}, true},
+		{"default limit", ListParams{}, []int{8, 6, 4, 2}, false},
+		{"starting after", ListParams{Limit: 2, StartingAfter: "6"}, []int{4, 2}, false},
+		{"starting after a filtered cursor", ListParams{Limit: 2, StartingAfter: "7"}, []int{6, 4}, true},
+		{"starting after the last item", ListParams{StartingAfter: "1"}, []int{}, false},
+		{"ending before", ListParams{Limit: 2, EndingBefore: "2"}, []int{6, 4}, true},
+		{"ending before the first page", ListParams{Limit: 2, EndingBefore: "4"}, []int{8, 6}, false},
+		{"ending before the first item", ListParams{EndingBefore: "9"}, []int{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, hasMore, err := paginate(items, id, even, tt.params)
+			if err != nil {
+				t.Fatalf("paginate: %v", err)
+			}
+			if !slices.Equal(page, tt.want) || hasMore != tt.hasMore {
+				t.Errorf("paginate = %v (has_more %t), want %v (has_more %t)", page, hasMore, tt.want, tt.hasMore)
+			}
+		})
+	}
+}
+
+func TestPaginateRejectsInvalidParams(t *testing.T) {
+	items := []int{2, 1}
+	id := func(i int) string { return strconv.Itoa(i) }
+	all := func(int) bool { return true }
+	tests := []struct {
+		name   string
+		params ListParams
+		code   string
+	}{
+		{"negative limit", ListParams{Limit: -1}, CodeParameterInvalid},
+		{"limit too large", ListParams{Limit: MaxListLimit + 1}, CodeParameterInvalid},
+		{"both cursors", ListParams{StartingAfter: "2", EndingBefore: "1"}, CodeParameterInvalid},
+		{"unknown starting_after", ListParams{StartingAfter: "3"}, CodeResourceMissing},
+		{"unknown ending_before", ListParams{EndingBefore: "3"}, CodeResourceMissing},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := paginate(items, id, all, tt.params)
+			wantDataError(t, err, ErrorKindInvalid, tt.code)
+		})
+	}
+}
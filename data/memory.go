@@ -0,0 +1,497 @@
+package data
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/nerdgarten/mock-payment-service/types"
+)
+
+// MemoryStore is a mutex-guarded in-memory Store. Update works on the live
+// datasets and restores a copy taken beforehand when its callback fails.
+type MemoryStore struct {
+	mu sync.RWMutex
+	memoryData
+	ids                 IDGenerator
+	authorizationWindow time.Duration
+	holdTTL             time.Duration
+	subscribers         []func(types.Event)
+}
+
+// memoryData holds the datasets of a MemoryStore.
+type memoryData struct {
+	customers         map[string]*types.Customer
+	customerOrder     []string
+	paymentMethods    map[string]*types.PaymentMethod
+	methodOrder       []string
+	paymentIntents    map[string]*types.PaymentIntent
+	intentOrder       []string
+	intentsByCustomer orderedIndex
+	charges           map[string]*types.Charge
+	chargeOrder       []string
+	chargesByCustomer orderedIndex
+	refunds           map[string]*types.Refund
+	refundOrder       []string
+	refundsByIntent   orderedIndex
+	holds             map[string]*types.Hold
+	holdOrder         []string
+	accounts          map[accountKey]*types.Account
+	webhooks          map[string]*types.WebhookEndpoint
+	webhookOrder      []string
+	transactions      map[string]*types.Transaction
+	ledger            []*types.Transaction
+}
+
+// NewMemoryStore creates a MemoryStore seeded with the mock datasets.
+func NewMemoryStore() *MemoryStore {
+	s := NewEmptyMemoryStore()
+	seedMockData(&memoryTx{s: s})
+	return s
+}
+
+// NewEmptyMemoryStore creates a MemoryStore without any seeded data.
+func NewEmptyMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		memoryData: memoryData{
+			customers:      make(map[string]*types.Customer),
+			paymentMethods: make(map[string]*types.PaymentMethod),
+			paymentIntents: make(map[string]*types.PaymentIntent),
+			charges:        make(map[string]*types.Charge),
+			refunds:        make(map[string]*types.Refund),
+			holds:          make(map[string]*types.Hold),
+			accounts:       make(map[accountKey]*types.Account),
+			webhooks:       make(map[string]*types.WebhookEndpoint),
+			transactions:   make(map[string]*types.Transaction),
+		},
+		ids:                 NewRandomIDGenerator(),
+		authorizationWindow: DefaultAuthorizationWindow,
+		holdTTL:             DefaultHoldTTL,
+	}
+}
+
+// SetIDGenerator replaces the generator of new object IDs, e.g. with
+// NewSeededIDGenerator for reproducible IDs.
+func (s *MemoryStore) SetIDGenerator(ids IDGenerator) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ids = ids
+}
+
+// SetAuthorizationWindow sets how long manual-capture authorizations made
+// from now on stay capturable, e.g. a few seconds to test their expiry.
+func (s *MemoryStore) SetAuthorizationWindow(window time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.authorizationWindow = window
+}
+
+// SetHoldTTL sets how long holds created from now on reserve their funds.
+func (s *MemoryStore) SetHoldTTL(ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.holdTTL = ttl
+}
+
+// View runs fn with shared read access to the store.
+func (s *MemoryStore) View(fn func(tx ReadTx) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return fn(&memoryTx{s: s})
+}
+
+// Update runs fn with exclusive access to the store and then publishes the
+// events it emitted. If fn fails, its writes are rolled back.
+func (s *MemoryStore) Update(fn func(tx Tx) error) error {
+	events, subscribers, err := s.update(fn)
+	if err != nil {
+		return err
+	}
+	for _, event := range events {
+		for _, subscriber := range subscribers {
+			subscriber(event)
+		}
+	}
+	return nil
+}
+
+// update runs fn under the write lock, releasing it even if fn panics. The
+// datasets are restored unless fn returns nil and every NewID succeeded.
+func (s *MemoryStore) update(fn func(tx Tx) error) ([]types.Event, []func(types.Event), error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	saved := s.memoryData.clone()
+	committed := false
+	defer func() {
+		if !committed {
+			s.memoryData = saved
+		}
+	}()
+	tx := &memoryTx{s: s}
+	err := fn(tx)
+	if err == nil {
+		err = tx.err
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	committed = true
+	return tx.events, s.subscribers, nil
+}
+
+// Subscribe registers fn to receive events from committed transactions.
+func (s *MemoryStore) Subscribe(fn func(event types.Event)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subscribers = append(s.subscribers, fn)
+}
+
+// accountKey identifies a customer's account for one payment type.
+type accountKey struct {
+	customerID  string
+	paymentType types.PaymentType
+}
+
+// orderedIndex lists object IDs per key, such as a customer ID, in creation
+// order. Objects are indexed under the key they have when first stored.
+type orderedIndex struct {
+	ids map[string][]string
+}
+
+func (x orderedIndex) clone() orderedIndex {
+	c := orderedIndex{ids: make(map[string][]string, len(x.ids))}
+	for key, ids := range x.ids {
+		c.ids[key] = slices.Clone(ids)
+	}
+	return c
+}
+
+func (x *orderedIndex) add(key, id string) {
+	if key == "" {
+		return
+	}
+	if x.ids == nil {
+		x.ids = make(map[string][]string)
+	}
+	x.ids[key] = append(x.ids[key], id)
+}
+
+// clone copies the datasets and every object in them, so that changes made
+// through the live objects do not reach the copy.
+func (d *memoryData) clone() memoryData {
+	c := memoryData{
+		customers:         cloneObjects(d.customers),
+		customerOrder:     slices.Clone(d.customerOrder),
+		paymentMethods:    make(map[string]*types.PaymentMethod, len(d.paymentMethods)),
+		methodOrder:       slices.Clone(d.methodOrder),
+		paymentIntents:    cloneObjects(d.paymentIntents),
+		intentOrder:       slices.Clone(d.intentOrder),
+		intentsByCustomer: d.intentsByCustomer.clone(),
+		charges:           cloneObjects(d.charges),
+		chargeOrder:       slices.Clone(d.chargeOrder),
+		chargesByCustomer: d.chargesByCustomer.clone(),
+		refunds:           cloneObjects(d.refunds),
+		refundOrder:       slices.Clone(d.refundOrder),
+		refundsByIntent:   d.refundsByIntent.clone(),
+		holds:             cloneObjects(d.holds),
+		holdOrder:         slices.Clone(d.holdOrder),
+		accounts:          cloneObjects(d.accounts),
+		webhooks:          cloneObjects(d.webhooks),
+		webhookOrder:      slices.Clone(d.webhookOrder),
+		transactions:      cloneObjects(d.transactions),
+		ledger:            make([]*types.Transaction, 0, len(d.ledger)),
+	}
+	for id, method := range d.paymentMethods {
+		c.paymentMethods[id] = clonePaymentMethod(method)
+	}
+	for _, txn := range d.ledger {
+		c.ledger = append(c.ledger, c.transactions[txn.ID])
+	}
+	return c
+}
+
+// cloneObjects copies a map and the objects it points to.
+func cloneObjects[K comparable, V any](objects map[K]*V) map[K]*V {
+	c := make(map[K]*V, len(objects))
+	for key, object := range objects {
+		copied := *object
+		c[key] = &copied
+	}
+	return c
+}
+
+// memoryTx accesses the maps of a MemoryStore whose lock is already held.
+type memoryTx struct {
+	s      *MemoryStore
+	events []types.Event
+	// err fails the transaction, e.g. when NewID found no unique ID.
+	err error
+}
+
+func (t *memoryTx) Customer(id string) *types.Customer {
+	return t.s.customers[id]
+}
+
+func (t *memoryTx) Customers() []*types.Customer {
+	customers := make([]*types.Customer, 0, len(t.s.customerOrder))
+	for _, id := range t.s.customerOrder {
+		customers = append(customers, t.s.customers[id])
+	}
+	return customers
+}
+
+func (t *memoryTx) PaymentMethod(id string) *types.PaymentMethod {
+	return t.s.paymentMethods[id]
+}
+
+func (t *memoryTx) PaymentMethods() []*types.PaymentMethod {
+	methods := make([]*types.PaymentMethod, 0, len(t.s.methodOrder))
+	for _, id := range t.s.methodOrder {
+		methods = append(methods, t.s.paymentMethods[id])
+	}
+	return methods
+}
+
+func (t *memoryTx) PaymentIntent(id string) *types.PaymentIntent {
+	return t.s.paymentIntents[id]
+}
+
+func (t *memoryTx) PaymentIntents() []*types.PaymentIntent {
+	intents := make([]*types.PaymentIntent, 0, len(t.s.intentOrder))
+	for _, id := range t.s.intentOrder {
+		intents = append(intents, t.s.paymentIntents[id])
+	}
+	return intents
+}
+
+func (t *memoryTx) Charge(id string) *types.Charge {
+	return t.s.charges[id]
+}
+
+func (t *memoryTx) Charges() []*types.Charge {
+	charges := make([]*types.Charge, 0, len(t.s.chargeOrder))
+	for _, id := range t.s.chargeOrder {
+		charges = append(charges, t.s.charges[id])
+	}
+	return charges
+}
+
+func (t *memoryTx) PaymentIntentsByCustomer(customerID string) []*types.PaymentIntent {
+	ids := t.s.intentsByCustomer.ids[customerID]
+	intents := make([]*types.PaymentIntent, 0, len(ids))
+	for _, id := range ids {
+		intents = append(intents, t.s.paymentIntents[id])
+	}
+	return intents
+}
+
+func (t *memoryTx) ChargesByCustomer(customerID string) []*types.Charge {
+	ids := t.s.chargesByCustomer.ids[customerID]
+	charges := make([]*types.Charge, 0, len(ids))
+	for _, id := range ids {
+		charges = append(charges, t.s.charges[id])
+	}
+	return charges
+}
+
+func (t *memoryTx) Refund(id string) *types.Refund {
+	return t.s.refunds[id]
+}
+
+func (t *memoryTx) Refunds() []*types.Refund {
+	refunds := make([]*types.Refund, 0, len(t.s.refundOrder))
+	for _, id := range t.s.refundOrder {
+		refunds = append(refunds, t.s.refunds[id])
+	}
+	return refunds
+}
+
+func (t *memoryTx) RefundsByPaymentIntent(paymentIntent string) []*types.Refund {
+	ids := t.s.refundsByIntent.ids[paymentIntent]
+	refunds := make([]*types.Refund, 0, len(ids))
+	for _, id := range ids {
+		refunds = append(refunds, t.s.refunds[id])
+	}
+	return refunds
+}
+
+func (t *memoryTx) Hold(id string) *types.Hold {
+	return t.s.holds[id]
+}
+
+func (t *memoryTx) Holds() []*types.Hold {
+	holds := make([]*types.Hold, 0, len(t.s.holdOrder))
+	for _, id := range t.s.holdOrder {
+		holds = append(holds, t.s.holds[id])
+	}
+	return holds
+}
+
+func (t *memoryTx) Account(customerID string, paymentType types.PaymentType) *types.Account {
+	return t.s.accounts[accountKey{customerID, paymentType}]
+}
+
+func (t *memoryTx) Accounts(customerID string) []*types.Account {
+	var accounts []*types.Account
+	for _, paymentType := range types.PaymentTypes {
+		if account := t.s.accounts[accountKey{customerID, paymentType}]; account != nil {
+			accounts = append(accounts, account)
+		}
+	}
+	return accounts
+}
+
+func (t *memoryTx) WebhookEndpoint(id string) *types.WebhookEndpoint {
+	return t.s.webhooks[id]
+}
+
+func (t *memoryTx) WebhookEndpoints() []*types.WebhookEndpoint {
+	endpoints := make([]*types.WebhookEndpoint, 0, len(t.s.webhookOrder))
+	for _, id := range t.s.webhookOrder {
+		endpoints = append(endpoints, t.s.webhooks[id])
+	}
+	return endpoints
+}
+
+func (t *memoryTx) Transaction(id string) *types.Transaction {
+	return t.s.transactions[id]
+}
+
+func (t *memoryTx) Transactions() []*types.Transaction {
+	return t.s.ledger
+}
+
+func (t *memoryTx) PutCustomer(customer *types.Customer) {
+	if _, ok := t.s.customers[customer.ID]; !ok {
+		t.s.customerOrder = append(t.s.customerOrder, customer.ID)
+	}
+	t.s.customers[customer.ID] = customer
+}
+
+func (t *memoryTx) PutPaymentMethod(method *types.PaymentMethod) {
+	if _, ok := t.s.paymentMethods[method.ID]; !ok {
+		t.s.methodOrder = append(t.s.methodOrder, method.ID)
+	}
+	t.s.paymentMethods[method.ID] = method
+}
+
+func (t *memoryTx) PutPaymentIntent(intent *types.PaymentIntent) {
+	if _, ok := t.s.paymentIntents[intent.ID]; !ok {
+		t.s.intentOrder = append(t.s.intentOrder, intent.ID)
+		t.s.intentsByCustomer.add(intent.Customer, intent.ID)
+	}
+	t.s.paymentIntents[intent.ID] = intent
+}
+
+func (t *memoryTx) PutCharge(charge *types.Charge) {
+	if _, ok := t.s.charges[charge.ID]; !ok {
+		t.s.chargeOrder = append(t.s.chargeOrder, charge.ID)
+		t.s.chargesByCustomer.add(charge.Customer, charge.ID)
+	}
+	t.s.charges[charge.ID] = charge
+}
+
+func (t *memoryTx) PutRefund(refund *types.Refund) {
+	if _, ok := t.s.refunds[refund.ID]; !ok {
+		t.s.refundOrder = append(t.s.refundOrder, refund.ID)
+		t.s.refundsByIntent.add(refund.PaymentIntent, refund.ID)
+	}
+	t.s.refunds[refund.ID] = refund
+}
+
+func (t *memoryTx) PutHold(hold *types.Hold) {
+	if _, ok := t.s.holds[hold.ID]; !ok {
+		t.s.holdOrder = append(t.s.holdOrder, hold.ID)
+	}
+	t.s.holds[hold.ID] = hold
+}
+
+func (t *memoryTx) PutAccount(account *types.Account) {
+	t.s.accounts[accountKey{account.CustomerID, account.Type}] = account
+}
+
+func (t *memoryTx) PutWebhookEndpoint(endpoint *types.WebhookEndpoint) {
+	if _, ok := t.s.webhooks[endpoint.ID]; !ok {
+		t.s.webhookOrder = append(t.s.webhookOrder, endpoint.ID)
+	}
+	t.s.webhooks[endpoint.ID] = endpoint
+}
+
+func (t *memoryTx) DeleteWebhookEndpoint(id string) {
+	if _, ok := t.s.webhooks[id]; !ok {
+		return
+	}
+	delete(t.s.webhooks, id)
+	t.s.webhookOrder = slices.DeleteFunc(t.s.webhookOrder, func(existing string) bool {
+		return existing == id
+	})
+}
+
+func (t *memoryTx) AppendTransaction(txn *types.Transaction) {
+	t.s.transactions[txn.ID] = txn
+	t.s.ledger = append(t.s.ledger, txn)
+}
+
+func (t *memoryTx) Clear() {
+	t.s.customers = make(map[string]*types.Customer)
+	t.s.customerOrder = nil
+	t.s.paymentMethods = make(map[string]*types.PaymentMethod)
+	t.s.methodOrder = nil
+	t.s.paymentIntents = make(map[string]*types.PaymentIntent)
+	t.s.intentOrder = nil
+	t.s.intentsByCustomer = orderedIndex{}
+	t.s.charges = make(map[string]*types.Charge)
+	t.s.chargeOrder = nil
+	t.s.chargesByCustomer = orderedIndex{}
+	t.s.refunds = make(map[string]*types.Refund)
+	t.s.refundOrder = nil
+	t.s.refundsByIntent = orderedIndex{}
+	t.s.holds = make(map[string]*types.Hold)
+	t.s.holdOrder = nil
+	t.s.accounts = make(map[accountKey]*types.Account)
+	t.s.transactions = make(map[string]*types.Transaction)
+	t.s.ledger = nil
+}
+
+// NewID returns an ID that is not used by any stored object. Collisions are
+// logged and regenerated; after maxIDAttempts collisions the transaction
+// fails.
+func (t *memoryTx) NewID(prefix string) string {
+	for attempt := 1; attempt <= maxIDAttempts; attempt++ {
+		id := t.s.ids.NewID(prefix)
+		if !t.idInUse(id) {
+			return id
+		}
+		log.Printf("data: generated ID %s collides with an existing ID", id)
+	}
+	if t.err == nil {
+		t.err = fmt.Errorf("data: no unique %s ID after %d attempts", prefix, maxIDAttempts)
+	}
+	return ""
+}
+
+func (t *memoryTx) idInUse(id string) bool {
+	return t.s.customers[id] != nil || t.s.paymentMethods[id] != nil || t.s.paymentIntents[id] != nil ||
+		t.s.charges[id] != nil || t.s.refunds[id] != nil || t.s.holds[id] != nil || t.s.transactions[id] != nil ||
+		t.s.webhooks[id] != nil
+}
+
+func (t *memoryTx) ResetIDs() {
+	if ids, ok := t.s.ids.(ResettableIDGenerator); ok {
+		ids.Reset()
+	}
+}
+
+func (t *memoryTx) AuthorizationWindow() time.Duration {
+	return t.s.authorizationWindow
+}
+
+func (t *memoryTx) HoldTTL() time.Duration {
+	return t.s.holdTTL
+}
+
+func (t *memoryTx) Emit(event types.Event) {
+	t.events = append(t.events, event)
+}
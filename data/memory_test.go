@@ -0,0 +1,113 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/nerdgarten/mock-payment-service/types"
+)
+
+// TestMemoryStoreConcurrentAccess runs deposits, withdrawals, customer
+// creation and listings in parallel. Run it with -race to check that the
+// store guards every map it touches.
+func TestMemoryStoreConcurrentAccess(t *testing.T) {
+	store := NewEmptyMemoryStore()
+	customer, err := CreateMockCustomer(store, "Ruff", "ruff@example.com")
+	if err != nil {
+		t.Fatalf("CreateMockCustomer: %v", err)
+	}
+	const (
+		workers    = 16
+		iterations = 50
+	)
+	deposit := types.Money{Amount: 300, Currency: types.DefaultCurrency}
+	withdrawal := types.Money{Amount: 100, Currency: types.DefaultCurrency}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*iterations*4)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				if _, err := Deposit(store, customer.ID, types.PaymentTypeCash, deposit); err != nil {
+					errs <- fmt.Errorf("Deposit: %w", err)
+				}
+				if _, err := Withdraw(store, customer.ID, types.PaymentTypeCash, withdrawal); err != nil {
+					errs <- fmt.Errorf("Withdraw: %w", err)
+				}
+				if _, err := CreateMockCustomer(store, fmt.Sprintf("customer %d-%d", w, i), ""); err != nil {
+					errs <- fmt.Errorf("CreateMockCustomer: %w", err)
+				}
+				if _, _, err := ListMockCustomers(store, CustomerFilter{}, ListParams{Limit: MaxListLimit}); err != nil {
+					errs <- fmt.Errorf("ListMockCustomers: %w", err)
+				}
+				if _, _, err := ListTransactions(store, TransactionFilter{CustomerID: customer.ID}, ListParams{}); err != nil {
+					errs <- fmt.Errorf("ListTransactions: %w", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	account := GetAccount(store, customer.ID, types.PaymentTypeCash)
+	want := DefaultAccountBalances[types.PaymentTypeCash] + workers*iterations*(deposit.Amount-withdrawal.Amount)
+	if account.Balance != want {
+		t.Errorf("final balance = %d, want %d", account.Balance, want)
+	}
+	var customers int
+	_ = store.View(func(tx ReadTx) error {
+		customers = len(tx.Customers())
+		if got := len(tx.Transactions()); got != 2*workers*iterations {
+			t.Errorf("ledger has %d transactions, want %d", got, 2*workers*iterations)
+		}
+		return nil
+	})
+	if want := 1 + workers*iterations; customers != want {
+		t.Errorf("store has %d customers, want %d", customers, want)
+	}
+}
+
+func TestUpdateRollsBackOnError(t *testing.T) {
+	store := NewMemoryStore()
+	var events int
+	store.Subscribe(func(types.Event) { events++ })
+	before := TakeSnapshot(store)
+
+	failed := errors.New("failed")
+	err := store.Update(func(tx Tx) error {
+		tx.PutCustomer(&types.Customer{ID: "cus_rolled_back", Object: "customer"})
+		account := tx.Account("cus_mock_12345", types.PaymentTypeCash)
+		account.Balance += 500
+		recordTransaction(tx, types.TransactionKindDeposit, account, 500, "", "")
+		tx.Emit(types.Event{Type: "customer.created"})
+		return failed
+	})
+	if err != failed {
+		t.Fatalf("Update error = %v, want %v", err, failed)
+	}
+	if after := TakeSnapshot(store); !reflect.DeepEqual(after, before) {
+		t.Error("a failed update changed the store")
+	}
+	if events != 0 {
+		t.Errorf("a failed update published %d events", events)
+	}
+
+	func() {
+		defer func() { _ = recover() }()
+		_ = store.Update(func(tx Tx) error {
+			tx.PutCustomer(&types.Customer{ID: "cus_rolled_back", Object: "customer"})
+			panic("boom")
+		})
+	}()
+	if GetMockCustomer(store, "cus_rolled_back") != nil {
+		t.Error("a panicking update kept its writes")
+	}
+}
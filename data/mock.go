@@ -2,323 +2,919 @@ package data
 
 import (
 	"fmt"
-	"math/rand"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/nerdgarten/mock-payment-service/types"
 )
 
-// MockCustomers stores mock customer data
-var MockCustomers = map[string]*types.Customer{
-	"cus_mock_12345": {
+// DefaultAccountBalances are the opening balances, in minor units of
+// types.DefaultCurrency, of every new customer's accounts.
+var DefaultAccountBalances = map[types.PaymentType]types.Amount{
+	types.PaymentTypeCash:          types.FromMajor(5000, types.DefaultCurrency),
+	types.PaymentTypeMobileBanking: types.FromMajor(5000, types.DefaultCurrency),
+	types.PaymentTypeCreditCard:    types.FromMajor(5000, types.DefaultCurrency),
+	types.PaymentTypeMeowthWallet:  types.FromMajor(500, types.DefaultCurrency),
+}
+
+// DefaultAuthorizationWindow is how long a payment intent with the manual
+// capture method may wait in requires_capture unless the store sets another
+// window. Once it passes, the intent is canceled and its authorization
+// released.
+const DefaultAuthorizationWindow = 7 * 24 * time.Hour
+
+// seedMockData populates a store with the mock customers, payment method,
+// payment intents, charges, refunds and the customers' accounts.
+func seedMockData(tx Tx) {
+	tx.PutCustomer(&types.Customer{
 		ID:      "cus_mock_12345",
 		Object:  "customer",
 		Name:    "Ruff",
 		Email:   "ruff@example.com",
 		Created: 1734567890,
-	},
-	"cus_mock_67890": {
+	})
+	tx.PutCustomer(&types.Customer{
 		ID:      "cus_mock_67890",
 		Object:  "customer",
 		Name:    "John Doe",
 		Email:   "john@example.com",
 		Created: 1734567800,
-	},
-}
-
-// MockPaymentIntents stores mock payment intents
-var MockPaymentIntents = map[string]*types.PaymentIntent{
-	"pi_mock_98765": {
+	})
+
+	tx.PutPaymentMethod(&types.PaymentMethod{
+		ID:     "pm_mock_visa",
+		Object: "payment_method",
+		Type:   types.PaymentMethodTypeCard,
+		Card: &types.Card{
+			Brand:       "visa",
+			Last4:       "4242",
+			ExpMonth:    12,
+			ExpYear:     2034,
+			Fingerprint: cardFingerprint("4242424242424242"),
+		},
+		Created: 1734567850,
+	})
+
+	tx.PutPaymentIntent(&types.PaymentIntent{
 		ID:            "pi_mock_98765",
 		Object:        "payment_intent",
 		Amount:        1200,
 		Currency:      "thb",
-		Status:        "requires_confirmation",
+		Status:        types.PaymentIntentStatusRequiresConfirmation,
+		CaptureMethod: types.CaptureMethodAutomatic,
 		ClientSecret:  "pi_mock_98765_secret_abc123",
 		Description:   "Food delivery payment",
 		PaymentMethod: "pm_mock_visa",
-	},
-}
-
-// MockCharges stores mock charges
-var MockCharges = map[string]*types.Charge{
-	"ch_mock_555": {
-		ID:            "ch_mock_555",
-		Status:        "succeeded",
-		Amount:        1200,
-		Currency:      "thb",
-		PaymentMethod: "pm_mock_visa",
-	},
-}
-
-// MockRefunds stores mock refunds
-var MockRefunds = map[string]*types.Refund{
-	"re_mock_444": {
+		Customer:      "cus_mock_12345",
+		Created:       1734567900,
+	})
+	tx.PutPaymentIntent(&types.PaymentIntent{
+		ID:             "pi_mock_24680",
+		Object:         "payment_intent",
+		Amount:         1200,
+		Currency:       "thb",
+		Status:         types.PaymentIntentStatusSucceeded,
+		CaptureMethod:  types.CaptureMethodAutomatic,
+		ClientSecret:   "pi_mock_24680_secret_def456",
+		Description:    "Grocery delivery payment",
+		PaymentMethod:  "pm_mock_visa",
+		AmountRefunded: 600,
+		LatestCharge:   "ch_mock_555",
+		Customer:       "cus_mock_67890",
+		Created:        1734567910,
+	})
+
+	tx.PutCharge(&types.Charge{
+		ID:             "ch_mock_555",
+		Object:         "charge",
+		Status:         "succeeded",
+		Amount:         1200,
+		Currency:       "thb",
+		PaymentMethod:  "pm_mock_visa",
+		PaymentIntent:  "pi_mock_24680",
+		Captured:       true,
+		AmountCaptured: 1200,
+		AmountRefunded: 600,
+		Customer:       "cus_mock_67890",
+		Created:        1734567920,
+	})
+
+	tx.PutRefund(&types.Refund{
 		ID:            "re_mock_444",
 		Object:        "refund",
 		Amount:        600,
 		Currency:      "thb",
 		Status:        "succeeded",
-		PaymentIntent: "pi_mock_98765",
-	},
+		PaymentIntent: "pi_mock_24680",
+		Charge:        "ch_mock_555",
+		Created:       1734567990,
+	})
+
+	openAccounts(tx, "cus_mock_12345")
+	openAccounts(tx, "cus_mock_67890")
 }
 
-// MockAccounts stores mock payment accounts with initial balance of 5000
-var MockAccounts = map[types.PaymentType]*types.Account{
-	types.PaymentTypeCash: {
-		Type:    types.PaymentTypeCash,
-		Balance: 5000,
-	},
-	types.PaymentTypeMobileBanking: {
-		Type:    types.PaymentTypeMobileBanking,
-		Balance: 5000,
-	},
-	types.PaymentTypeCreditCard: {
-		Type:    types.PaymentTypeCreditCard,
-		Balance: 5000,
-	},
-	types.PaymentTypeMeowthWallet: {
-		Type:    types.PaymentTypeMeowthWallet,
-		Balance: 500.0,
-	},
+// openAccounts creates an account with the default opening balance for every
+// payment type of a customer.
+func openAccounts(tx Tx, customerID string) {
+	for _, paymentType := range types.PaymentTypes {
+		tx.PutAccount(&types.Account{
+			CustomerID: customerID,
+			Type:       paymentType,
+			Currency:   types.DefaultCurrency,
+			Balance:    DefaultAccountBalances[paymentType],
+		})
+	}
 }
 
-// GenerateCustomerID generates a mock customer ID
-func GenerateCustomerID() string {
-	return fmt.Sprintf("cus_mock_%d", rand.Intn(100000))
+// CreateMockCustomer creates a new mock customer and opens its accounts. A
+// non-empty email must be valid and unused.
+func CreateMockCustomer(store Store, name, email string) (*types.Customer, error) {
+	var out types.Customer
+	err := store.Update(func(tx Tx) error {
+		if err := checkCustomerEmail(tx, "", email); err != nil {
+			return err
+		}
+		customer := &types.Customer{
+			ID:      tx.NewID("cus"),
+			Object:  "customer",
+			Name:    name,
+			Email:   email,
+			Created: time.Now().Unix(),
+		}
+		tx.PutCustomer(customer)
+		openAccounts(tx, customer.ID)
+		emit(tx, types.EventCustomerCreated, customer)
+		out = *customer
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
 }
 
-// GeneratePaymentIntentID generates a mock payment intent ID
-func GeneratePaymentIntentID() string {
-	return fmt.Sprintf("pi_mock_%d", rand.Intn(100000))
+// GetMockCustomer retrieves a customer by ID
+func GetMockCustomer(store Store, id string) *types.Customer {
+	var out *types.Customer
+	_ = store.View(func(tx ReadTx) error {
+		if customer := tx.Customer(id); customer != nil {
+			c := *customer
+			out = &c
+		}
+		return nil
+	})
+	return out
 }
 
-// GenerateChargeID generates a mock charge ID
-func GenerateChargeID() string {
-	return fmt.Sprintf("ch_mock_%d", rand.Intn(100000))
+// GetMockPaymentIntent retrieves a payment intent by ID
+func GetMockPaymentIntent(store Store, id string) *types.PaymentIntent {
+	var out *types.PaymentIntent
+	_ = store.View(func(tx ReadTx) error {
+		if intent := tx.PaymentIntent(id); intent != nil {
+			pi := *intent
+			out = &pi
+		}
+		return nil
+	})
+	return out
 }
 
-// GenerateRefundID generates a mock refund ID
-func GenerateRefundID() string {
-	return fmt.Sprintf("re_mock_%d", rand.Intn(100000))
+// GetMockCharge retrieves a charge by ID
+func GetMockCharge(store Store, id string) *types.Charge {
+	var out *types.Charge
+	_ = store.View(func(tx ReadTx) error {
+		if charge := tx.Charge(id); charge != nil {
+			c := *charge
+			out = &c
+		}
+		return nil
+	})
+	return out
 }
 
-// CreateMockCustomer creates a new mock customer
-func CreateMockCustomer(name, email string) *types.Customer {
-	id := GenerateCustomerID()
-	customer := &types.Customer{
-		ID:      id,
-		Object:  "customer",
-		Name:    name,
-		Email:   email,
-		Created: time.Now().Unix(),
+// CreateMockPaymentIntent creates a new mock payment intent. Intents without a
+// payment method start in requires_payment_method. A non-empty customerID must
+// name an existing customer and a non-empty paymentMethod a stored payment
+// method or a test card. An empty captureMethod means automatic.
+func CreateMockPaymentIntent(store Store, amount types.Money, customerID, paymentMethod, description string, captureMethod types.CaptureMethod) (*types.PaymentIntent, error) {
+	if err := validateMoney(amount); err != nil {
+		return nil, err
+	}
+	switch captureMethod {
+	case "":
+		captureMethod = types.CaptureMethodAutomatic
+	case types.CaptureMethodAutomatic, types.CaptureMethodManual:
+	default:
+		return nil, &Error{
+			Kind:    ErrorKindInvalid,
+			Code:    CodeParameterInvalid,
+			Param:   "capture_method",
+			Message: fmt.Sprintf("unsupported capture_method %q, expected automatic or manual", captureMethod),
+		}
+	}
+	status := types.PaymentIntentStatusRequiresConfirmation
+	if paymentMethod == "" {
+		status = types.PaymentIntentStatusRequiresPaymentMethod
+	}
+	var out types.PaymentIntent
+	err := store.Update(func(tx Tx) error {
+		if customerID != "" && activeCustomer(tx, customerID) == nil {
+			err := notFoundError("customer", customerID)
+			err.Param = "customer"
+			return err
+		}
+		if paymentMethod != "" {
+			if err := checkPaymentMethod(tx, paymentMethod, customerID); err != nil {
+				return err
+			}
+		}
+		id := tx.NewID("pi")
+		intent := &types.PaymentIntent{
+			ID:            id,
+			Object:        "payment_intent",
+			Amount:        amount.Amount,
+			Currency:      strings.ToLower(amount.Currency),
+			Status:        status,
+			CaptureMethod: captureMethod,
+			ClientSecret:  tx.NewID(id + "_secret"),
+			Description:   description,
+			PaymentMethod: paymentMethod,
+			Customer:      customerID,
+			Created:       time.Now().Unix(),
+		}
+		tx.PutPaymentIntent(intent)
+		emit(tx, types.EventPaymentIntentCreated, intent)
+		out = *intent
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	MockCustomers[id] = customer
-	return customer
+	return &out, nil
 }
 
-// GetMockCustomer retrieves a customer by ID
-func GetMockCustomer(id string) *types.Customer {
-	return MockCustomers[id]
+// ConfirmParams are the optional parameters of a confirmation.
+type ConfirmParams struct {
+	// PaymentMethod, checked like on creation, replaces the intent's payment
+	// method first.
+	PaymentMethod string
+	// ClientSecret must match the intent's client secret when set.
+	ClientSecret string
+	// ReturnURL is where the 3-D Secure challenge page sends the customer.
+	ReturnURL string
+	// ChallengeURL is the URL of the hosted 3-D Secure challenge page; the
+	// intent's escaped client secret is appended to it.
+	ChallengeURL string
 }
 
-// CreateMockPaymentIntent creates a new mock payment intent
-func CreateMockPaymentIntent(amount float64, currency, paymentMethod, description string) *types.PaymentIntent {
-	id := GeneratePaymentIntentID()
-	intent := &types.PaymentIntent{
-		ID:            id,
-		Object:        "payment_intent",
-		Amount:        int64(amount),
-		Currency:      currency,
-		Status:        "requires_confirmation",
-		ClientSecret:  fmt.Sprintf("%s_secret_%s", id, generateRandomString(6)),
-		Description:   description,
-		PaymentMethod: paymentMethod,
-	}
-	MockPaymentIntents[id] = intent
-	return intent
+// ConfirmMockPaymentIntent confirms a payment intent and creates a charge.
+// When the payment method requires 3-D Secure, no charge is created yet: the
+// intent moves to requires_action with a next action redirecting to the
+// challenge page. When a test card declines, the intent returns to
+// requires_payment_method and is returned with its failed charge and an
+// ErrorKindPaymentFailed error.
+func ConfirmMockPaymentIntent(store Store, id string, params ConfirmParams) (*types.PaymentIntent, *types.Charges, error) {
+	var (
+		intent   types.PaymentIntent
+		charges  *types.Charges
+		declined *types.PaymentError
+	)
+	err := store.Update(func(tx Tx) error {
+		stored := tx.PaymentIntent(id)
+		if stored == nil {
+			return notFoundError("payment intent", id)
+		}
+		if params.ClientSecret != "" && params.ClientSecret != stored.ClientSecret {
+			return &Error{Kind: ErrorKindInvalid, Code: CodeParameterInvalid, Param: "client_secret", Message: "client_secret does not match payment intent " + id}
+		}
+		next := *stored
+		if params.PaymentMethod != "" {
+			if err := checkPaymentMethod(tx, params.PaymentMethod, next.Customer); err != nil {
+				return err
+			}
+			if next.Status == types.PaymentIntentStatusRequiresPaymentMethod {
+				if err := transitionPaymentIntent(&next, "confirm", types.PaymentIntentStatusRequiresConfirmation); err != nil {
+					return err
+				}
+			}
+			next.PaymentMethod = params.PaymentMethod
+		}
+		if requiresAuthentication(tx, next.PaymentMethod) {
+			if err := transitionPaymentIntent(&next, "confirm", types.PaymentIntentStatusRequiresAction); err != nil {
+				return err
+			}
+			next.LastPaymentError = nil
+			next.NextAction = &types.NextAction{
+				Type: "redirect_to_url",
+				RedirectToURL: &types.RedirectToURL{
+					URL:       params.ChallengeURL + url.PathEscape(next.ClientSecret),
+					ReturnURL: params.ReturnURL,
+				},
+			}
+			*stored = next
+			intent = next
+			charges = &types.Charges{Data: []types.Charge{}}
+			emit(tx, types.EventPaymentIntentRequiresAction, stored)
+			return nil
+		}
+		if err := transitionPaymentIntent(&next, "confirm", types.PaymentIntentStatusProcessing); err != nil {
+			return err
+		}
+		var charge *types.Charge
+		charge, declined = chargePaymentIntent(tx, &next)
+		*stored = next
+		intent = next
+		charges = &types.Charges{Data: []types.Charge{*charge}}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if declined != nil {
+		return &intent, charges, paymentFailedError(declined)
+	}
+	return &intent, charges, nil
 }
 
-// ConfirmMockPaymentIntent confirms a payment intent and creates a charge
-func ConfirmMockPaymentIntent(id string) (*types.PaymentIntent, *types.Charges) {
-	intent := MockPaymentIntents[id]
-	if intent == nil {
-		return nil, nil
-	}
-	intent.Status = "succeeded"
-	chargeID := GenerateChargeID()
+// chargePaymentIntent charges the payment method of a processing intent and
+// moves the intent to succeeded, or back to requires_payment_method with the
+// returned payment error when a test card declines. An intent with the manual
+// capture method only authorizes its amount and moves to requires_capture.
+// The caller stores intent.
+func chargePaymentIntent(tx Tx, intent *types.PaymentIntent) (*types.Charge, *types.PaymentError) {
+	now := time.Now()
 	charge := &types.Charge{
-		ID:            chargeID,
+		ID:            tx.NewID("ch"),
+		Object:        "charge",
 		Status:        "succeeded",
 		Amount:        intent.Amount,
 		Currency:      intent.Currency,
 		PaymentMethod: intent.PaymentMethod,
+		PaymentIntent: intent.ID,
+		Customer:      intent.Customer,
+		Created:       now.Unix(),
 	}
-	MockCharges[chargeID] = charge
-	charges := &types.Charges{
-		Data: []types.Charge{*charge},
+	declined := paymentMethodDecline(tx, intent.PaymentMethod)
+	manual := intent.CaptureMethod == types.CaptureMethodManual
+	switch {
+	case declined != nil:
+	case manual:
+		intent.Status = types.PaymentIntentStatusRequiresCapture
+		intent.AmountCapturable = charge.Amount
+		charge.CaptureBefore = now.Add(tx.AuthorizationWindow()).Unix()
+	default:
+		intent.Status = types.PaymentIntentStatusSucceeded
+		charge.Captured = true
+		charge.AmountCaptured = charge.Amount
 	}
-	return intent, charges
-}
-
-// CreateMockRefund creates a new mock refund
-func CreateMockRefund(paymentIntent string, amount float64) *types.Refund {
-	id := GenerateRefundID()
-	refund := &types.Refund{
-		ID:            id,
-		Object:        "refund",
-		Amount:        int64(amount),
-		Currency:      "thb", // Assuming THB for simplicity
-		Status:        "succeeded",
-		PaymentIntent: paymentIntent,
+	if declined != nil {
+		intent.Status = types.PaymentIntentStatusRequiresPaymentMethod
+		charge.Status = "failed"
+		charge.FailureCode = declined.Code
+		charge.FailureMessage = declined.Message
+		charge.DeclineCode = declined.DeclineCode
 	}
-	MockRefunds[id] = refund
-	return refund
-}
-
-// generateRandomString generates a random string of given length
-func generateRandomString(length int) string {
-	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-	b := make([]byte, length)
-	for i := range b {
-		b[i] = charset[rand.Intn(len(charset))]
+	intent.LastPaymentError = declined
+	intent.NextAction = nil
+	intent.LatestCharge = charge.ID
+	tx.PutCharge(charge)
+	if declined != nil {
+		emit(tx, types.EventChargeFailed, charge)
+		emit(tx, types.EventPaymentIntentFailed, intent)
+		return charge, declined
 	}
-	return string(b)
+	emit(tx, types.EventChargeSucceeded, charge)
+	if manual {
+		emit(tx, types.EventPaymentIntentCapturable, intent)
+	} else {
+		emit(tx, types.EventPaymentIntentSucceeded, intent)
+	}
+	return charge, nil
 }
 
-// GenerateTransactionID generates a mock transaction ID
-func GenerateTransactionID() string {
-	return fmt.Sprintf("txn_mock_%d", rand.Intn(100000))
+// CancelMockPaymentIntent cancels a payment intent that has not succeeded
+// yet, releasing the authorization of an intent in requires_capture.
+func CancelMockPaymentIntent(store Store, id, reason string) (*types.PaymentIntent, error) {
+	var intent types.PaymentIntent
+	err := store.Update(func(tx Tx) error {
+		stored := tx.PaymentIntent(id)
+		if stored == nil {
+			return notFoundError("payment intent", id)
+		}
+		if err := transitionPaymentIntent(stored, "cancel", types.PaymentIntentStatusCanceled); err != nil {
+			return err
+		}
+		stored.CanceledAt = time.Now().Unix()
+		stored.CancellationReason = reason
+		stored.NextAction = nil
+		releaseAuthorization(tx, stored)
+		intent = *stored
+		emit(tx, types.EventPaymentIntentCanceled, stored)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &intent, nil
 }
 
-// Deposit adds money to a payment account
-func Deposit(paymentType types.PaymentType, amount float64) *types.DepositResponse {
-	account := MockAccounts[paymentType]
-	if account == nil {
-		return &types.DepositResponse{
-			Success: false,
-			Message: "Payment type not supported",
+// CaptureMockPaymentIntent captures the funds of an intent in
+// requires_capture. A nil amountToCapture captures the whole authorized
+// amount; a smaller amount releases the rest. An authorization past its
+// capture deadline is expired instead and an ErrorKindConflict error returned.
+func CaptureMockPaymentIntent(store Store, id string, amountToCapture *types.Amount) (*types.PaymentIntent, *types.Charges, error) {
+	var (
+		intent  types.PaymentIntent
+		charges = &types.Charges{Data: []types.Charge{}}
+	)
+	err := updateExpiring(store, func(tx Tx) error {
+		stored := tx.PaymentIntent(id)
+		if stored == nil {
+			return notFoundError("payment intent", id)
+		}
+		next := *stored
+		if err := transitionPaymentIntent(&next, "capture", types.PaymentIntentStatusSucceeded); err != nil {
+			return err
+		}
+		charge := tx.Charge(stored.LatestCharge)
+		now := time.Now()
+		if charge != nil && charge.CaptureBefore != 0 && now.Unix() >= charge.CaptureBefore {
+			expireAuthorization(tx, stored, now)
+			return &expiredError{&Error{
+				Kind:    ErrorKindConflict,
+				Code:    CodePaymentIntentUnexpectedState,
+				Message: fmt.Sprintf("the authorization of payment intent %s expired at %s", id, time.Unix(charge.CaptureBefore, 0).UTC().Format(time.RFC3339)),
+			}}
+		}
+		capturable := stored.AmountCapturable
+		amount := capturable
+		if amountToCapture != nil {
+			amount = *amountToCapture
+		}
+		if amount <= 0 {
+			return &Error{Kind: ErrorKindInvalid, Code: CodeParameterInvalid, Param: "amount_to_capture", Message: "amount_to_capture must be greater than zero"}
+		}
+		if amount > capturable {
+			return &Error{
+				Kind:    ErrorKindInvalid,
+				Code:    CodeAmountTooLarge,
+				Param:   "amount_to_capture",
+				Message: fmt.Sprintf("amount_to_capture %d is greater than the capturable amount %d of payment intent %s", amount, capturable, id),
+			}
 		}
+		next.AmountCapturable = 0
+		*stored = next
+		if charge != nil {
+			charge.Status = "succeeded"
+			charge.Captured = true
+			charge.AmountCaptured = amount
+			charge.AmountReleased = charge.Amount - amount
+			charges.Data = append(charges.Data, *charge)
+			emit(tx, types.EventChargeCaptured, charge)
+		}
+		intent = *stored
+		emit(tx, types.EventPaymentIntentSucceeded, stored)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
 	}
+	return &intent, charges, nil
+}
 
-	if amount <= 0 {
-		return &types.DepositResponse{
-			Success: false,
-			Message: "Invalid deposit amount",
+// ExpireMockAuthorizations cancels every intent in requires_capture whose
+// authorization passed its capture deadline at now, releasing the held funds.
+// It returns the number of expired intents.
+func ExpireMockAuthorizations(store Store, now time.Time) (int, error) {
+	due := func(tx ReadTx) []*types.PaymentIntent {
+		var intents []*types.PaymentIntent
+		for _, intent := range tx.PaymentIntents() {
+			if intent.Status != types.PaymentIntentStatusRequiresCapture {
+				continue
+			}
+			if charge := tx.Charge(intent.LatestCharge); charge != nil && charge.CaptureBefore != 0 && now.Unix() >= charge.CaptureBefore {
+				intents = append(intents, intent)
+			}
 		}
+		return intents
 	}
+	return expireDue(store, due, func(tx Tx, intent *types.PaymentIntent) {
+		expireAuthorization(tx, intent, now)
+	})
+}
 
-	account.Balance += amount
-	transactionID := GenerateTransactionID()
+// expireAuthorization cancels an intent in requires_capture whose
+// authorization expired and releases its charge.
+func expireAuthorization(tx Tx, intent *types.PaymentIntent, now time.Time) {
+	intent.Status = types.PaymentIntentStatusCanceled
+	intent.CanceledAt = now.Unix()
+	intent.CancellationReason = "expired"
+	if charge := releaseAuthorization(tx, intent); charge != nil {
+		emit(tx, types.EventChargeExpired, charge)
+	}
+	emit(tx, types.EventPaymentIntentCanceled, intent)
+}
 
-	return &types.DepositResponse{
-		Success:       true,
-		TransactionID: transactionID,
-		Message:       "Deposit successful",
-		Account:       *account,
+// releaseAuthorization releases the uncaptured funds held by an intent's
+// latest charge and returns that charge, or nil when nothing was held.
+func releaseAuthorization(tx Tx, intent *types.PaymentIntent) *types.Charge {
+	intent.AmountCapturable = 0
+	charge := tx.Charge(intent.LatestCharge)
+	if charge == nil || charge.Status != "succeeded" || charge.Captured {
+		return nil
 	}
+	charge.AmountReleased = charge.Amount
+	return charge
 }
 
-// Withdraw removes money from a payment account
-func Withdraw(paymentType types.PaymentType, amount float64) *types.WithdrawResponse {
-	account := MockAccounts[paymentType]
-	if account == nil {
-		return &types.WithdrawResponse{
-			Success: false,
-			Message: "Payment type not supported",
+// CreateMockRefund refunds part or all of a succeeded payment intent's charge.
+// A nil amount refunds everything not refunded yet; the refund inherits the
+// charge's currency and the cumulative refunds may not exceed the captured amount.
+func CreateMockRefund(store Store, paymentIntent string, amount *types.Amount, reason string) (*types.Refund, error) {
+	var refund types.Refund
+	err := store.Update(func(tx Tx) error {
+		intent := tx.PaymentIntent(paymentIntent)
+		if intent == nil {
+			err := notFoundError("payment intent", paymentIntent)
+			err.Param = "payment_intent"
+			return err
+		}
+		charge := tx.Charge(intent.LatestCharge)
+		if intent.Status != types.PaymentIntentStatusSucceeded || charge == nil || charge.Status != "succeeded" {
+			return &Error{
+				Kind:    ErrorKindConflict,
+				Code:    CodePaymentIntentUnexpectedState,
+				Param:   "payment_intent",
+				Message: fmt.Sprintf("payment intent %s has no succeeded charge to refund", paymentIntent),
+			}
+		}
+		remaining := capturedAmount(charge) - charge.AmountRefunded
+		if remaining <= 0 {
+			return &Error{
+				Kind:    ErrorKindConflict,
+				Code:    CodeChargeAlreadyRefunded,
+				Message: fmt.Sprintf("charge %s has already been refunded", charge.ID),
+			}
+		}
+		refundAmount := remaining
+		if amount != nil {
+			refundAmount = *amount
+		}
+		if refundAmount <= 0 {
+			return &Error{Kind: ErrorKindInvalid, Code: CodeParameterInvalid, Param: "amount", Message: "amount must be greater than zero"}
+		}
+		if refundAmount > remaining {
+			return &Error{
+				Kind:    ErrorKindInvalid,
+				Code:    CodeAmountTooLarge,
+				Param:   "amount",
+				Message: fmt.Sprintf("refund amount %d is greater than the unrefunded amount %d on charge %s", refundAmount, remaining, charge.ID),
+			}
 		}
-	}
 
-	if amount <= 0 {
-		return &types.WithdrawResponse{
-			Success: false,
-			Message: "Invalid withdrawal amount",
+		refund = types.Refund{
+			ID:            tx.NewID("re"),
+			Object:        "refund",
+			Amount:        refundAmount,
+			Currency:      charge.Currency,
+			Status:        "succeeded",
+			PaymentIntent: intent.ID,
+			Charge:        charge.ID,
+			Reason:        reason,
+			Created:       time.Now().Unix(),
 		}
+		stored := refund
+		tx.PutRefund(&stored)
+		charge.AmountRefunded += refundAmount
+		charge.Refunded = charge.AmountRefunded == capturedAmount(charge)
+		intent.AmountRefunded += refundAmount
+		emit(tx, types.EventRefundCreated, &stored)
+		emit(tx, types.EventChargeRefunded, charge)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
+	return &refund, nil
+}
 
-	if account.Balance < amount {
-		return &types.WithdrawResponse{
-			Success: false,
-			Message: "Insufficient balance",
-		}
+// capturedAmount is the part of a succeeded intent's charge that was actually
+// collected. Charges stored without capture details, e.g. by older snapshots,
+// were captured in full.
+func capturedAmount(charge *types.Charge) types.Amount {
+	if !charge.Captured {
+		return charge.Amount
 	}
+	return charge.AmountCaptured
+}
 
-	account.Balance -= amount
-	transactionID := GenerateTransactionID()
+// GetMockRefund retrieves a refund by ID
+func GetMockRefund(store Store, id string) *types.Refund {
+	var out *types.Refund
+	_ = store.View(func(tx ReadTx) error {
+		if refund := tx.Refund(id); refund != nil {
+			r := *refund
+			out = &r
+		}
+		return nil
+	})
+	return out
+}
 
-	return &types.WithdrawResponse{
-		Success:       true,
-		TransactionID: transactionID,
-		Message:       "Withdrawal successful",
-		Account:       *account,
+// Deposit adds money to a customer's payment account
+func Deposit(store Store, customerID string, paymentType types.PaymentType, money types.Money) (*types.DepositResponse, error) {
+	var resp *types.DepositResponse
+	err := store.Update(func(tx Tx) error {
+		account, err := walletAccount(tx, customerID, paymentType, money, "Invalid deposit amount")
+		if err != nil {
+			return err
+		}
+		account.Balance += money.Amount
+		txn := recordTransaction(tx, types.TransactionKindDeposit, account, money.Amount, "", "")
+		resp = &types.DepositResponse{
+			Success:       true,
+			TransactionID: txn.ID,
+			Message:       "Deposit successful",
+			Account:       *account,
+		}
+		emit(tx, types.EventAccountDeposited, resp)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
+	return resp, nil
 }
 
-// Refund processes a refund to a payment account
-func Refund(paymentType types.PaymentType, amount float64, referenceID string) *types.RefundResponse {
-	account := MockAccounts[paymentType]
-	if account == nil {
-		return &types.RefundResponse{
-			Success: false,
-			Message: "Payment type not supported",
+// Withdraw removes money from a customer's payment account
+func Withdraw(store Store, customerID string, paymentType types.PaymentType, money types.Money) (*types.WithdrawResponse, error) {
+	var resp *types.WithdrawResponse
+	err := store.Update(func(tx Tx) error {
+		account, err := walletAccount(tx, customerID, paymentType, money, "Invalid withdrawal amount")
+		if err != nil {
+			return err
+		}
+		if account.Available() < money.Amount {
+			return insufficientBalanceError()
 		}
+		account.Balance -= money.Amount
+		txn := recordTransaction(tx, types.TransactionKindWithdrawal, account, money.Amount, "", "")
+		resp = &types.WithdrawResponse{
+			Success:       true,
+			TransactionID: txn.ID,
+			Message:       "Withdrawal successful",
+			Account:       *account,
+		}
+		emit(tx, types.EventAccountWithdrawn, resp)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
+	return resp, nil
+}
 
-	if amount <= 0 {
-		return &types.RefundResponse{
-			Success: false,
-			Message: "Invalid refund amount",
+// Refund processes a refund to a customer's payment account
+func Refund(store Store, customerID string, paymentType types.PaymentType, money types.Money, referenceID string) (*types.RefundResponse, error) {
+	var resp *types.RefundResponse
+	err := store.Update(func(tx Tx) error {
+		account, err := walletAccount(tx, customerID, paymentType, money, "Invalid refund amount")
+		if err != nil {
+			return err
 		}
+		account.Balance += money.Amount
+		txn := recordTransaction(tx, types.TransactionKindRefund, account, money.Amount, referenceID, "")
+		resp = &types.RefundResponse{
+			Success:       true,
+			TransactionID: txn.ID,
+			Message:       "Refund successful",
+			Account:       *account,
+		}
+		emit(tx, types.EventAccountRefunded, resp)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
+	return resp, nil
+}
 
-	account.Balance += amount
-	transactionID := GenerateTransactionID()
+// ProcessPayment processes a payment by deducting from the customer's account. Credit
+// card payments record a charge against paymentMethod, and magic test cards
+// decline without touching the balance. A declined payment returns both the
+// failed payment, including its charge, and the decline error.
+func ProcessPayment(store Store, customerID string, paymentType types.PaymentType, money types.Money, orderID, paymentMethod string) (*types.ProcessPaymentResponse, error) {
+	var (
+		resp     *types.ProcessPaymentResponse
+		declined error
+	)
+	err := store.Update(func(tx Tx) error {
+		account, err := walletAccount(tx, customerID, paymentType, money, "Invalid payment amount")
+		if err != nil {
+			return err
+		}
+		amount := money.Amount
+
+		var charge *types.Charge
+		if paymentType == types.PaymentTypeCreditCard {
+			if paymentMethod != "" {
+				if err := checkPaymentMethod(tx, paymentMethod, customerID); err != nil {
+					return err
+				}
+			}
+			charge = &types.Charge{
+				ID:            tx.NewID("ch"),
+				Object:        "charge",
+				Status:        "succeeded",
+				Amount:        amount,
+				Currency:      account.Currency,
+				PaymentMethod: paymentMethod,
+				Customer:      customerID,
+				Created:       time.Now().Unix(),
+			}
+			if paymentErr := paymentMethodDecline(tx, paymentMethod); paymentErr != nil {
+				charge.Status = "failed"
+				charge.FailureCode = paymentErr.Code
+				charge.FailureMessage = paymentErr.Message
+				charge.DeclineCode = paymentErr.DeclineCode
+				tx.PutCharge(charge)
+				emit(tx, types.EventChargeFailed, charge)
+				failed := *charge
+				resp = &types.ProcessPaymentResponse{
+					Success:     false,
+					Message:     paymentErr.Message,
+					OrderID:     orderID,
+					Account:     *account,
+					DeclineCode: paymentErr.DeclineCode,
+					Charge:      &failed,
+				}
+				// The failed charge is kept, so the transaction still commits.
+				declined = paymentFailedError(paymentErr)
+				return nil
+			}
+		}
 
-	return &types.RefundResponse{
-		Success:       true,
-		TransactionID: transactionID,
-		Message:       "Refund successful",
-		Account:       *account,
+		if account.Available() < amount {
+			return insufficientBalanceError()
+		}
+
+		account.Balance -= amount
+		var reference string
+		if charge != nil {
+			reference = charge.ID
+		}
+		txn := recordTransaction(tx, types.TransactionKindPayment, account, amount, reference, orderID)
+		resp = &types.ProcessPaymentResponse{
+			Success:       true,
+			TransactionID: txn.ID,
+			Message:       "Payment processed successfully",
+			OrderID:       orderID,
+			Account:       *account,
+		}
+		if charge != nil {
+			charge.Captured = true
+			charge.AmountCaptured = amount
+			tx.PutCharge(charge)
+			emit(tx, types.EventChargeSucceeded, charge)
+			succeeded := *charge
+			resp.Charge = &succeeded
+		}
+		emit(tx, types.EventPaymentProcessed, resp)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
+	return resp, declined
 }
 
-// ProcessPayment processes a payment by deducting from the account
-func ProcessPayment(paymentType types.PaymentType, amount float64, orderID string) *types.ProcessPaymentResponse {
-	account := MockAccounts[paymentType]
+// walletAccount returns the account that a wallet operation moves money on,
+// rejecting unknown customers and payment types, currency mismatches and
+// non-positive amounts. amountMessage describes an invalid amount.
+func walletAccount(tx Tx, customerID string, paymentType types.PaymentType, money types.Money, amountMessage string) (*types.Account, error) {
+	if activeCustomer(tx, customerID) == nil {
+		return nil, &Error{Kind: ErrorKindNotFound, Code: CodeResourceMissing, Param: "customer_id", Message: "Customer not found"}
+	}
+	account := tx.Account(customerID, paymentType)
 	if account == nil {
-		return &types.ProcessPaymentResponse{
-			Success: false,
-			Message: "Payment type not supported",
-		}
+		return nil, &Error{Kind: ErrorKindInvalid, Code: CodeParameterInvalid, Param: "type", Message: "Payment type not supported"}
+	}
+	if msg := checkAccountCurrency(account, money.Currency); msg != "" {
+		return nil, &Error{Kind: ErrorKindInvalid, Code: CodeParameterInvalid, Param: "currency", Message: msg}
 	}
+	if money.Amount <= 0 {
+		return nil, &Error{Kind: ErrorKindInvalid, Code: CodeParameterInvalid, Param: "amount", Message: amountMessage}
+	}
+	return account, nil
+}
 
-	if amount <= 0 {
-		return &types.ProcessPaymentResponse{
-			Success: false,
-			Message: "Invalid payment amount",
+// GetAccount retrieves a customer's account information. Accounts of deleted
+// customers are not returned.
+func GetAccount(store Store, customerID string, paymentType types.PaymentType) *types.Account {
+	var out *types.Account
+	_ = store.View(func(tx ReadTx) error {
+		if activeCustomer(tx, customerID) == nil {
+			return nil
 		}
-	}
+		if account := tx.Account(customerID, paymentType); account != nil {
+			a := *account
+			out = &a
+		}
+		return nil
+	})
+	return out
+}
 
-	if account.Balance < amount {
-		return &types.ProcessPaymentResponse{
-			Success: false,
-			Message: "Insufficient balance",
+// SetAccountBalance sets the balance of a customer's account, recording the
+// difference in the ledger as an adjustment.
+func SetAccountBalance(store Store, customerID string, paymentType types.PaymentType, balance types.Amount) (*types.Account, error) {
+	var out *types.Account
+	err := store.Update(func(tx Tx) error {
+		if activeCustomer(tx, customerID) == nil {
+			return notFoundError("customer", customerID)
+		}
+		account := tx.Account(customerID, paymentType)
+		if account == nil {
+			return &Error{Kind: ErrorKindInvalid, Code: CodeParameterInvalid, Param: "type", Message: fmt.Sprintf("payment type %q not supported", paymentType)}
+		}
+		if balance < 0 {
+			return &Error{Kind: ErrorKindInvalid, Code: CodeParameterInvalid, Param: "balance", Message: "balance must not be negative"}
 		}
+		if balance < account.Held {
+			return &Error{
+				Kind:    ErrorKindInvalid,
+				Code:    CodeParameterInvalid,
+				Param:   "balance",
+				Message: fmt.Sprintf("balance must not be less than the %d held on the account", account.Held),
+			}
+		}
+		if diff := balance - account.Balance; diff != 0 {
+			account.Balance = balance
+			recordTransaction(tx, types.TransactionKindAdjustment, account, diff, "", "")
+			emit(tx, types.EventAccountAdjusted, account)
+		}
+		a := *account
+		out = &a
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
+	return out, nil
+}
 
-	account.Balance -= amount
-	transactionID := GenerateTransactionID()
+// ListAccounts returns every account of a customer.
+func ListAccounts(store Store, customerID string) ([]types.Account, error) {
+	accounts := []types.Account{}
+	err := store.View(func(tx ReadTx) error {
+		if activeCustomer(tx, customerID) == nil {
+			return notFoundError("customer", customerID)
+		}
+		for _, account := range tx.Accounts(customerID) {
+			accounts = append(accounts, *account)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return accounts, nil
+}
 
-	return &types.ProcessPaymentResponse{
-		Success:       true,
-		TransactionID: transactionID,
-		Message:       "Payment processed successfully",
-		OrderID:       orderID,
-		Account:       *account,
+// validateMoney rejects non-positive amounts and unsupported currencies. Like
+// Stripe, amounts in three-decimal currencies must be a multiple of 10 so they
+// can be settled in two decimals.
+func validateMoney(money types.Money) error {
+	if err := money.Validate(); err != nil {
+		return &Error{Kind: ErrorKindInvalid, Code: CodeParameterInvalid, Param: "currency", Message: err.Error()}
 	}
+	if money.Amount <= 0 {
+		return &Error{Kind: ErrorKindInvalid, Code: CodeParameterInvalid, Param: "amount", Message: "amount must be greater than zero"}
+	}
+	if exponent, _ := types.CurrencyExponent(money.Currency); exponent == 3 && money.Amount%10 != 0 {
+		return &Error{
+			Kind:    ErrorKindInvalid,
+			Code:    CodeParameterInvalid,
+			Param:   "amount",
+			Message: fmt.Sprintf("amount in %s must be a multiple of 10 minor units", strings.ToUpper(money.Currency)),
+		}
+	}
+	return nil
 }
 
-// GetAccount retrieves account information
-func GetAccount(paymentType types.PaymentType) *types.Account {
-	return MockAccounts[paymentType]
+// checkAccountCurrency describes why currency cannot move money on account,
+// or returns "" when it can. An empty currency means the account's currency.
+func checkAccountCurrency(account *types.Account, currency string) string {
+	if currency == "" {
+		return ""
+	}
+	if _, ok := types.CurrencyExponent(currency); !ok {
+		return fmt.Sprintf("Unsupported currency %s", currency)
+	}
+	if !strings.EqualFold(currency, account.Currency) {
+		return fmt.Sprintf("Currency %s does not match account currency %s", currency, account.Currency)
+	}
+	return ""
 }
@@ -0,0 +1,302 @@
+package data
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nerdgarten/mock-payment-service/types"
+)
+
+// authorizeIntent creates and confirms a manual capture intent for 1200 THB.
+func authorizeIntent(t *testing.T, store Store) *types.PaymentIntent {
+	t.Helper()
+	intent, err := CreateMockPaymentIntent(store, types.Money{Amount: 1200, Currency: "thb"}, "", "pm_card_visa", "", types.CaptureMethodManual)
+	if err != nil {
+		t.Fatalf("CreateMockPaymentIntent: %v", err)
+	}
+	intent, _, err = ConfirmMockPaymentIntent(store, intent.ID, ConfirmParams{})
+	if err != nil {
+		t.Fatalf("ConfirmMockPaymentIntent: %v", err)
+	}
+	return intent
+}
+
+func wantDataError(t *testing.T, err error, kind ErrorKind, code string) {
+	t.Helper()
+	var dataErr *Error
+	if !errors.As(err, &dataErr) || dataErr.Kind != kind || dataErr.Code != code {
+		t.Fatalf("error = %v, want kind %d code %s", err, kind, code)
+	}
+}
+
+func TestManualCaptureAuthorizes(t *testing.T) {
+	store := NewEmptyMemoryStore()
+	before := time.Now()
+	intent := authorizeIntent(t, store)
+	if intent.Status != types.PaymentIntentStatusRequiresCapture || intent.AmountCapturable != 1200 {
+		t.Fatalf("intent status %s capturable %d, want requires_capture 1200", intent.Status, intent.AmountCapturable)
+	}
+	charge := GetMockCharge(store, intent.LatestCharge)
+	if charge.Captured || charge.AmountCaptured != 0 || charge.Status != "succeeded" {
+		t.Errorf("charge = %+v, want an uncaptured succeeded charge", charge)
+	}
+	if deadline := before.Add(DefaultAuthorizationWindow).Unix(); charge.CaptureBefore < deadline || charge.CaptureBefore > deadline+5 {
+		t.Errorf("capture_before = %d, want about %d", charge.CaptureBefore, deadline)
+	}
+}
+
+func TestPartialCapture(t *testing.T) {
+	store := NewEmptyMemoryStore()
+	intent := authorizeIntent(t, store)
+
+	tooLarge, zero := types.Amount(1201), types.Amount(0)
+	_, _, err := CaptureMockPaymentIntent(store, intent.ID, &tooLarge)
+	wantDataError(t, err, ErrorKindInvalid, CodeAmountTooLarge)
+	_, _, err = CaptureMockPaymentIntent(store, intent.ID, &zero)
+	wantDataError(t, err, ErrorKindInvalid, CodeParameterInvalid)
+	if got := GetMockPaymentIntent(store, intent.ID); got.Status != types.PaymentIntentStatusRequiresCapture || got.AmountCapturable != 1200 {
+		t.Fatalf("rejected captures changed the intent to %s capturable %d", got.Status, got.AmountCapturable)
+	}
+
+	amount := types.Amount(800)
+	captured, charges, err := CaptureMockPaymentIntent(store, intent.ID, &amount)
+	if err != nil {
+		t.Fatalf("CaptureMockPaymentIntent: %v", err)
+	}
+	if captured.Status != types.PaymentIntentStatusSucceeded || captured.AmountCapturable != 0 {
+		t.Errorf("captured intent status %s capturable %d, want succeeded 0", captured.Status, captured.AmountCapturable)
+	}
+	if len(charges.Data) != 1 {
+		t.Fatalf("capture returned %d charges, want 1", len(charges.Data))
+	}
+	charge := charges.Data[0]
+	if !charge.Captured || charge.AmountCaptured != 800 || charge.AmountReleased != 400 || charge.Amount != 1200 {
+		t.Errorf("charge captured %t amount_captured %d amount_released %d amount %d, want true 800 400 1200",
+			charge.Captured, charge.AmountCaptured, charge.AmountReleased, charge.Amount)
+	}
+
+	// Only the captured amount can be refunded.
+	refund, err := CreateMockRefund(store, intent.ID, nil, "")
+	if err != nil {
+		t.Fatalf("CreateMockRefund: %v", err)
+	}
+	if refund.Amount != 800 {
+		t.Errorf("refund amount = %d, want 800", refund.Amount)
+	}
+	_, err = CreateMockRefund(store, intent.ID, nil, "")
+	wantDataError(t, err, ErrorKindConflict, CodeChargeAlreadyRefunded)
+
+	_, _, err = CaptureMockPaymentIntent(store, intent.ID, nil)
+	wantDataError(t, err, ErrorKindConflict, CodePaymentIntentUnexpectedState)
+}
+
+func TestFullCapture(t *testing.T) {
+	store := NewEmptyMemoryStore()
+	intent := authorizeIntent(t, store)
+	_, charges, err := CaptureMockPaymentIntent(store, intent.ID, nil)
+	if err != nil {
+		t.Fatalf("CaptureMockPaymentIntent: %v", err)
+	}
+	if charge := charges.Data[0]; charge.AmountCaptured != 1200 || charge.AmountReleased != 0 {
+		t.Errorf("amount_captured %d amount_released %d, want 1200 0", charge.AmountCaptured, charge.AmountReleased)
+	}
+}
+
+func TestCaptureRequiresManualIntent(t *testing.T) {
+	store := NewEmptyMemoryStore()
+	intent, err := CreateMockPaymentIntent(store, types.Money{Amount: 1200, Currency: "thb"}, "", "pm_card_visa", "", "")
+	if err != nil {
+		t.Fatalf("CreateMockPaymentIntent: %v", err)
+	}
+	intent, _, err = ConfirmMockPaymentIntent(store, intent.ID, ConfirmParams{})
+	if err != nil {
+		t.Fatalf("ConfirmMockPaymentIntent: %v", err)
+	}
+	if charge := GetMockCharge(store, intent.LatestCharge); !charge.Captured || charge.AmountCaptured != 1200 || charge.CaptureBefore != 0 {
+		t.Errorf("automatic charge = %+v, want captured at once", charge)
+	}
+	_, _, err = CaptureMockPaymentIntent(store, intent.ID, nil)
+	wantDataError(t, err, ErrorKindConflict, CodePaymentIntentUnexpectedState)
+}
+
+func TestCancelReleasesAuthorization(t *testing.T) {
+	store := NewEmptyMemoryStore()
+	intent := authorizeIntent(t, store)
+	canceled, err := CancelMockPaymentIntent(store, intent.ID, "abandoned")
+	if err != nil {
+		t.Fatalf("CancelMockPaymentIntent: %v", err)
+	}
+	if canceled.Status != types.PaymentIntentStatusCanceled || canceled.AmountCapturable != 0 {
+		t.Errorf("canceled intent status %s capturable %d", canceled.Status, canceled.AmountCapturable)
+	}
+	if charge := GetMockCharge(store, intent.LatestCharge); charge.Captured || charge.AmountReleased != 1200 {
+		t.Errorf("charge captured %t amount_released %d, want false 1200", charge.Captured, charge.AmountReleased)
+	}
+}
+
+func TestExpireMockAuthorizations(t *testing.T) {
+	store := NewEmptyMemoryStore()
+	intent := authorizeIntent(t, store)
+	other := authorizeIntent(t, store)
+	deadline := time.Unix(GetMockCharge(store, intent.LatestCharge).CaptureBefore, 0)
+
+	if n, err := ExpireMockAuthorizations(store, deadline.Add(-time.Second)); err != nil || n != 0 {
+		t.Fatalf("ExpireMockAuthorizations before the deadline = %d, %v, want 0", n, err)
+	}
+	if _, _, err := CaptureMockPaymentIntent(store, other.ID, nil); err != nil {
+		t.Fatalf("CaptureMockPaymentIntent: %v", err)
+	}
+	n, err := ExpireMockAuthorizations(store, deadline.Add(time.Second))
+	if err != nil || n != 1 {
+		t.Fatalf("ExpireMockAuthorizations after the deadline = %d, %v, want 1", n, err)
+	}
+	expired := GetMockPaymentIntent(store, intent.ID)
+	if expired.Status != types.PaymentIntentStatusCanceled || expired.CancellationReason != "expired" || expired.AmountCapturable != 0 {
+		t.Errorf("expired intent status %s reason %q capturable %d", expired.Status, expired.CancellationReason, expired.AmountCapturable)
+	}
+	if charge := GetMockCharge(store, intent.LatestCharge); charge.Captured || charge.AmountReleased != 1200 {
+		t.Errorf("expired charge captured %t amount_released %d, want false 1200", charge.Captured, charge.AmountReleased)
+	}
+	if got := GetMockPaymentIntent(store, other.ID); got.Status != types.PaymentIntentStatusSucceeded {
+		t.Errorf("captured intent status = %s, want succeeded", got.Status)
+	}
+}
+
+func TestCaptureAfterDeadlineExpiresAuthorization(t *testing.T) {
+	store := NewEmptyMemoryStore()
+	intent := authorizeIntent(t, store)
+	_ = store.Update(func(tx Tx) error {
+		tx.Charge(intent.LatestCharge).CaptureBefore = time.Now().Add(-time.Minute).Unix()
+		return nil
+	})
+	_, _, err := CaptureMockPaymentIntent(store, intent.ID, nil)
+	wantDataError(t, err, ErrorKindConflict, CodePaymentIntentUnexpectedState)
+	if got := GetMockPaymentIntent(store, intent.ID); got.Status != types.PaymentIntentStatusCanceled || got.CancellationReason != "expired" {
+		t.Errorf("intent status %s reason %q, want canceled as expired", got.Status, got.CancellationReason)
+	}
+}
+
+func TestCustomersHaveTheirOwnAccounts(t *testing.T) {
+	store := NewEmptyMemoryStore()
+	alice, err := CreateMockCustomer(store, "Alice", "alice@example.com")
+	if err != nil {
+		t.Fatalf("CreateMockCustomer: %v", err)
+	}
+	bob, err := CreateMockCustomer(store, "Bob", "bob@example.com")
+	if err != nil {
+		t.Fatalf("CreateMockCustomer: %v", err)
+	}
+	accounts, err := ListAccounts(store, alice.ID)
+	if err != nil {
+		t.Fatalf("ListAccounts: %v", err)
+	}
+	if len(accounts) != len(types.PaymentTypes) {
+		t.Fatalf("ListAccounts = %d accounts, want %d", len(accounts), len(types.PaymentTypes))
+	}
+	for _, account := range accounts {
+		if account.CustomerID != alice.ID || account.Currency != types.DefaultCurrency || account.Balance != DefaultAccountBalances[account.Type] {
+			t.Errorf("opening account = %+v", account)
+		}
+	}
+
+	if _, err := Deposit(store, alice.ID, types.PaymentTypeCash, types.Money{Amount: 100}); err != nil {
+		t.Fatalf("Deposit: %v", err)
+	}
+	if _, err := Withdraw(store, bob.ID, types.PaymentTypeMeowthWallet, types.Money{Amount: 50}); err != nil {
+		t.Fatalf("Withdraw: %v", err)
+	}
+	tests := []struct {
+		customerID  string
+		paymentType types.PaymentType
+		want        types.Amount
+	}{
+		{alice.ID, types.PaymentTypeCash, DefaultAccountBalances[types.PaymentTypeCash] + 100},
+		{alice.ID, types.PaymentTypeMeowthWallet, DefaultAccountBalances[types.PaymentTypeMeowthWallet]},
+		{bob.ID, types.PaymentTypeCash, DefaultAccountBalances[types.PaymentTypeCash]},
+		{bob.ID, types.PaymentTypeMeowthWallet, DefaultAccountBalances[types.PaymentTypeMeowthWallet] - 50},
+	}
+	for _, tt := range tests {
+		if got := GetAccount(store, tt.customerID, tt.paymentType).Balance; got != tt.want {
+			t.Errorf("%s %s balance = %d, want %d", tt.customerID, tt.paymentType, got, tt.want)
+		}
+	}
+}
+
+func TestAccountsOfUnknownCustomers(t *testing.T) {
+	store := NewEmptyMemoryStore()
+	if account := GetAccount(store, "cus_missing", types.PaymentTypeCash); account != nil {
+		t.Errorf("GetAccount = %+v, want nil", account)
+	}
+	_, err := ListAccounts(store, "cus_missing")
+	wantDataError(t, err, ErrorKindNotFound, CodeResourceMissing)
+	_, err = Deposit(store, "cus_missing", types.PaymentTypeCash, types.Money{Amount: 100})
+	wantDataError(t, err, ErrorKindNotFound, CodeResourceMissing)
+
+	customer, err := CreateMockCustomer(store, "Alice", "")
+	if err != nil {
+		t.Fatalf("CreateMockCustomer: %v", err)
+	}
+	_, err = Deposit(store, customer.ID, types.PaymentType("bitcoin"), types.Money{Amount: 100})
+	wantDataError(t, err, ErrorKindInvalid, CodeParameterInvalid)
+}
+
+func TestCreateMockRefund(t *testing.T) {
+	store := NewEmptyMemoryStore()
+	intent, err := CreateMockPaymentIntent(store, types.Money{Amount: 1200, Currency: "thb"}, "", "pm_card_visa", "", "")
+	if err != nil {
+		t.Fatalf("CreateMockPaymentIntent: %v", err)
+	}
+	_, err = CreateMockRefund(store, intent.ID, nil, "")
+	wantDataError(t, err, ErrorKindConflict, CodePaymentIntentUnexpectedState)
+	intent, _, err = ConfirmMockPaymentIntent(store, intent.ID, ConfirmParams{})
+	if err != nil {
+		t.Fatalf("ConfirmMockPaymentIntent: %v", err)
+	}
+
+	tooLarge, zero, part := types.Amount(1201), types.Amount(0), types.Amount(500)
+	_, err = CreateMockRefund(store, intent.ID, &tooLarge, "")
+	wantDataError(t, err, ErrorKindInvalid, CodeAmountTooLarge)
+	_, err = CreateMockRefund(store, intent.ID, &zero, "")
+	wantDataError(t, err, ErrorKindInvalid, CodeParameterInvalid)
+
+	refund, err := CreateMockRefund(store, intent.ID, &part, "requested_by_customer")
+	if err != nil {
+		t.Fatalf("CreateMockRefund: %v", err)
+	}
+	if refund.Amount != 500 || refund.Charge != intent.LatestCharge || refund.Reason != "requested_by_customer" {
+		t.Errorf("refund = %+v", refund)
+	}
+	charge := GetMockCharge(store, refund.Charge)
+	if charge.AmountRefunded != 500 || charge.Refunded {
+		t.Errorf("partly refunded charge amount_refunded %d refunded %t, want 500 false", charge.AmountRefunded, charge.Refunded)
+	}
+	// The unrefunded 700 can no longer be exceeded.
+	tooLarge = 701
+	_, err = CreateMockRefund(store, intent.ID, &tooLarge, "")
+	wantDataError(t, err, ErrorKindInvalid, CodeAmountTooLarge)
+
+	rest, err := CreateMockRefund(store, intent.ID, nil, "")
+	if err != nil {
+		t.Fatalf("CreateMockRefund of the rest: %v", err)
+	}
+	if rest.Amount != 700 {
+		t.Errorf("refund of the rest = %d, want 700", rest.Amount)
+	}
+	charge = GetMockCharge(store, refund.Charge)
+	if charge.AmountRefunded != 1200 || !charge.Refunded {
+		t.Errorf("refunded charge amount_refunded %d refunded %t, want 1200 true", charge.AmountRefunded, charge.Refunded)
+	}
+	if got := GetMockPaymentIntent(store, intent.ID); got.AmountRefunded != 1200 {
+		t.Errorf("intent amount_refunded = %d, want 1200", got.AmountRefunded)
+	}
+	_, err = CreateMockRefund(store, intent.ID, nil, "")
+	wantDataError(t, err, ErrorKindConflict, CodeChargeAlreadyRefunded)
+
+	_, err = CreateMockRefund(store, "pi_missing", nil, "")
+	wantDataError(t, err, ErrorKindNotFound, CodeResourceMissing)
+	var dataErr *Error
+	if errors.As(err, &dataErr) && dataErr.Param != "payment_intent" {
+		t.Errorf("missing intent param = %q, want payment_intent", dataErr.Param)
+	}
+}
@@ -0,0 +1,255 @@
+package data
+
+import (
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nerdgarten/mock-payment-service/types"
+)
+
+// MobileBankingBanks lists the banks supported by mobilebanking payment methods.
+var MobileBankingBanks = []string{"bay", "bbl", "kbank", "ktb", "scb", "ttb"}
+
+// Cash voucher lifetimes in days.
+const (
+	defaultCashVoucherDays = 3
+	maxCashVoucherDays     = 60
+)
+
+// CreateMockPaymentMethod validates req and stores a new payment method,
+// attaching it to req.Customer when set.
+func CreateMockPaymentMethod(store Store, req types.CreatePaymentMethodRequest) (*types.PaymentMethod, error) {
+	now := time.Now()
+	method := &types.PaymentMethod{Object: "payment_method", Type: req.Type, Created: now.Unix()}
+	if err := checkPaymentMethodDetails(req); err != nil {
+		return nil, err
+	}
+	switch req.Type {
+	case types.PaymentMethodTypeCard:
+		card, err := newCard(req.Card, now)
+		if err != nil {
+			return nil, err
+		}
+		method.Card = card
+	case types.PaymentMethodTypeMobileBanking:
+		if req.MobileBanking == nil || req.MobileBanking.Bank == "" {
+			return nil, &Error{Kind: ErrorKindInvalid, Code: CodeParameterMissing, Param: "mobilebanking[bank]", Message: "mobilebanking[bank] is required"}
+		}
+		bank := strings.ToLower(req.MobileBanking.Bank)
+		if !slices.Contains(MobileBankingBanks, bank) {
+			return nil, &Error{
+				Kind:    ErrorKindInvalid,
+				Code:    CodeParameterInvalid,
+				Param:   "mobilebanking[bank]",
+				Message: fmt.Sprintf("unsupported bank %q, expected one of %s", req.MobileBanking.Bank, strings.Join(MobileBankingBanks, ", ")),
+			}
+		}
+		method.MobileBanking = &types.MobileBanking{Bank: bank}
+	case types.PaymentMethodTypeMeowthWallet:
+		method.MeowthWallet = &types.MeowthWallet{}
+		if req.MeowthWallet != nil {
+			*method.MeowthWallet = *req.MeowthWallet
+		}
+	case types.PaymentMethodTypeCashVoucher:
+		days := defaultCashVoucherDays
+		if req.CashVoucher != nil && req.CashVoucher.ExpiresAfterDays != 0 {
+			days = req.CashVoucher.ExpiresAfterDays
+		}
+		if days < 1 || days > maxCashVoucherDays {
+			return nil, &Error{
+				Kind:    ErrorKindInvalid,
+				Code:    CodeParameterInvalid,
+				Param:   "cash_voucher[expires_after_days]",
+				Message: fmt.Sprintf("cash_voucher[expires_after_days] must be between 1 and %d", maxCashVoucherDays),
+			}
+		}
+		method.CashVoucher = &types.CashVoucher{ExpiresAt: now.AddDate(0, 0, days).Unix()}
+	}
+
+	err := store.Update(func(tx Tx) error {
+		if req.Customer != "" && activeCustomer(tx, req.Customer) == nil {
+			err := notFoundError("customer", req.Customer)
+			err.Param = "customer"
+			return err
+		}
+		method.ID = tx.NewID("pm")
+		if method.CashVoucher != nil {
+			method.CashVoucher.Number = voucherNumber(method.ID)
+		}
+		method.Customer = req.Customer
+		tx.PutPaymentMethod(method)
+		if method.Customer != "" {
+			emit(tx, types.EventPaymentMethodAttached, method)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return clonePaymentMethod(method), nil
+}
+
+// checkPaymentMethodDetails checks that req has a supported type and no
+// details of another type.
+func checkPaymentMethodDetails(req types.CreatePaymentMethodRequest) error {
+	if req.Type == "" {
+		return &Error{Kind: ErrorKindInvalid, Code: CodeParameterMissing, Param: "type", Message: "type is required"}
+	}
+	if !slices.Contains(types.PaymentMethodTypes, req.Type) {
+		return &Error{Kind: ErrorKindInvalid, Code: CodeParameterInvalid, Param: "type", Message: fmt.Sprintf("unsupported payment method type %q", req.Type)}
+	}
+	details := map[types.PaymentMethodType]bool{
+		types.PaymentMethodTypeCard:          req.Card != nil,
+		types.PaymentMethodTypeMobileBanking: req.MobileBanking != nil,
+		types.PaymentMethodTypeMeowthWallet:  req.MeowthWallet != nil,
+		types.PaymentMethodTypeCashVoucher:   req.CashVoucher != nil,
+	}
+	for _, methodType := range types.PaymentMethodTypes {
+		if details[methodType] && methodType != req.Type {
+			return &Error{
+				Kind:    ErrorKindInvalid,
+				Code:    CodeParameterInvalid,
+				Param:   string(methodType),
+				Message: fmt.Sprintf("%s cannot be set on a payment method of type %s", methodType, req.Type),
+			}
+		}
+	}
+	return nil
+}
+
+// voucherNumber derives the 12-digit number printed on a cash voucher from its
+// payment method ID.
+func voucherNumber(id string) string {
+	h := fnv.New64a()
+	h.Write([]byte(id))
+	return fmt.Sprintf("%012d", h.Sum64()%1_000_000_000_000)
+}
+
+// GetMockPaymentMethod retrieves a payment method by ID
+func GetMockPaymentMethod(store Store, id string) *types.PaymentMethod {
+	var out *types.PaymentMethod
+	_ = store.View(func(tx ReadTx) error {
+		if method := tx.PaymentMethod(id); method != nil {
+			out = clonePaymentMethod(method)
+		}
+		return nil
+	})
+	return out
+}
+
+// AttachMockPaymentMethod attaches a payment method to a customer. Attaching
+// it again to the same customer is a no-op.
+func AttachMockPaymentMethod(store Store, id, customerID string) (*types.PaymentMethod, error) {
+	if customerID == "" {
+		return nil, &Error{Kind: ErrorKindInvalid, Code: CodeParameterMissing, Param: "customer", Message: "customer is required"}
+	}
+	var out *types.PaymentMethod
+	err := store.Update(func(tx Tx) error {
+		method := tx.PaymentMethod(id)
+		if method == nil {
+			return notFoundError("payment method", id)
+		}
+		if activeCustomer(tx, customerID) == nil {
+			err := notFoundError("customer", customerID)
+			err.Param = "customer"
+			return err
+		}
+		switch method.Customer {
+		case customerID:
+		case "":
+			method.Customer = customerID
+			emit(tx, types.EventPaymentMethodAttached, method)
+		default:
+			return &Error{
+				Kind:    ErrorKindConflict,
+				Code:    CodePaymentMethodUnexpectedState,
+				Message: fmt.Sprintf("payment method %s is already attached to customer %s", id, method.Customer),
+			}
+		}
+		out = clonePaymentMethod(method)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DetachMockPaymentMethod detaches a payment method from its customer,
+// unsetting it as the customer's default payment method.
+func DetachMockPaymentMethod(store Store, id string) (*types.PaymentMethod, error) {
+	var out *types.PaymentMethod
+	err := store.Update(func(tx Tx) error {
+		method := tx.PaymentMethod(id)
+		if method == nil {
+			return notFoundError("payment method", id)
+		}
+		if method.Customer == "" {
+			return &Error{
+				Kind:    ErrorKindConflict,
+				Code:    CodePaymentMethodUnexpectedState,
+				Message: fmt.Sprintf("payment method %s is not attached to a customer", id),
+			}
+		}
+		if customer := tx.Customer(method.Customer); customer != nil && customer.DefaultPaymentMethod == id {
+			customer.DefaultPaymentMethod = ""
+		}
+		method.Customer = ""
+		emit(tx, types.EventPaymentMethodDetached, method)
+		out = clonePaymentMethod(method)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// checkPaymentMethod rejects a payment method that is neither stored nor a
+// test card token or number. A stored payment method attached to a customer
+// may only pay for that customer.
+func checkPaymentMethod(tx ReadTx, paymentMethod, customerID string) error {
+	if isTestPaymentMethod(paymentMethod) {
+		return nil
+	}
+	method := tx.PaymentMethod(paymentMethod)
+	if method == nil {
+		err := notFoundError("payment method", paymentMethod)
+		err.Param = "payment_method"
+		return err
+	}
+	if method.Customer != "" && method.Customer != customerID {
+		return &Error{
+			Kind:    ErrorKindInvalid,
+			Code:    CodeParameterInvalid,
+			Param:   "payment_method",
+			Message: fmt.Sprintf("payment method %s is attached to customer %s and cannot be used for another customer", paymentMethod, method.Customer),
+		}
+	}
+	return nil
+}
+
+// clonePaymentMethod copies a payment method and its details.
+func clonePaymentMethod(method *types.PaymentMethod) *types.PaymentMethod {
+	c := *method
+	if c.Card != nil {
+		card := *c.Card
+		c.Card = &card
+	}
+	if c.MobileBanking != nil {
+		bank := *c.MobileBanking
+		c.MobileBanking = &bank
+	}
+	if c.MeowthWallet != nil {
+		wallet := *c.MeowthWallet
+		c.MeowthWallet = &wallet
+	}
+	if c.CashVoucher != nil {
+		voucher := *c.CashVoucher
+		c.CashVoucher = &voucher
+	}
+	return &c
+}
@@ -0,0 +1,128 @@
+package data
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nerdgarten/mock-payment-service/types"
+)
+
+func createCard(t *testing.T, store Store, customerID string) *types.PaymentMethod {
+	t.Helper()
+	method, err := CreateMockPaymentMethod(store, types.CreatePaymentMethodRequest{
+		Type:     types.PaymentMethodTypeCard,
+		Card:     &types.CardParams{Number: "4242424242424242", ExpMonth: 12, ExpYear: 2099, CVC: "123"},
+		Customer: customerID,
+	})
+	if err != nil {
+		t.Fatalf("CreateMockPaymentMethod: %v", err)
+	}
+	return method
+}
+
+func TestCreateMockPaymentMethod(t *testing.T) {
+	store := NewEmptyMemoryStore()
+	card := createCard(t, store, "")
+	if card.Object != "payment_method" || card.Card == nil || card.Card.Last4 != "4242" || card.Customer != "" {
+		t.Errorf("card payment method = %+v", card)
+	}
+
+	bank, err := CreateMockPaymentMethod(store, types.CreatePaymentMethodRequest{Type: types.PaymentMethodTypeMobileBanking, MobileBanking: &types.MobileBanking{Bank: "KBank"}})
+	if err != nil {
+		t.Fatalf("CreateMockPaymentMethod(mobilebanking): %v", err)
+	}
+	if bank.MobileBanking.Bank != "kbank" {
+		t.Errorf("bank = %q, want kbank", bank.MobileBanking.Bank)
+	}
+
+	before := time.Now()
+	voucher, err := CreateMockPaymentMethod(store, types.CreatePaymentMethodRequest{Type: types.PaymentMethodTypeCashVoucher})
+	if err != nil {
+		t.Fatalf("CreateMockPaymentMethod(cash_voucher): %v", err)
+	}
+	if len(voucher.CashVoucher.Number) != 12 || voucher.CashVoucher.ExpiresAt < before.AddDate(0, 0, defaultCashVoucherDays).Unix() {
+		t.Errorf("cash voucher = %+v", voucher.CashVoucher)
+	}
+
+	// Payment methods can pay for intents once stored.
+	if _, err := CreateMockPaymentIntent(store, types.Money{Amount: 1200, Currency: "thb"}, "", card.ID, "", ""); err != nil {
+		t.Errorf("CreateMockPaymentIntent with a stored card: %v", err)
+	}
+}
+
+func TestCreateMockPaymentMethodRejectsInvalidRequests(t *testing.T) {
+	tests := []struct {
+		name  string
+		req   types.CreatePaymentMethodRequest
+		kind  ErrorKind
+		code  string
+		param string
+	}{
+		{"missing type", types.CreatePaymentMethodRequest{}, ErrorKindInvalid, CodeParameterMissing, "type"},
+		{"unsupported type", types.CreatePaymentMethodRequest{Type: "crypto"}, ErrorKindInvalid, CodeParameterInvalid, "type"},
+		{"details of another type", types.CreatePaymentMethodRequest{Type: types.PaymentMethodTypeMeowthWallet, MobileBanking: &types.MobileBanking{Bank: "scb"}}, ErrorKindInvalid, CodeParameterInvalid, "mobilebanking"},
+		{"missing bank", types.CreatePaymentMethodRequest{Type: types.PaymentMethodTypeMobileBanking}, ErrorKindInvalid, CodeParameterMissing, "mobilebanking[bank]"},
+		{"unsupported bank", types.CreatePaymentMethodRequest{Type: types.PaymentMethodTypeMobileBanking, MobileBanking: &types.MobileBanking{Bank: "acme"}}, ErrorKindInvalid, CodeParameterInvalid, "mobilebanking[bank]"},
+		{"voucher lifetime", types.CreatePaymentMethodRequest{Type: types.PaymentMethodTypeCashVoucher, CashVoucher: &types.CashVoucherParams{ExpiresAfterDays: maxCashVoucherDays + 1}}, ErrorKindInvalid, CodeParameterInvalid, "cash_voucher[expires_after_days]"},
+		{"unknown customer", types.CreatePaymentMethodRequest{Type: types.PaymentMethodTypeMeowthWallet, Customer: "cus_missing"}, ErrorKindNotFound, CodeResourceMissing, "customer"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := CreateMockPaymentMethod(NewEmptyMemoryStore(), tt.req)
+			wantDataError(t, err, tt.kind, tt.code)
+			if dataErr := err.(*Error); dataErr.Param != tt.param {
+				t.Errorf("param = %q, want %q", dataErr.Param, tt.param)
+			}
+		})
+	}
+}
+
+func TestAttachAndDetachPaymentMethod(t *testing.T) {
+	store := NewEmptyMemoryStore()
+	alice, err := CreateMockCustomer(store, "Alice", "")
+	if err != nil {
+		t.Fatalf("CreateMockCustomer: %v", err)
+	}
+	bob, err := CreateMockCustomer(store, "Bob", "")
+	if err != nil {
+		t.Fatalf("CreateMockCustomer: %v", err)
+	}
+	card := createCard(t, store, "")
+
+	_, err = AttachMockPaymentMethod(store, card.ID, "")
+	wantDataError(t, err, ErrorKindInvalid, CodeParameterMissing)
+	_, err = AttachMockPaymentMethod(store, "pm_missing", alice.ID)
+	wantDataError(t, err, ErrorKindNotFound, CodeResourceMissing)
+	_, err = DetachMockPaymentMethod(store, card.ID)
+	wantDataError(t, err, ErrorKindConflict, CodePaymentMethodUnexpectedState)
+
+	attached, err := AttachMockPaymentMethod(store, card.ID, alice.ID)
+	if err != nil || attached.Customer != alice.ID {
+		t.Fatalf("AttachMockPaymentMethod = %+v, %v", attached, err)
+	}
+	if _, err := AttachMockPaymentMethod(store, card.ID, alice.ID); err != nil {
+		t.Errorf("attaching again to the same customer: %v", err)
+	}
+	_, err = AttachMockPaymentMethod(store, card.ID, bob.ID)
+	wantDataError(t, err, ErrorKindConflict, CodePaymentMethodUnexpectedState)
+
+	// An attached payment method only pays for its customer.
+	_, err = CreateMockPaymentIntent(store, types.Money{Amount: 1200, Currency: "thb"}, bob.ID, card.ID, "", "")
+	wantDataError(t, err, ErrorKindInvalid, CodeParameterInvalid)
+	if _, err := CreateMockPaymentIntent(store, types.Money{Amount: 1200, Currency: "thb"}, alice.ID, card.ID, "", ""); err != nil {
+		t.Errorf("CreateMockPaymentIntent for the card's customer: %v", err)
+	}
+
+	if _, err := UpdateMockCustomer(store, alice.ID, nil, nil, &card.ID); err != nil {
+		t.Fatalf("UpdateMockCustomer: %v", err)
+	}
+	detached, err := DetachMockPaymentMethod(store, card.ID)
+	if err != nil || detached.Customer != "" {
+		t.Fatalf("DetachMockPaymentMethod = %+v, %v", detached, err)
+	}
+	if got := GetMockCustomer(store, alice.ID); got.DefaultPaymentMethod != "" {
+		t.Errorf("default payment method = %q after detach, want none", got.DefaultPaymentMethod)
+	}
+	_, err = UpdateMockCustomer(store, alice.ID, nil, nil, &card.ID)
+	wantDataError(t, err, ErrorKindInvalid, CodeParameterInvalid)
+}
@@ -0,0 +1,95 @@
+package data
+
+import (
+	"time"
+
+	"github.com/nerdgarten/mock-payment-service/types"
+)
+
+// ReadTx exposes read access to the mock datasets inside a transaction.
+// Returned pointers must not be retained or modified after the transaction ends.
+type ReadTx interface {
+	Customer(id string) *types.Customer
+	// Customers returns every customer in creation order.
+	Customers() []*types.Customer
+	PaymentMethod(id string) *types.PaymentMethod
+	// PaymentMethods returns every payment method in creation order.
+	PaymentMethods() []*types.PaymentMethod
+	PaymentIntent(id string) *types.PaymentIntent
+	// PaymentIntents returns every payment intent in creation order.
+	PaymentIntents() []*types.PaymentIntent
+	// PaymentIntentsByCustomer returns a customer's payment intents in
+	// creation order.
+	PaymentIntentsByCustomer(customerID string) []*types.PaymentIntent
+	Charge(id string) *types.Charge
+	// Charges returns every charge in creation order.
+	Charges() []*types.Charge
+	// ChargesByCustomer returns a customer's charges in creation order.
+	ChargesByCustomer(customerID string) []*types.Charge
+	Refund(id string) *types.Refund
+	// Refunds returns every refund in creation order.
+	Refunds() []*types.Refund
+	// RefundsByPaymentIntent returns the refunds of a payment intent in
+	// creation order.
+	RefundsByPaymentIntent(paymentIntent string) []*types.Refund
+	Hold(id string) *types.Hold
+	// Holds returns every hold in creation order.
+	Holds() []*types.Hold
+	Account(customerID string, paymentType types.PaymentType) *types.Account
+	// Accounts returns a customer's accounts ordered as types.PaymentTypes.
+	Accounts(customerID string) []*types.Account
+	WebhookEndpoint(id string) *types.WebhookEndpoint
+	WebhookEndpoints() []*types.WebhookEndpoint
+	Transaction(id string) *types.Transaction
+	// Transactions returns the ledger in the order it was written.
+	Transactions() []*types.Transaction
+}
+
+// Tx exposes read and write access to the mock datasets inside a transaction.
+type Tx interface {
+	ReadTx
+	PutCustomer(customer *types.Customer)
+	PutPaymentMethod(method *types.PaymentMethod)
+	PutPaymentIntent(intent *types.PaymentIntent)
+	PutCharge(charge *types.Charge)
+	PutRefund(refund *types.Refund)
+	PutHold(hold *types.Hold)
+	// PutAccount stores account under its customer ID and payment type.
+	PutAccount(account *types.Account)
+	PutWebhookEndpoint(endpoint *types.WebhookEndpoint)
+	DeleteWebhookEndpoint(id string)
+	// AppendTransaction adds a transaction to the end of the ledger.
+	AppendTransaction(txn *types.Transaction)
+	// Clear removes every customer, payment method, payment intent, charge,
+	// refund, hold, account and ledger transaction. Webhook endpoints are kept.
+	Clear()
+	// NewID returns a new unique ID with the given prefix, e.g. "cus". If no
+	// unique ID can be generated, it returns "" and the transaction fails with
+	// an error once its callback returns.
+	NewID(prefix string) string
+	// ResetIDs restarts the store's ID sequence if its generator is a
+	// ResettableIDGenerator.
+	ResetIDs()
+	// AuthorizationWindow is how long a new manual-capture authorization stays
+	// capturable.
+	AuthorizationWindow() time.Duration
+	// HoldTTL is how long a new hold reserves funds before it expires.
+	HoldTTL() time.Duration
+	// Emit queues an event for the store's subscribers. Events are delivered
+	// only after the transaction completes without error.
+	Emit(event types.Event)
+}
+
+// Store persists customers, payment methods, payment intents, charges, refunds,
+// holds, accounts, the transaction ledger and webhook endpoints. Implementations
+// must be safe for concurrent use; every callback runs isolated from other
+// transactions on the same store.
+type Store interface {
+	View(fn func(tx ReadTx) error) error
+	// Update runs fn atomically: if fn returns an error or panics, none of its
+	// writes are kept and none of its events are published.
+	Update(fn func(tx Tx) error) error
+	// Subscribe registers fn to receive every event emitted by a committed
+	// transaction. fn is called outside the store's lock.
+	Subscribe(fn func(event types.Event))
+}
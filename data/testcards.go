@@ -0,0 +1,176 @@
+package data
+
+import (
+	"strings"
+
+	"github.com/nerdgarten/mock-payment-service/types"
+)
+
+// testCardDecline is the failure produced by a magic test card.
+type testCardDecline struct {
+	code        string
+	declineCode string
+	message     string
+}
+
+var (
+	declineGeneric = testCardDecline{"card_declined", "generic_decline", "Your card was declined."}
+	declineFunds   = testCardDecline{"card_declined", "insufficient_funds", "Your card has insufficient funds."}
+	declineLost    = testCardDecline{"card_declined", "lost_card", "Your card was declined."}
+	declineStolen  = testCardDecline{"card_declined", "stolen_card", "Your card was declined."}
+	declineFraud   = testCardDecline{"card_declined", "fraudulent", "Your card was declined."}
+	declineExpired = testCardDecline{"expired_card", "expired_card", "Your card has expired."}
+	declineCVC     = testCardDecline{"incorrect_cvc", "incorrect_cvc", "Your card's security code is incorrect."}
+	declineError   = testCardDecline{"processing_error", "processing_error", "An error occurred while processing your card. Try again in a little bit."}
+)
+
+// testCardDeclines mirrors Stripe's catalog of declining test card numbers and
+// payment method tokens. Any other accepted payment method is approved.
+var testCardDeclines = map[string]testCardDecline{
+	"4000000000000002": declineGeneric,
+	"4000000000009995": declineFunds,
+	"4000000000009987": declineLost,
+	"4000000000009979": declineStolen,
+	"4100000000000019": declineFraud,
+	"4000000000000069": declineExpired,
+	"4000000000000127": declineCVC,
+	"4000000000000119": declineError,
+
+	"pm_card_chargeDeclined":                  declineGeneric,
+	"pm_card_visa_chargeDeclined":             declineGeneric,
+	"pm_card_chargeDeclinedInsufficientFunds": declineFunds,
+	"pm_card_insufficientFunds":               declineFunds,
+	"pm_card_chargeDeclinedLostCard":          declineLost,
+	"pm_card_chargeDeclinedStolenCard":        declineStolen,
+	"pm_card_chargeDeclinedFraudulent":        declineFraud,
+	"pm_card_chargeDeclinedExpiredCard":       declineExpired,
+	"pm_card_chargeDeclinedIncorrectCvc":      declineCVC,
+	"pm_card_chargeDeclinedProcessingError":   declineError,
+
+	// Declined after a completed 3-D Secure challenge.
+	"4000008260003178": declineFunds,
+	"pm_card_authenticationRequiredChargeDeclinedInsufficientFunds": declineFunds,
+}
+
+// threeDSecureCards are the test card numbers and tokens whose payments
+// require a 3-D Secure challenge before they are charged.
+var threeDSecureCards = map[string]bool{
+	"4000002500003155": true,
+	"4000002760003184": true,
+	"4000008260003178": true,
+
+	"pm_card_authenticationRequiredOnSetup":                         true,
+	"pm_card_authenticationRequired":                                true,
+	"pm_card_authenticationRequiredChargeDeclinedInsufficientFunds": true,
+}
+
+// testCardTokens are Stripe's approving test payment method tokens, mapped to
+// their card brand. They and the numbers and tokens of the declining and 3-D
+// Secure test cards are accepted wherever a payment method ID is expected.
+var testCardTokens = map[string]string{
+	"pm_card_visa":       "visa",
+	"pm_card_visa_debit": "visa",
+	"pm_card_mastercard": "mastercard",
+	"pm_card_amex":       "amex",
+	"pm_card_discover":   "discover",
+	"pm_card_jcb":        "jcb",
+	"pm_card_unionpay":   "unionpay",
+}
+
+// approvingTestCards are Stripe's approving test card numbers. Their brand
+// follows from the number.
+var approvingTestCards = map[string]bool{
+	"4242424242424242": true,
+	"4000056655665556": true,
+	"5555555555554444": true,
+	"2223003122003222": true,
+	"5200828282828210": true,
+	"5105105105105100": true,
+	"378282246310005":  true,
+	"371449635398431":  true,
+	"6011111111111117": true,
+	"6011000990139424": true,
+	"3056930009020004": true,
+	"36227206271667":   true,
+	"3566002020360505": true,
+	"6200000000000005": true,
+}
+
+// isTestPaymentMethod reports whether paymentMethod is a test token or a magic
+// test card number, approving or not, rather than a stored payment method.
+func isTestPaymentMethod(paymentMethod string) bool {
+	if _, ok := testCardTokens[paymentMethod]; ok {
+		return true
+	}
+	number := normalizeCardNumber(paymentMethod)
+	_, ok := testCardDeclines[number]
+	return ok || threeDSecureCards[number] || approvingTestCards[number]
+}
+
+// normalizeCardNumber removes the spaces and dashes of a formatted card number.
+func normalizeCardNumber(number string) string {
+	return strings.NewReplacer(" ", "", "-", "").Replace(number)
+}
+
+// testCardFingerprints maps the fingerprints of the magic test card numbers to
+// the numbers, so that payment methods created from them behave alike.
+var testCardFingerprints = func() map[string]string {
+	fingerprints := make(map[string]string)
+	for number := range testCardDeclines {
+		if !strings.HasPrefix(number, "pm_") {
+			fingerprints[cardFingerprint(number)] = number
+		}
+	}
+	for number := range threeDSecureCards {
+		if !strings.HasPrefix(number, "pm_") {
+			fingerprints[cardFingerprint(number)] = number
+		}
+	}
+	return fingerprints
+}()
+
+// testCard returns the magic test card number or token that paymentMethod
+// stands for. Stored cards stand for the number they were created from.
+func testCard(tx ReadTx, paymentMethod string) string {
+	method := tx.PaymentMethod(paymentMethod)
+	if method == nil {
+		return normalizeCardNumber(paymentMethod)
+	}
+	if method.Card == nil {
+		return ""
+	}
+	return testCardFingerprints[method.Card.Fingerprint]
+}
+
+// paymentMethodDecline returns the payment error paymentMethod produces, or
+// nil if it is approved.
+func paymentMethodDecline(tx ReadTx, paymentMethod string) *types.PaymentError {
+	paymentErr := TestCardDecline(testCard(tx, paymentMethod))
+	if paymentErr != nil {
+		paymentErr.PaymentMethod = paymentMethod
+	}
+	return paymentErr
+}
+
+// requiresAuthentication reports whether paying with paymentMethod requires a
+// 3-D Secure challenge.
+func requiresAuthentication(tx ReadTx, paymentMethod string) bool {
+	return threeDSecureCards[testCard(tx, paymentMethod)]
+}
+
+// TestCardDecline returns the payment error a magic test card number or
+// payment method token produces, or nil if the card is approved. Spaces and
+// dashes in card numbers are ignored.
+func TestCardDecline(paymentMethod string) *types.PaymentError {
+	decline, ok := testCardDeclines[normalizeCardNumber(paymentMethod)]
+	if !ok {
+		return nil
+	}
+	return &types.PaymentError{
+		Type:          "card_error",
+		Code:          decline.code,
+		DeclineCode:   decline.declineCode,
+		Message:       decline.message,
+		PaymentMethod: paymentMethod,
+	}
+}
@@ -0,0 +1,166 @@
+package data
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/nerdgarten/mock-payment-service/types"
+)
+
+func TestTestCardTablesAreAccepted(t *testing.T) {
+	var numbers []string
+	for number := range approvingTestCards {
+		numbers = append(numbers, number)
+	}
+	for number := range testCardDeclines {
+		numbers = append(numbers, number)
+	}
+	for number := range threeDSecureCards {
+		numbers = append(numbers, number)
+	}
+	for token := range testCardTokens {
+		numbers = append(numbers, token)
+	}
+	for _, number := range numbers {
+		if !isTestPaymentMethod(number) {
+			t.Errorf("isTestPaymentMethod(%q) = false", number)
+		}
+		if strings.HasPrefix(number, "pm_") {
+			continue
+		}
+		if !luhnValid(number) {
+			t.Errorf("test card %s fails the Luhn check", number)
+		}
+		if brand := cardBrand(number); brand == "unknown" {
+			t.Errorf("test card %s has no brand", number)
+		}
+	}
+	for _, other := range []string{"4111111111111112", "pm_card_unknown", ""} {
+		if isTestPaymentMethod(other) {
+			t.Errorf("isTestPaymentMethod(%q) = true", other)
+		}
+	}
+}
+
+func TestApprovingTestCardsCreateAndConfirmIntents(t *testing.T) {
+	for _, paymentMethod := range []string{"4242424242424242", "4242 4242 4242 4242", "5555-5555-5555-4444", "pm_card_visa"} {
+		t.Run(paymentMethod, func(t *testing.T) {
+			store := NewEmptyMemoryStore()
+			intent, err := CreateMockPaymentIntent(store, types.Money{Amount: 1200, Currency: "thb"}, "", paymentMethod, "", "")
+			if err != nil {
+				t.Fatalf("CreateMockPaymentIntent: %v", err)
+			}
+			if intent.Status != types.PaymentIntentStatusRequiresConfirmation {
+				t.Errorf("status = %s, want requires_confirmation", intent.Status)
+			}
+			intent, _, err = ConfirmMockPaymentIntent(store, intent.ID, ConfirmParams{})
+			if err != nil {
+				t.Fatalf("ConfirmMockPaymentIntent: %v", err)
+			}
+			if intent.Status != types.PaymentIntentStatusSucceeded {
+				t.Errorf("confirmed status = %s, want succeeded", intent.Status)
+			}
+		})
+	}
+}
+
+func TestUnknownCardNumberIsNotFound(t *testing.T) {
+	store := NewEmptyMemoryStore()
+	_, err := CreateMockPaymentIntent(store, types.Money{Amount: 1200, Currency: "thb"}, "", "4111111111111112", "", "")
+	var dataErr *Error
+	if !errors.As(err, &dataErr) || dataErr.Kind != ErrorKindNotFound || dataErr.Param != "payment_method" {
+		t.Errorf("CreateMockPaymentIntent error = %v, want payment_method not found", err)
+	}
+}
+
+func TestTestCardDecline(t *testing.T) {
+	tests := []struct {
+		paymentMethod string
+		code          string
+		declineCode   string
+	}{
+		{"4000000000000002", "card_declined", "generic_decline"},
+		{"4000 0000 0000 9995", "card_declined", "insufficient_funds"},
+		{"4000-0000-0000-9987", "card_declined", "lost_card"},
+		{"4000000000000069", "expired_card", "expired_card"},
+		{"4000000000000127", "incorrect_cvc", "incorrect_cvc"},
+		{"pm_card_chargeDeclinedFraudulent", "card_declined", "fraudulent"},
+		{"pm_card_chargeDeclinedProcessingError", "processing_error", "processing_error"},
+		{"4242424242424242", "", ""},
+		{"pm_card_visa", "", ""},
+	}
+	for _, tt := range tests {
+		got := TestCardDecline(tt.paymentMethod)
+		if tt.code == "" {
+			if got != nil {
+				t.Errorf("TestCardDecline(%q) = %+v, want nil", tt.paymentMethod, got)
+			}
+			continue
+		}
+		if got == nil || got.Type != "card_error" || got.Code != tt.code || got.DeclineCode != tt.declineCode || got.PaymentMethod != tt.paymentMethod {
+			t.Errorf("TestCardDecline(%q) = %+v, want %s %s", tt.paymentMethod, got, tt.code, tt.declineCode)
+		}
+	}
+}
+
+func TestDecliningCardFailsConfirm(t *testing.T) {
+	store := NewEmptyMemoryStore()
+	intent, err := CreateMockPaymentIntent(store, types.Money{Amount: 1200, Currency: "thb"}, "", "4000000000009995", "", "")
+	if err != nil {
+		t.Fatalf("CreateMockPaymentIntent: %v", err)
+	}
+	confirmed, charges, err := ConfirmMockPaymentIntent(store, intent.ID, ConfirmParams{})
+	var dataErr *Error
+	if !errors.As(err, &dataErr) || dataErr.Kind != ErrorKindPaymentFailed || dataErr.DeclineCode != "insufficient_funds" {
+		t.Fatalf("ConfirmMockPaymentIntent error = %v, want an insufficient_funds decline", err)
+	}
+	if confirmed.Status != types.PaymentIntentStatusRequiresPaymentMethod || confirmed.LastPaymentError == nil || confirmed.LastPaymentError.DeclineCode != "insufficient_funds" {
+		t.Errorf("declined intent status %s last_payment_error %+v", confirmed.Status, confirmed.LastPaymentError)
+	}
+	if charge := charges.Data[0]; charge.Status != "failed" || charge.Captured || charge.DeclineCode != "insufficient_funds" {
+		t.Errorf("declined charge = %+v", charge)
+	}
+
+	// A new payment method lets the intent be confirmed again.
+	confirmed, _, err = ConfirmMockPaymentIntent(store, intent.ID, ConfirmParams{PaymentMethod: "pm_card_visa"})
+	if err != nil {
+		t.Fatalf("ConfirmMockPaymentIntent with a new payment method: %v", err)
+	}
+	if confirmed.Status != types.PaymentIntentStatusSucceeded || confirmed.LastPaymentError != nil {
+		t.Errorf("retried intent status %s last_payment_error %+v", confirmed.Status, confirmed.LastPaymentError)
+	}
+}
+
+func TestDecliningCardFailsProcessPayment(t *testing.T) {
+	store := NewMemoryStore()
+	before := GetAccount(store, "cus_mock_12345", types.PaymentTypeCreditCard).Balance
+	resp, err := ProcessPayment(store, "cus_mock_12345", types.PaymentTypeCreditCard, types.Money{Amount: 1000}, "order_1", "pm_card_chargeDeclinedStolenCard")
+	var dataErr *Error
+	if !errors.As(err, &dataErr) || dataErr.Kind != ErrorKindPaymentFailed || dataErr.DeclineCode != "stolen_card" {
+		t.Fatalf("ProcessPayment error = %v, want a stolen_card decline", err)
+	}
+	if resp.Success || resp.Charge == nil || resp.Charge.Status != "failed" || resp.DeclineCode != "stolen_card" {
+		t.Errorf("declined payment response = %+v", resp)
+	}
+	if after := GetAccount(store, "cus_mock_12345", types.PaymentTypeCreditCard).Balance; after != before {
+		t.Errorf("declined payment changed the balance from %d to %d", before, after)
+	}
+	if charge := GetMockCharge(store, resp.Charge.ID); charge == nil || charge.Status != "failed" {
+		t.Errorf("stored charge = %+v, want the failed charge", charge)
+	}
+}
+
+func TestUnknownPaymentMethodFailsProcessPayment(t *testing.T) {
+	store := NewMemoryStore()
+	before := TakeSnapshot(store)
+	_, err := ProcessPayment(store, "cus_mock_12345", types.PaymentTypeCreditCard, types.Money{Amount: 1000}, "order_1", "pm_missing")
+	wantDataError(t, err, ErrorKindNotFound, CodeResourceMissing)
+	if dataErr := err.(*Error); dataErr.Param != "payment_method" {
+		t.Errorf("param = %q, want payment_method", dataErr.Param)
+	}
+	if after := TakeSnapshot(store); !reflect.DeepEqual(after, before) {
+		t.Error("a payment with an unknown payment method changed the store")
+	}
+}
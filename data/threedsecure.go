@@ -0,0 +1,76 @@
+package data
+
+import (
+	"strings"
+
+	"github.com/nerdgarten/mock-payment-service/types"
+)
+
+// The code and decline code of the last payment error of an intent whose
+// 3-D Secure challenge failed.
+const (
+	codeAuthenticationFailure         = "payment_intent_authentication_failure"
+	declineCodeAuthenticationRequired = "authentication_required"
+)
+
+// GetMockPaymentIntentBySecret retrieves a payment intent by its client secret.
+func GetMockPaymentIntentBySecret(store Store, clientSecret string) *types.PaymentIntent {
+	id, _, _ := strings.Cut(clientSecret, "_secret_")
+	intent := GetMockPaymentIntent(store, id)
+	if intent == nil || intent.ClientSecret != clientSecret {
+		return nil
+	}
+	return intent
+}
+
+// AuthenticateMockPaymentIntent submits the 3-D Secure challenge of the intent
+// with clientSecret, which must be in requires_action. A passed challenge
+// charges the intent like a confirmation, so a declining test card still moves
+// it to requires_payment_method. A failed challenge moves it to
+// requires_payment_method without a charge. It also returns the return URL the
+// challenge was started with, if any.
+func AuthenticateMockPaymentIntent(store Store, clientSecret string, passed bool) (*types.PaymentIntent, string, error) {
+	id, _, _ := strings.Cut(clientSecret, "_secret_")
+	var (
+		intent    types.PaymentIntent
+		returnURL string
+	)
+	err := store.Update(func(tx Tx) error {
+		stored := tx.PaymentIntent(id)
+		if stored == nil || stored.ClientSecret != clientSecret {
+			return notFoundError("payment intent", id)
+		}
+		if action := stored.NextAction; action != nil && action.RedirectToURL != nil {
+			returnURL = action.RedirectToURL.ReturnURL
+		}
+		next := *stored
+		if !passed {
+			if err := transitionPaymentIntent(&next, "authenticate", types.PaymentIntentStatusRequiresPaymentMethod); err != nil {
+				return err
+			}
+			next.NextAction = nil
+			next.LastPaymentError = &types.PaymentError{
+				Type:          string(types.ErrorTypeCard),
+				Code:          codeAuthenticationFailure,
+				DeclineCode:   declineCodeAuthenticationRequired,
+				Message:       "The provided payment method has failed authentication. Provide a new payment method to attempt to fulfill this payment intent again.",
+				PaymentMethod: next.PaymentMethod,
+			}
+			*stored = next
+			intent = next
+			emit(tx, types.EventPaymentIntentFailed, stored)
+			return nil
+		}
+		if err := transitionPaymentIntent(&next, "authenticate", types.PaymentIntentStatusProcessing); err != nil {
+			return err
+		}
+		chargePaymentIntent(tx, &next)
+		*stored = next
+		intent = next
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return &intent, returnURL, nil
+}
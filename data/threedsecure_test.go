@@ -0,0 +1,108 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/nerdgarten/mock-payment-service/types"
+)
+
+// requireAction creates an intent paid with paymentMethod and confirms it into
+// requires_action.
+func requireAction(t *testing.T, store Store, paymentMethod string) *types.PaymentIntent {
+	t.Helper()
+	intent, err := CreateMockPaymentIntent(store, types.Money{Amount: 1200, Currency: "thb"}, "", paymentMethod, "", "")
+	if err != nil {
+		t.Fatalf("CreateMockPaymentIntent: %v", err)
+	}
+	intent, charges, err := ConfirmMockPaymentIntent(store, intent.ID, ConfirmParams{
+		ReturnURL:    "https://shop.example/return",
+		ChallengeURL: "https://mock.example/3ds/tenant_default/",
+	})
+	if err != nil {
+		t.Fatalf("ConfirmMockPaymentIntent: %v", err)
+	}
+	if intent.Status != types.PaymentIntentStatusRequiresAction || len(charges.Data) != 0 {
+		t.Fatalf("confirmed intent status %s with %d charges, want requires_action without charges", intent.Status, len(charges.Data))
+	}
+	return intent
+}
+
+func TestThreeDSecureCardRequiresAction(t *testing.T) {
+	store := NewEmptyMemoryStore()
+	intent := requireAction(t, store, "4000002500003155")
+	action := intent.NextAction
+	if action == nil || action.Type != "redirect_to_url" || action.RedirectToURL == nil {
+		t.Fatalf("next_action = %+v, want redirect_to_url", action)
+	}
+	if want := "https://mock.example/3ds/tenant_default/" + intent.ClientSecret; action.RedirectToURL.URL != want {
+		t.Errorf("challenge URL = %s, want %s", action.RedirectToURL.URL, want)
+	}
+	if action.RedirectToURL.ReturnURL != "https://shop.example/return" {
+		t.Errorf("return URL = %s", action.RedirectToURL.ReturnURL)
+	}
+	if got := GetMockPaymentIntentBySecret(store, intent.ClientSecret); got == nil || got.ID != intent.ID {
+		t.Errorf("GetMockPaymentIntentBySecret = %+v, want %s", got, intent.ID)
+	}
+	if got := GetMockPaymentIntentBySecret(store, intent.ID+"_secret_wrong"); got != nil {
+		t.Errorf("GetMockPaymentIntentBySecret with a wrong secret = %+v, want nil", got)
+	}
+}
+
+func TestAuthenticateMockPaymentIntent(t *testing.T) {
+	tests := []struct {
+		name          string
+		paymentMethod string
+		passed        bool
+		status        types.PaymentIntentStatus
+		errorCode     string
+	}{
+		{"passed", "4000002500003155", true, types.PaymentIntentStatusSucceeded, ""},
+		{"failed", "pm_card_authenticationRequired", false, types.PaymentIntentStatusRequiresPaymentMethod, codeAuthenticationFailure},
+		{"passed then declined", "4000008260003178", true, types.PaymentIntentStatusRequiresPaymentMethod, "card_declined"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewEmptyMemoryStore()
+			intent := requireAction(t, store, tt.paymentMethod)
+			got, returnURL, err := AuthenticateMockPaymentIntent(store, intent.ClientSecret, tt.passed)
+			if err != nil {
+				t.Fatalf("AuthenticateMockPaymentIntent: %v", err)
+			}
+			if returnURL != "https://shop.example/return" {
+				t.Errorf("return URL = %q, want the one given at confirmation", returnURL)
+			}
+			if got.Status != tt.status || got.NextAction != nil {
+				t.Errorf("intent status %s next_action %+v, want %s without next action", got.Status, got.NextAction, tt.status)
+			}
+			var code string
+			if got.LastPaymentError != nil {
+				code = got.LastPaymentError.Code
+			}
+			if code != tt.errorCode {
+				t.Errorf("last_payment_error code = %q, want %q", code, tt.errorCode)
+			}
+			if !tt.passed {
+				if paymentErr := got.LastPaymentError; paymentErr.Type != string(types.ErrorTypeCard) || paymentErr.DeclineCode != declineCodeAuthenticationRequired {
+					t.Errorf("last_payment_error = %+v, want a card_error declined with %s", paymentErr, declineCodeAuthenticationRequired)
+				}
+			}
+			// A failed challenge charges nothing.
+			if charged := got.LatestCharge != ""; charged != tt.passed {
+				t.Errorf("latest_charge = %q after a challenge that passed %t", got.LatestCharge, tt.passed)
+			}
+
+			_, _, err = AuthenticateMockPaymentIntent(store, intent.ClientSecret, true)
+			wantDataError(t, err, ErrorKindConflict, CodePaymentIntentUnexpectedState)
+		})
+	}
+}
+
+func TestAuthenticateMockPaymentIntentRequiresTheClientSecret(t *testing.T) {
+	store := NewEmptyMemoryStore()
+	intent := requireAction(t, store, "4000002500003155")
+	_, _, err := AuthenticateMockPaymentIntent(store, intent.ID+"_secret_wrong", true)
+	wantDataError(t, err, ErrorKindNotFound, CodeResourceMissing)
+	if got := GetMockPaymentIntent(store, intent.ID); got.Status != types.PaymentIntentStatusRequiresAction {
+		t.Errorf("intent status = %s, want requires_action", got.Status)
+	}
+}
@@ -1,3 +1,5 @@
 module github.com/nerdgarten/mock-payment-service
 
 go 1.24.4
+
+require gopkg.in/yaml.v3 v3.0.1
@@ -1,21 +1,137 @@
 package main
 
 import (
+	"encoding/json"
+	"flag"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
 
+	"github.com/nerdgarten/mock-payment-service/data"
+	"github.com/nerdgarten/mock-payment-service/seed"
 	"github.com/nerdgarten/mock-payment-service/server"
+	"github.com/nerdgarten/mock-payment-service/types"
+	"github.com/nerdgarten/mock-payment-service/webhook"
 )
 
 func main() {
+	seedFile := flag.String("seed", os.Getenv("SEED_FILE"), "JSON or YAML file with the initial mock datasets (env SEED_FILE)")
+	idSeed := flag.String("id-seed", os.Getenv("ID_SEED"), "generate reproducible IDs from this integer seed instead of random ones (env ID_SEED)")
+	legacyErrors := flag.Bool("legacy-errors", os.Getenv("LEGACY_ERRORS") == "true", "return the legacy flat error body and HTTP 200 wallet failures (env LEGACY_ERRORS=true)")
+	flag.Parse()
+
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "50052"
 	}
 
+	store := data.NewMemoryStore()
+	var faults []types.FaultRule
+	if v := os.Getenv("FAULTS"); v != "" {
+		if err := json.Unmarshal([]byte(v), &faults); err != nil {
+			log.Fatalf("invalid FAULTS: %v", err)
+		}
+	}
+	apiKeys := strings.FieldsFunc(os.Getenv("API_KEYS"), func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
+	if *seedFile != "" {
+		file, seeded, err := loadSeed(*seedFile)
+		if err != nil {
+			log.Fatalf("invalid seed file: %v", err)
+		}
+		store = seeded
+		apiKeys = append(apiKeys, file.APIKeys...)
+		faults = append(faults, file.Faults...)
+		log.Printf("Loaded seed file %s", *seedFile)
+	}
+	var ids func() data.IDGenerator
+	if *idSeed != "" {
+		seed, err := strconv.ParseInt(*idSeed, 10, 64)
+		if err != nil {
+			log.Fatalf("invalid ID seed %q", *idSeed)
+		}
+		ids = func() data.IDGenerator { return data.NewSeededIDGenerator(seed) }
+		log.Printf("Generating reproducible IDs from seed %d", seed)
+	}
+	maxAttempts := webhook.DefaultMaxAttempts
+	if v := os.Getenv("WEBHOOK_MAX_ATTEMPTS"); v != "" {
+		attempts, err := strconv.Atoi(v)
+		if err != nil || attempts < 1 {
+			log.Fatalf("invalid WEBHOOK_MAX_ATTEMPTS %q", v)
+		}
+		maxAttempts = attempts
+	}
+	initialBackoff := webhook.DefaultInitialBackoff
+	if v := os.Getenv("WEBHOOK_INITIAL_BACKOFF"); v != "" {
+		backoff, err := time.ParseDuration(v)
+		if err != nil {
+			log.Fatalf("invalid WEBHOOK_INITIAL_BACKOFF %q: %v", v, err)
+		}
+		initialBackoff = backoff
+	}
+	authorizationWindow := data.DefaultAuthorizationWindow
+	if v := os.Getenv("AUTHORIZATION_WINDOW"); v != "" {
+		window, err := time.ParseDuration(v)
+		if err != nil || window <= 0 {
+			log.Fatalf("invalid AUTHORIZATION_WINDOW %q", v)
+		}
+		authorizationWindow = window
+		log.Printf("Expiring uncaptured authorizations after %s", window)
+	}
+	holdTTL := data.DefaultHoldTTL
+	if v := os.Getenv("HOLD_TTL"); v != "" {
+		ttl, err := time.ParseDuration(v)
+		if err != nil || ttl <= 0 {
+			log.Fatalf("invalid HOLD_TTL %q", v)
+		}
+		holdTTL = ttl
+		log.Printf("Expiring wallet holds after %s", ttl)
+	}
+	// setUpStore configures the store of a tenant and starts delivering its
+	// events to the tenant's webhook endpoints.
+	setUpStore := func(store *data.MemoryStore) {
+		if ids != nil {
+			store.SetIDGenerator(ids())
+		}
+		store.SetAuthorizationWindow(authorizationWindow)
+		store.SetHoldTTL(holdTTL)
+		dispatcher := webhook.NewDispatcher(store)
+		dispatcher.MaxAttempts = maxAttempts
+		dispatcher.InitialBackoff = initialBackoff
+		dispatcher.Start()
+	}
+	setUpStore(store)
+
+	paymentServer := server.NewPaymentServer(store)
+	paymentServer.NewTenantStore = func() data.Store {
+		store := data.NewEmptyMemoryStore()
+		setUpStore(store)
+		return store
+	}
+	paymentServer.LegacyErrors = *legacyErrors
+	if *legacyErrors {
+		log.Printf("Returning legacy error responses")
+	}
+	if err := paymentServer.RequireAPIKeys(apiKeys...); err != nil {
+		log.Fatalf("invalid API key: %v", err)
+	}
+	if len(apiKeys) > 0 {
+		log.Printf("Requiring one of %d API keys", len(apiKeys))
+	}
+	if err := paymentServer.AddFaultRules(faults...); err != nil {
+		log.Fatalf("invalid fault rule: %v", err)
+	}
+	if len(faults) > 0 {
+		log.Printf("Injecting faults from %d rules", len(faults))
+	}
 	mux := http.NewServeMux()
-	server.NewPaymentServer().RegisterRoutes(mux)
+	paymentServer.RegisterRoutes(mux)
 
 	addr := ":" + port
 	log.Printf("Mock Payment REST server listening on %s", addr)
@@ -23,3 +139,20 @@ func main() {
 		log.Fatalf("failed to start server: %v", err)
 	}
 }
+
+// loadSeed parses a seed file and creates a store holding its datasets.
+func loadSeed(path string) (*seed.File, *data.MemoryStore, error) {
+	file, err := seed.Load(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	snapshot, err := file.Snapshot()
+	if err != nil {
+		return nil, nil, fmt.Errorf("seed file %s: %w", path, err)
+	}
+	store := data.NewEmptyMemoryStore()
+	if err := data.RestoreSnapshot(store, snapshot); err != nil {
+		return nil, nil, fmt.Errorf("seed file %s: %w", path, err)
+	}
+	return file, store, nil
+}
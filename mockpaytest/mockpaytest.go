@@ -0,0 +1,185 @@
+// Package mockpaytest runs the mock payment service in-process so Go tests get
+// a hermetic payment backend without Docker or a fixed port.
+//
+//	func TestCheckout(t *testing.T) {
+//		mock := mockpaytest.NewServer(t)
+//		customer := mock.SeedCustomer("Ruff", "ruff@example.com")
+//		mock.SetBalance(customer.ID, types.PaymentTypeMeowthWallet, 10000)
+//		// exercise code that talks to mock.URL or uses mock.Client ...
+//		mock.AssertWebhookReceived("payment.processed")
+//	}
+package mockpaytest
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nerdgarten/mock-payment-service/client"
+	"github.com/nerdgarten/mock-payment-service/data"
+	"github.com/nerdgarten/mock-payment-service/server"
+	"github.com/nerdgarten/mock-payment-service/types"
+	"github.com/nerdgarten/mock-payment-service/webhook"
+)
+
+// WebhookSecret signs the webhook deliveries a Server records.
+const WebhookSecret = "whsec_mockpaytest"
+
+// WebhookTimeout bounds how long AssertWebhookReceived waits for a delivery.
+var WebhookTimeout = 2 * time.Second
+
+// Server is a mock payment service bound to a single test. It is shut down
+// when the test and its subtests complete.
+type Server struct {
+	// URL is the base URL of the service, e.g. "http://127.0.0.1:51234".
+	URL string
+	// Client is preconfigured to call URL.
+	Client *client.Client
+	// Store backs the service and may be inspected or modified directly.
+	Store data.Store
+
+	t          testing.TB
+	api        *httptest.Server
+	receiver   *httptest.Server
+	dispatcher *webhook.Dispatcher
+
+	mu       sync.Mutex
+	events   []types.Event
+	received chan struct{}
+}
+
+// IDSeed seeds the ID generator of the stores created by NewServer, so a
+// test produces the same IDs on every run.
+const IDSeed = 1
+
+// NewServer starts a service backed by a new empty store with reproducible IDs.
+func NewServer(t testing.TB) *Server {
+	t.Helper()
+	store := data.NewEmptyMemoryStore()
+	store.SetIDGenerator(data.NewSeededIDGenerator(IDSeed))
+	return NewServerWithStore(t, store)
+}
+
+// NewServerWithStore starts a service backed by store, e.g.
+// data.NewMemoryStore() for the standard mock fixtures, or a store configured
+// with SetAuthorizationWindow or SetHoldTTL to test expiry. A webhook endpoint is
+// registered in store so that every event is recorded by the Server.
+func NewServerWithStore(t testing.TB, store data.Store) *Server {
+	t.Helper()
+	s := &Server{
+		Store:    store,
+		t:        t,
+		received: make(chan struct{}),
+	}
+
+	s.receiver = httptest.NewServer(http.HandlerFunc(s.receiveWebhook))
+	if _, err := data.CreateWebhookEndpoint(store, s.receiver.URL, nil, WebhookSecret); err != nil {
+		s.receiver.Close()
+		t.Fatalf("mockpaytest: register webhook endpoint: %v", err)
+	}
+	s.dispatcher = webhook.NewDispatcher(store)
+	s.dispatcher.MaxAttempts = 3
+	s.dispatcher.InitialBackoff = 10 * time.Millisecond
+	s.dispatcher.Start()
+
+	mux := http.NewServeMux()
+	server.NewPaymentServer(store).RegisterRoutes(mux)
+	s.api = httptest.NewServer(mux)
+	s.URL = s.api.URL
+	s.Client = client.New(s.URL)
+	s.Client.HTTPClient = s.api.Client()
+
+	t.Cleanup(s.close)
+	return s
+}
+
+func (s *Server) close() {
+	s.api.Close()
+	s.dispatcher.Wait()
+	s.receiver.Close()
+}
+
+// SeedCustomer creates a customer with the default account balances.
+func (s *Server) SeedCustomer(name, email string) *types.Customer {
+	s.t.Helper()
+	customer, err := data.CreateMockCustomer(s.Store, name, email)
+	if err != nil {
+		s.t.Fatalf("mockpaytest: seed customer %s: %v", name, err)
+	}
+	return customer
+}
+
+// SetBalance sets the balance of a customer's account in minor units. The
+// change is recorded in the ledger as an adjustment.
+func (s *Server) SetBalance(customerID string, paymentType types.PaymentType, balance types.Amount) *types.Account {
+	s.t.Helper()
+	account, err := data.SetAccountBalance(s.Store, customerID, paymentType, balance)
+	if err != nil {
+		s.t.Fatalf("mockpaytest: set %s balance of %s: %v", paymentType, customerID, err)
+	}
+	return account
+}
+
+// Webhooks returns the events delivered so far, in delivery order.
+func (s *Server) Webhooks() []types.Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]types.Event(nil), s.events...)
+}
+
+// AssertWebhookReceived waits up to WebhookTimeout for a delivered event of
+// eventType and returns the first one, failing the test if none arrives. Like
+// t.Fatal, it must be called from the goroutine running the test.
+func (s *Server) AssertWebhookReceived(eventType string) types.Event {
+	s.t.Helper()
+	deadline := time.NewTimer(WebhookTimeout)
+	defer deadline.Stop()
+	for {
+		s.mu.Lock()
+		for _, event := range s.events {
+			if event.Type == eventType {
+				s.mu.Unlock()
+				return event
+			}
+		}
+		received := s.received
+		s.mu.Unlock()
+
+		select {
+		case <-received:
+		case <-deadline.C:
+			s.t.Fatalf("mockpaytest: no %s webhook received within %s", eventType, WebhookTimeout)
+			return types.Event{}
+		}
+	}
+}
+
+func (s *Server) receiveWebhook(w http.ResponseWriter, r *http.Request) {
+	payload, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := webhook.VerifySignature(r.Header.Get(webhook.SignatureHeader), payload, WebhookSecret, 0); err != nil {
+		s.t.Errorf("mockpaytest: webhook signature: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var event types.Event
+	if err := json.Unmarshal(payload, &event); err != nil {
+		s.t.Errorf("mockpaytest: decode webhook: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	s.events = append(s.events, event)
+	close(s.received)
+	s.received = make(chan struct{})
+	s.mu.Unlock()
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,88 @@
+package mockpaytest_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/nerdgarten/mock-payment-service/data"
+	"github.com/nerdgarten/mock-payment-service/mockpaytest"
+	"github.com/nerdgarten/mock-payment-service/types"
+)
+
+func TestServerProcessesPayment(t *testing.T) {
+	mock := mockpaytest.NewServer(t)
+	customer := mock.SeedCustomer("Ruff", "ruff@example.com")
+	mock.SetBalance(customer.ID, types.PaymentTypeMeowthWallet, 10000)
+
+	resp, err := mock.Client.ProcessPayment(context.Background(), types.ProcessPaymentRequest{
+		CustomerID: customer.ID,
+		Type:       types.PaymentTypeMeowthWallet,
+		Amount:     2500,
+		OrderID:    "order-1001",
+	})
+	if err != nil {
+		t.Fatalf("ProcessPayment: %v", err)
+	}
+	if !resp.Success || resp.Account.Balance != 7500 {
+		t.Fatalf("ProcessPayment = success %t balance %d, want success with 7500 left", resp.Success, resp.Account.Balance)
+	}
+
+	event := mock.AssertWebhookReceived(types.EventPaymentProcessed)
+	var processed types.ProcessPaymentResponse
+	if err := json.Unmarshal(event.Data.Object, &processed); err != nil {
+		t.Fatalf("decode %s event: %v", event.Type, err)
+	}
+	if processed.OrderID != "order-1001" || processed.TransactionID != resp.TransactionID {
+		t.Errorf("event order %q transaction %q, want order-1001 and %s", processed.OrderID, processed.TransactionID, resp.TransactionID)
+	}
+	mock.AssertWebhookReceived(types.EventAccountAdjusted)
+
+	account, err := mock.Client.GetAccount(context.Background(), customer.ID, types.PaymentTypeMeowthWallet)
+	if err != nil {
+		t.Fatalf("GetAccount: %v", err)
+	}
+	if account.Balance != 7500 {
+		t.Errorf("account balance = %d, want 7500", account.Balance)
+	}
+}
+
+func TestServerIDsAreReproducible(t *testing.T) {
+	first := mockpaytest.NewServer(t).SeedCustomer("Ruff", "ruff@example.com")
+	second := mockpaytest.NewServer(t).SeedCustomer("Ruff", "ruff@example.com")
+	if first.ID != second.ID {
+		t.Errorf("customer IDs differ between servers: %s and %s", first.ID, second.ID)
+	}
+}
+
+func TestServersAreIsolated(t *testing.T) {
+	a := mockpaytest.NewServer(t)
+	b := mockpaytest.NewServer(t)
+	customer := a.SeedCustomer("Ruff", "ruff@example.com")
+	if _, err := b.Client.GetCustomer(context.Background(), customer.ID); err == nil {
+		t.Errorf("customer %s created on one server is visible on another", customer.ID)
+	}
+}
+
+func TestServerWithShortAuthorizationWindow(t *testing.T) {
+	store := data.NewEmptyMemoryStore()
+	store.SetAuthorizationWindow(-time.Second)
+	mock := mockpaytest.NewServerWithStore(t, store)
+	ctx := context.Background()
+
+	intent, err := mock.Client.CreatePaymentIntent(ctx, types.CreatePaymentIntentRequest{Amount: 1200, Currency: "thb", PaymentMethod: "pm_card_visa", CaptureMethod: types.CaptureMethodManual})
+	if err != nil {
+		t.Fatalf("CreatePaymentIntent: %v", err)
+	}
+	if _, err := mock.Client.ConfirmPaymentIntent(ctx, types.ConfirmPaymentIntentRequest{ID: intent.ID}); err != nil {
+		t.Fatalf("ConfirmPaymentIntent: %v", err)
+	}
+	expired, err := mock.Client.GetPaymentIntent(ctx, intent.ID)
+	if err != nil {
+		t.Fatalf("GetPaymentIntent: %v", err)
+	}
+	if expired.Status != types.PaymentIntentStatusCanceled || expired.CancellationReason != "expired" {
+		t.Errorf("intent status %s reason %q, want canceled as expired", expired.Status, expired.CancellationReason)
+	}
+}
@@ -0,0 +1,252 @@
+// Package seed loads the initial mock datasets from a JSON or YAML file.
+package seed
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/nerdgarten/mock-payment-service/data"
+	"github.com/nerdgarten/mock-payment-service/types"
+)
+
+// File is the content of a seed file. Fields use the same names as the API's
+// JSON objects; omitted optional fields take the defaults of the matching API
+// call.
+type File struct {
+	// APIKeys are accepted in addition to those in the API_KEYS environment
+	// variable, e.g. "sk_test_..." and "pk_test_...".
+	APIKeys        []string              `json:"api_keys"`
+	Customers      []Customer            `json:"customers"`
+	PaymentMethods []types.PaymentMethod `json:"payment_methods"`
+	PaymentIntents []types.PaymentIntent `json:"payment_intents"`
+	Charges        []types.Charge        `json:"charges"`
+	Refunds        []types.Refund        `json:"refunds"`
+	// Faults are injected into matching requests from startup.
+	Faults []types.FaultRule `json:"faults"`
+}
+
+// Customer is a seeded customer and the balances of its accounts.
+type Customer struct {
+	types.Customer
+	// Balances overrides data.DefaultAccountBalances per payment type, in
+	// minor units of types.DefaultCurrency.
+	Balances map[types.PaymentType]types.Amount `json:"balances,omitempty"`
+}
+
+// Load reads and parses a seed file. Files ending in .yaml or .yml are parsed
+// as YAML, everything else as JSON. Unknown fields are rejected.
+func Load(path string) (*File, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		var doc any
+		if err := yaml.Unmarshal(raw, &doc); err != nil {
+			return nil, fmt.Errorf("seed file %s: %w", path, err)
+		}
+		if raw, err = json.Marshal(doc); err != nil {
+			return nil, fmt.Errorf("seed file %s: %w", path, err)
+		}
+	}
+	var file File
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&file); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			line := 1 + bytes.Count(raw[:syntaxErr.Offset], []byte("\n"))
+			return nil, fmt.Errorf("seed file %s: line %d: %w", path, line, err)
+		}
+		// Decoding errors are prefixed with "json: " even for YAML files.
+		return nil, fmt.Errorf("seed file %s: %s", path, strings.TrimPrefix(err.Error(), "json: "))
+	}
+	return &file, nil
+}
+
+// Snapshot validates the seed and converts it into a snapshot that can be
+// imported with data.RestoreSnapshot. Every customer gets an account per
+// payment type. References between objects are checked on restore.
+func (f *File) Snapshot() (*types.Snapshot, error) {
+	now := time.Now().Unix()
+	snapshot := &types.Snapshot{
+		Object:         "snapshot",
+		Customers:      []types.Customer{},
+		PaymentMethods: []types.PaymentMethod{},
+		PaymentIntents: []types.PaymentIntent{},
+		Charges:        []types.Charge{},
+		Refunds:        []types.Refund{},
+		Accounts:       []types.Account{},
+		Transactions:   []types.Transaction{},
+	}
+
+	for i, c := range f.Customers {
+		customer := c.Customer
+		customer.Object = "customer"
+		if customer.Created == 0 {
+			customer.Created = now
+		}
+		for paymentType, balance := range c.Balances {
+			if !slices.Contains(types.PaymentTypes, paymentType) {
+				return nil, fmt.Errorf("customers[%d].balances: unsupported payment type %q", i, paymentType)
+			}
+			if balance < 0 {
+				return nil, fmt.Errorf("customers[%d].balances.%s: balance must not be negative", i, paymentType)
+			}
+		}
+		snapshot.Customers = append(snapshot.Customers, customer)
+		for _, paymentType := range types.PaymentTypes {
+			balance, ok := c.Balances[paymentType]
+			if !ok {
+				balance = data.DefaultAccountBalances[paymentType]
+			}
+			snapshot.Accounts = append(snapshot.Accounts, types.Account{
+				CustomerID: customer.ID,
+				Type:       paymentType,
+				Currency:   types.DefaultCurrency,
+				Balance:    balance,
+			})
+		}
+	}
+
+	for _, method := range f.PaymentMethods {
+		method.Object = "payment_method"
+		if method.Created == 0 {
+			method.Created = now
+		}
+		snapshot.PaymentMethods = append(snapshot.PaymentMethods, method)
+	}
+
+	currencies := make(map[string]string)
+	intentCustomers := make(map[string]string)
+	intentStatuses := make(map[string]types.PaymentIntentStatus)
+	for i, intent := range f.PaymentIntents {
+		intent.Object = "payment_intent"
+		if intent.Created == 0 {
+			intent.Created = now
+		}
+		intent.Currency = strings.ToLower(intent.Currency)
+		if intent.Currency == "" {
+			intent.Currency = types.DefaultCurrency
+		}
+		if err := checkMoney(intent.Amount, intent.Currency); err != nil {
+			return nil, fmt.Errorf("payment_intents[%d]: %w", i, err)
+		}
+		if intent.Status == "" {
+			intent.Status = types.PaymentIntentStatusRequiresConfirmation
+			if intent.PaymentMethod == "" {
+				intent.Status = types.PaymentIntentStatusRequiresPaymentMethod
+			}
+		}
+		if !slices.Contains(types.PaymentIntentStatuses, intent.Status) {
+			return nil, fmt.Errorf("payment_intents[%d].status: unknown status %q", i, intent.Status)
+		}
+		switch intent.CaptureMethod {
+		case "":
+			intent.CaptureMethod = types.CaptureMethodAutomatic
+		case types.CaptureMethodAutomatic, types.CaptureMethodManual:
+		default:
+			return nil, fmt.Errorf("payment_intents[%d].capture_method: unknown capture method %q", i, intent.CaptureMethod)
+		}
+		if intent.Status == types.PaymentIntentStatusRequiresCapture && intent.AmountCapturable == 0 {
+			intent.AmountCapturable = intent.Amount
+		}
+		if intent.AmountCapturable < 0 || intent.AmountCapturable > intent.Amount {
+			return nil, fmt.Errorf("payment_intents[%d].amount_capturable: must be between 0 and amount", i)
+		}
+		if intent.AmountRefunded < 0 || intent.AmountRefunded > intent.Amount {
+			return nil, fmt.Errorf("payment_intents[%d].amount_refunded: must be between 0 and amount", i)
+		}
+		if intent.ClientSecret == "" && intent.ID != "" {
+			intent.ClientSecret = intent.ID + "_secret_seed"
+		}
+		currencies[intent.ID] = intent.Currency
+		intentCustomers[intent.ID] = intent.Customer
+		intentStatuses[intent.ID] = intent.Status
+		snapshot.PaymentIntents = append(snapshot.PaymentIntents, intent)
+	}
+
+	for i, charge := range f.Charges {
+		charge.Object = "charge"
+		if charge.Customer == "" {
+			charge.Customer = intentCustomers[charge.PaymentIntent]
+		}
+		if charge.Created == 0 {
+			charge.Created = now
+		}
+		charge.Currency = strings.ToLower(charge.Currency)
+		if charge.Currency == "" {
+			charge.Currency = currencies[charge.PaymentIntent]
+		}
+		if charge.Currency == "" {
+			charge.Currency = types.DefaultCurrency
+		}
+		if err := checkMoney(charge.Amount, charge.Currency); err != nil {
+			return nil, fmt.Errorf("charges[%d]: %w", i, err)
+		}
+		if charge.Status == "" {
+			charge.Status = "succeeded"
+		}
+		// Succeeded charges are captured in full unless they hold the funds of
+		// an intent in requires_capture.
+		if charge.Status == "succeeded" && intentStatuses[charge.PaymentIntent] != types.PaymentIntentStatusRequiresCapture {
+			charge.Captured = true
+			if charge.AmountCaptured == 0 {
+				charge.AmountCaptured = charge.Amount
+			}
+		}
+		if charge.AmountCaptured < 0 || charge.AmountCaptured > charge.Amount {
+			return nil, fmt.Errorf("charges[%d].amount_captured: must be between 0 and amount", i)
+		}
+		if charge.AmountRefunded < 0 || charge.AmountRefunded > charge.Amount {
+			return nil, fmt.Errorf("charges[%d].amount_refunded: must be between 0 and amount", i)
+		}
+		charge.Refunded = charge.AmountRefunded == charge.Amount
+		if charge.Captured {
+			charge.Refunded = charge.AmountRefunded == charge.AmountCaptured
+		}
+		snapshot.Charges = append(snapshot.Charges, charge)
+	}
+
+	for i, refund := range f.Refunds {
+		refund.Object = "refund"
+		refund.Currency = strings.ToLower(refund.Currency)
+		if refund.Currency == "" {
+			refund.Currency = currencies[refund.PaymentIntent]
+		}
+		if err := checkMoney(refund.Amount, refund.Currency); err != nil {
+			return nil, fmt.Errorf("refunds[%d]: %w", i, err)
+		}
+		if refund.PaymentIntent == "" {
+			return nil, fmt.Errorf("refunds[%d].payment_intent: required", i)
+		}
+		if refund.Status == "" {
+			refund.Status = "succeeded"
+		}
+		if refund.Created == 0 {
+			refund.Created = now
+		}
+		snapshot.Refunds = append(snapshot.Refunds, refund)
+	}
+	return snapshot, nil
+}
+
+func checkMoney(amount types.Amount, currency string) error {
+	if err := (types.Money{Amount: amount, Currency: currency}).Validate(); err != nil {
+		return fmt.Errorf("currency: %w", err)
+	}
+	if amount <= 0 {
+		return fmt.Errorf("amount: must be greater than zero")
+	}
+	return nil
+}
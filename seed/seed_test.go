@@ -0,0 +1,127 @@
+package seed
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nerdgarten/mock-payment-service/data"
+	"github.com/nerdgarten/mock-payment-service/types"
+)
+
+// writeSeed writes content to a file named name in a temporary directory and
+// returns its path.
+func writeSeed(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write seed file: %v", err)
+	}
+	return path
+}
+
+func TestExampleSeedLoads(t *testing.T) {
+	file, err := Load("../examples/seed.yaml")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	snapshot, err := file.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+	store := data.NewEmptyMemoryStore()
+	if err := data.RestoreSnapshot(store, snapshot); err != nil {
+		t.Fatalf("RestoreSnapshot: %v", err)
+	}
+	if got := data.GetAccount(store, "cus_shop_alice", types.PaymentTypeMeowthWallet).Balance; got != 25000 {
+		t.Errorf("alice meowth-wallet balance = %d, want 25000", got)
+	}
+	if got := data.GetAccount(store, "cus_shop_alice", types.PaymentTypeCash).Balance; got != data.DefaultAccountBalances[types.PaymentTypeCash] {
+		t.Errorf("alice cash balance = %d, want the default", got)
+	}
+	if got := data.GetAccount(store, "cus_shop_bob", types.PaymentTypeCash).Balance; got != 0 {
+		t.Errorf("bob cash balance = %d, want 0", got)
+	}
+}
+
+func TestSnapshotDefaults(t *testing.T) {
+	path := writeSeed(t, "seed.json", `{
+		"customers": [{"id": "cus_1", "name": "Alice"}],
+		"payment_intents": [
+			{"id": "pi_new", "amount": 1000},
+			{"id": "pi_ready", "amount": 1000, "currency": "USD", "payment_method": "pm_card_visa"},
+			{"id": "pi_paid", "amount": 1000, "status": "succeeded", "customer": "cus_1", "latest_charge": "ch_paid"}
+		],
+		"charges": [{"id": "ch_paid", "amount": 1000, "payment_intent": "pi_paid"}]
+	}`)
+	file, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	snapshot, err := file.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+	if len(snapshot.Accounts) != len(types.PaymentTypes) {
+		t.Errorf("seeded %d accounts, want %d", len(snapshot.Accounts), len(types.PaymentTypes))
+	}
+	intents := snapshot.PaymentIntents
+	if intents[0].Status != types.PaymentIntentStatusRequiresPaymentMethod || intents[0].Currency != types.DefaultCurrency || intents[0].ClientSecret == "" {
+		t.Errorf("intent without payment method = %+v", intents[0])
+	}
+	if intents[1].Status != types.PaymentIntentStatusRequiresConfirmation || intents[1].Currency != "usd" || intents[1].CaptureMethod != types.CaptureMethodAutomatic {
+		t.Errorf("intent with payment method = %+v", intents[1])
+	}
+	charge := snapshot.Charges[0]
+	if charge.Status != "succeeded" || !charge.Captured || charge.AmountCaptured != 1000 || charge.Customer != "cus_1" || charge.Currency != types.DefaultCurrency {
+		t.Errorf("charge = %+v", charge)
+	}
+	if err := data.RestoreSnapshot(data.NewEmptyMemoryStore(), snapshot); err != nil {
+		t.Errorf("RestoreSnapshot: %v", err)
+	}
+}
+
+func TestLoadRejectsInvalidFiles(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    string
+	}{
+		{"unknown field", "seed.json", `{"customer": []}`, `unknown field "customer"`},
+		{"syntax error", "seed.json", "{\n\"customers\": [\n}", "line 3"},
+		{"yaml syntax error", "seed.yml", "customers:\n  - id: [", "seed.yml"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(writeSeed(t, tt.file, tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Load error = %v, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSnapshotRejectsInvalidSeeds(t *testing.T) {
+	tests := []struct {
+		name string
+		file File
+		want string
+	}{
+		{"unsupported balance type", File{Customers: []Customer{{Customer: types.Customer{ID: "cus_1"}, Balances: map[types.PaymentType]types.Amount{"bitcoin": 1}}}}, "customers[0].balances"},
+		{"negative balance", File{Customers: []Customer{{Customer: types.Customer{ID: "cus_1"}, Balances: map[types.PaymentType]types.Amount{types.PaymentTypeCash: -1}}}}, "customers[0].balances.cash"},
+		{"zero amount", File{PaymentIntents: []types.PaymentIntent{{ID: "pi_1"}}}, "payment_intents[0]: amount"},
+		{"unknown status", File{PaymentIntents: []types.PaymentIntent{{ID: "pi_1", Amount: 100, Status: "paid"}}}, "payment_intents[0].status"},
+		{"unknown capture method", File{PaymentIntents: []types.PaymentIntent{{ID: "pi_1", Amount: 100, CaptureMethod: "later"}}}, "payment_intents[0].capture_method"},
+		{"refund without intent", File{Refunds: []types.Refund{{ID: "re_1", Amount: 100, Currency: "thb"}}}, "refunds[0].payment_intent"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.file.Snapshot()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Snapshot error = %v, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/nerdgarten/mock-payment-service/data"
+	"github.com/nerdgarten/mock-payment-service/types"
+)
+
+// handleAdminReset restores the fixtures of the caller's tenant and responds
+// with the resulting snapshot.
+func (s *PaymentServer) handleAdminReset(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, r)
+		return
+	}
+	log.Printf("REST AdminReset called")
+	t := requestTenant(r)
+	if err := t.reset(); err != nil {
+		writeDataError(w, r, err, nil)
+		return
+	}
+	writeJSON(w, http.StatusOK, data.TakeSnapshot(t.store))
+}
+
+func (s *PaymentServer) handleAdminSnapshot(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, r)
+		return
+	}
+	log.Printf("REST AdminSnapshot called")
+	writeJSON(w, http.StatusOK, data.TakeSnapshot(s.storeFor(r)))
+}
+
+// handleAdminRestore replaces the state of the caller's tenant with a snapshot
+// taken by /admin/snapshot and responds with the restored snapshot.
+func (s *PaymentServer) handleAdminRestore(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, r)
+		return
+	}
+	var snapshot types.Snapshot
+	if err := json.NewDecoder(r.Body).Decode(&snapshot); err != nil {
+		writeDecodeError(w, r, err)
+		return
+	}
+	log.Printf("REST AdminRestore called customers=%d payment_intents=%d transactions=%d", len(snapshot.Customers), len(snapshot.PaymentIntents), len(snapshot.Transactions))
+	t := requestTenant(r)
+	if err := data.RestoreSnapshot(t.store, &snapshot); err != nil {
+		writeDataError(w, r, err, nil)
+		return
+	}
+	t.idempotency.clear()
+	writeJSON(w, http.StatusOK, data.TakeSnapshot(t.store))
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/nerdgarten/mock-payment-service/types"
+)
+
+func TestAdminResetRestoresFixtures(t *testing.T) {
+	_, ts := newTestServer(t)
+	var fixtures types.Snapshot
+	if status := call(t, ts, http.MethodGet, "/admin/snapshot", "", nil, &fixtures); status != http.StatusOK {
+		t.Fatalf("snapshot: status = %d, want 200", status)
+	}
+	if status := call(t, ts, http.MethodPost, "/customers", "", types.CreateCustomerRequest{Name: "Mia"}, nil); status != http.StatusCreated {
+		t.Fatalf("create customer: status = %d, want 201", status)
+	}
+	var reset types.Snapshot
+	if status := call(t, ts, http.MethodPost, "/admin/reset", "", nil, &reset); status != http.StatusOK {
+		t.Fatalf("reset: status = %d, want 200", status)
+	}
+	if len(reset.Customers) != len(fixtures.Customers) || len(reset.PaymentIntents) != len(fixtures.PaymentIntents) {
+		t.Errorf("reset left %d customers and %d intents, want %d and %d",
+			len(reset.Customers), len(reset.PaymentIntents), len(fixtures.Customers), len(fixtures.PaymentIntents))
+	}
+}
+
+func TestAdminRestore(t *testing.T) {
+	_, ts := newTestServer(t)
+	var snapshot types.Snapshot
+	call(t, ts, http.MethodGet, "/admin/snapshot", "", nil, &snapshot)
+	if status := call(t, ts, http.MethodPost, "/customers", "", types.CreateCustomerRequest{Name: "Mia"}, nil); status != http.StatusCreated {
+		t.Fatalf("create customer: status = %d, want 201", status)
+	}
+	var restored types.Snapshot
+	if status := call(t, ts, http.MethodPost, "/admin/restore", "", snapshot, &restored); status != http.StatusOK {
+		t.Fatalf("restore: status = %d, want 200", status)
+	}
+	if len(restored.Customers) != len(snapshot.Customers) {
+		t.Errorf("restored %d customers, want %d", len(restored.Customers), len(snapshot.Customers))
+	}
+
+	invalid := types.Snapshot{Customers: []types.Customer{{ID: "cus_1"}, {ID: "cus_1"}}}
+	var envelope types.ErrorEnvelope
+	if status := call(t, ts, http.MethodPost, "/admin/restore", "", invalid, &envelope); status != http.StatusBadRequest {
+		t.Fatalf("invalid restore: status = %d, want 400", status)
+	}
+	if envelope.Error.Param != "customers[1].id" {
+		t.Errorf("invalid restore param = %q, want customers[1].id", envelope.Error.Param)
+	}
+	var after types.Snapshot
+	call(t, ts, http.MethodGet, "/admin/snapshot", "", nil, &after)
+	if len(after.Customers) != len(snapshot.Customers) {
+		t.Errorf("invalid restore changed the store to %d customers", len(after.Customers))
+	}
+}
@@ -0,0 +1,130 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/nerdgarten/mock-payment-service/types"
+)
+
+// Error codes returned by the API key middleware.
+const (
+	codeAPIKeyMissing     = "api_key_missing"
+	codeAPIKeyInvalid     = "api_key_invalid"
+	codeSecretKeyRequired = "secret_key_required"
+)
+
+// APIKeyKind distinguishes secret keys, which may call every route, from
+// publishable keys, which may only call client-side-safe routes.
+type APIKeyKind string
+
+const (
+	APIKeyKindSecret      APIKeyKind = "secret"
+	APIKeyKindPublishable APIKeyKind = "publishable"
+)
+
+// APIKey is a parsed API key such as "sk_test_abc" or "pk_live_xyz".
+type APIKey struct {
+	Value    string
+	Kind     APIKeyKind
+	Livemode bool
+}
+
+// ParseAPIKey parses a key of the form "<sk|pk>_<test|live>_<token>".
+func ParseAPIKey(value string) (APIKey, error) {
+	key := APIKey{Value: value}
+	kind, rest, _ := strings.Cut(value, "_")
+	mode, token, _ := strings.Cut(rest, "_")
+	switch kind {
+	case "sk":
+		key.Kind = APIKeyKindSecret
+	case "pk":
+		key.Kind = APIKeyKindPublishable
+	default:
+		return APIKey{}, fmt.Errorf("API key %q must start with sk_ or pk_", redactAPIKey(value))
+	}
+	switch mode {
+	case "test":
+	case "live":
+		key.Livemode = true
+	default:
+		return APIKey{}, fmt.Errorf("API key %q must start with %s_test_ or %s_live_", redactAPIKey(value), kind, kind)
+	}
+	if token == "" {
+		return APIKey{}, fmt.Errorf("API key %q has no token after its prefix", redactAPIKey(value))
+	}
+	return key, nil
+}
+
+// redactAPIKey hides all but the prefix and last four characters of a key,
+// as Stripe does in its error messages.
+func redactAPIKey(value string) string {
+	prefix := ""
+	if i := strings.LastIndex(value, "_"); i >= 0 && i < len(value)-1 {
+		prefix, value = value[:i+1], value[i+1:]
+	}
+	if len(value) <= 4 {
+		return prefix + strings.Repeat("*", len(value))
+	}
+	return prefix + "****" + value[len(value)-4:]
+}
+
+type apiKeyContextKey struct{}
+
+// requestAPIKey returns the key that authenticated r. ok is false for
+// anonymous requests, which are only allowed while no key is configured for
+// the default tenant.
+func requestAPIKey(r *http.Request) (APIKey, bool) {
+	key, ok := r.Context().Value(apiKeyContextKey{}).(APIKey)
+	return key, ok
+}
+
+// authenticate resolves the tenant of a request from its bearer token.
+// Publishable keys are only accepted when publishable is true. Requests
+// without a token use the default tenant unless it requires a key.
+func (s *PaymentServer) authenticate(next http.HandlerFunc, publishable bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		value, hasBearer := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		value = strings.TrimSpace(value)
+		if !hasBearer {
+			value = ""
+		}
+		key, owner, known := s.tenants.lookup(value)
+		switch {
+		case known && key.Kind == APIKeyKindPublishable && !publishable:
+			writeSecretKeyRequired(w, r)
+			return
+		case known:
+			ctx := context.WithValue(r.Context(), apiKeyContextKey{}, key)
+			next(w, r.WithContext(context.WithValue(ctx, tenantContextKey{}, owner)))
+			return
+		case value == "" && s.tenants.defaultRequiresKey():
+			writeUnauthorized(w, r, codeAPIKeyMissing, "You did not provide an API key. Provide it in the Authorization header as 'Bearer sk_test_...'.")
+			return
+		case value != "" && s.tenants.hasKeys():
+			writeUnauthorized(w, r, codeAPIKeyInvalid, "Invalid API Key provided: "+redactAPIKey(value))
+			return
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), tenantContextKey{}, s.tenants.defaultTenant())))
+	}
+}
+
+// publishableRequest reports whether r was authenticated with a publishable key.
+func publishableRequest(r *http.Request) bool {
+	key, ok := requestAPIKey(r)
+	return ok && key.Kind == APIKeyKindPublishable
+}
+
+func writeSecretKeyRequired(w http.ResponseWriter, r *http.Request) {
+	writeAPIError(w, r, http.StatusForbidden, types.APIError{
+		Message: "This API call cannot be made with a publishable API key. Please use a secret API key.",
+		Code:    codeSecretKeyRequired,
+	})
+}
+
+func writeUnauthorized(w http.ResponseWriter, r *http.Request, code, message string) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="mock-payment-service"`)
+	writeAPIError(w, r, http.StatusUnauthorized, types.APIError{Message: message, Code: code})
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseAPIKey(t *testing.T) {
+	tests := []struct {
+		value    string
+		kind     APIKeyKind
+		livemode bool
+	}{
+		{"sk_test_4eC39HqLyjWDarjtT1zdp7dc", APIKeyKindSecret, false},
+		{"pk_test_TYooMQauvdEDq54NiTphI7jx", APIKeyKindPublishable, false},
+		{"sk_live_abc", APIKeyKindSecret, true},
+	}
+	for _, tt := range tests {
+		key, err := ParseAPIKey(tt.value)
+		if err != nil {
+			t.Errorf("ParseAPIKey(%q): %v", tt.value, err)
+			continue
+		}
+		if key.Value != tt.value || key.Kind != tt.kind || key.Livemode != tt.livemode {
+			t.Errorf("ParseAPIKey(%q) = %+v, want kind %v livemode %t", tt.value, key, tt.kind, tt.livemode)
+		}
+	}
+}
+
+func TestParseAPIKeyRedactsInvalidKeys(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"xk_test_supersecret1234", "must start with sk_ or pk_"},
+		{"sk_prod_supersecret1234", "must start with sk_test_ or sk_live_"},
+		{"sk_test_", "has no token after its prefix"},
+		{"sk_test", "has no token after its prefix"},
+		{"supersecret1234", "must start with sk_ or pk_"},
+	}
+	for _, tt := range tests {
+		_, err := ParseAPIKey(tt.value)
+		if err == nil {
+			t.Errorf("ParseAPIKey(%q) = nil error, want %q", tt.value, tt.want)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("ParseAPIKey(%q) error %q, want it to contain %q", tt.value, err, tt.want)
+		}
+		if strings.Contains(err.Error(), tt.value) {
+			t.Errorf("ParseAPIKey(%q) error %q leaks the key", tt.value, err)
+		}
+	}
+}
+
+func TestRedactAPIKey(t *testing.T) {
+	tests := map[string]string{
+		"sk_test_4eC39HqLyjWDarjtT1zdp7dc": "sk_test_****p7dc",
+		"sk_test_abc":                      "sk_test_***",
+		"secret":                           "****cret",
+	}
+	for value, want := range tests {
+		if got := redactAPIKey(value); got != want {
+			t.Errorf("redactAPIKey(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
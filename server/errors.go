@@ -0,0 +1,142 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/nerdgarten/mock-payment-service/data"
+	"github.com/nerdgarten/mock-payment-service/types"
+)
+
+// requestIDHeader carries the ID of every response, also reported as the
+// request_id of errors.
+const requestIDHeader = "Request-Id"
+
+type requestContextKey struct{}
+
+// requestInfo is attached to every request by withRequestID.
+type requestInfo struct {
+	id           string
+	legacyErrors bool
+}
+
+// withRequestID assigns the request an ID and records how its errors are
+// written.
+func (s *PaymentServer) withRequestID(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		info := requestInfo{id: data.NewRandomIDGenerator().NewID("req"), legacyErrors: s.LegacyErrors}
+		w.Header().Set(requestIDHeader, info.id)
+		next(w, r.WithContext(context.WithValue(r.Context(), requestContextKey{}, info)))
+	}
+}
+
+func requestContext(r *http.Request) requestInfo {
+	info, _ := r.Context().Value(requestContextKey{}).(requestInfo)
+	return info
+}
+
+// legacyErrors reports whether r gets the legacy error responses.
+func legacyErrors(r *http.Request) bool {
+	return requestContext(r).legacyErrors
+}
+
+// writeAPIError writes apiErr in the error envelope, or as a legacy
+// ErrorResponse in legacy error mode. An empty Type is derived from status.
+func writeAPIError(w http.ResponseWriter, r *http.Request, status int, apiErr types.APIError) {
+	if apiErr.Type == "" {
+		apiErr.Type = errorType(status)
+	}
+	info := requestContext(r)
+	if info.legacyErrors {
+		writeJSON(w, status, types.ErrorResponse{
+			Error:         apiErr.Message,
+			Code:          apiErr.Code,
+			DeclineCode:   apiErr.DeclineCode,
+			Param:         apiErr.Param,
+			PaymentIntent: apiErr.PaymentIntent,
+		})
+		return
+	}
+	apiErr.RequestID = info.id
+	writeJSON(w, status, types.ErrorEnvelope{Error: apiErr})
+}
+
+// errorType returns the error type matching an HTTP status.
+func errorType(status int) types.ErrorType {
+	switch {
+	case status == http.StatusUnauthorized:
+		return types.ErrorTypeAuthentication
+	case status == http.StatusPaymentRequired:
+		return types.ErrorTypeCard
+	case status == http.StatusForbidden:
+		return types.ErrorTypePermission
+	case status == http.StatusTooManyRequests:
+		return types.ErrorTypeRateLimit
+	case status >= http.StatusInternalServerError:
+		return types.ErrorTypeAPI
+	default:
+		return types.ErrorTypeInvalidRequest
+	}
+}
+
+// writeError writes an error without a code, except that every 404 has the
+// resource_missing code.
+func writeError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	apiErr := types.APIError{Message: message}
+	if status == http.StatusNotFound {
+		apiErr.Code = data.CodeResourceMissing
+	}
+	writeAPIError(w, r, status, apiErr)
+}
+
+func writeMethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	writeError(w, r, http.StatusMethodNotAllowed, "method not allowed")
+}
+
+// writeDataError maps data layer errors to HTTP responses. intent, when set,
+// is the payment intent affected by a failed payment.
+func writeDataError(w http.ResponseWriter, r *http.Request, err error, intent *types.PaymentIntent) {
+	status, apiErr := dataAPIError(err)
+	apiErr.PaymentIntent = intent
+	writeAPIError(w, r, status, apiErr)
+}
+
+// dataAPIError converts a data layer error into a status and error object.
+// Other errors are reported as internal errors.
+func dataAPIError(err error) (int, types.APIError) {
+	var dataErr *data.Error
+	if !errors.As(err, &dataErr) {
+		return http.StatusInternalServerError, types.APIError{Message: err.Error()}
+	}
+	status := http.StatusBadRequest
+	switch dataErr.Kind {
+	case data.ErrorKindNotFound:
+		status = http.StatusNotFound
+	case data.ErrorKindConflict:
+		status = http.StatusConflict
+	case data.ErrorKindPaymentFailed, data.ErrorKindCardError:
+		status = http.StatusPaymentRequired
+	}
+	return status, types.APIError{
+		Code:        dataErr.Code,
+		DeclineCode: dataErr.DeclineCode,
+		Param:       dataErr.Param,
+		Message:     dataErr.Message,
+	}
+}
+
+// writeDecodeError reports a request body that could not be decoded,
+// surfacing amounts that are not whole minor units.
+func writeDecodeError(w http.ResponseWriter, r *http.Request, err error) {
+	var amountErr *types.AmountError
+	if errors.As(err, &amountErr) {
+		writeAPIError(w, r, http.StatusBadRequest, types.APIError{
+			Message: amountErr.Error(),
+			Code:    data.CodeParameterInvalid,
+			Param:   "amount",
+		})
+		return
+	}
+	writeError(w, r, http.StatusBadRequest, "invalid request payload")
+}
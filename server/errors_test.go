@@ -0,0 +1,95 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/nerdgarten/mock-payment-service/data"
+	"github.com/nerdgarten/mock-payment-service/types"
+)
+
+func TestFractionalAmountIsRejected(t *testing.T) {
+	_, ts := newTestServer(t)
+	body := json.RawMessage(`{"customer_id":"cus_mock_12345","type":"cash","amount":12.99}`)
+	var envelope types.ErrorEnvelope
+	status := call(t, ts, http.MethodPost, "/deposit", "", body, &envelope)
+	if status != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", status)
+	}
+	want := types.APIError{
+		Type:  types.ErrorTypeInvalidRequest,
+		Code:  data.CodeParameterInvalid,
+		Param: "amount",
+	}
+	got := envelope.Error
+	if got.Type != want.Type || got.Code != want.Code || got.Param != want.Param {
+		t.Errorf("error = %+v, want type %s code %s param %s", got, want.Type, want.Code, want.Param)
+	}
+}
+
+func TestUnsupportedCurrencyIsRejected(t *testing.T) {
+	_, ts := newTestServer(t)
+	req := types.DepositRequest{CustomerID: "cus_mock_12345", Type: types.PaymentTypeCash, Currency: "xyz", Amount: 100}
+	var envelope types.ErrorEnvelope
+	if status := call(t, ts, http.MethodPost, "/deposit", "", req, &envelope); status != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", status)
+	}
+	if envelope.Error.Type != types.ErrorTypeInvalidRequest {
+		t.Errorf("error type = %q, want %q", envelope.Error.Type, types.ErrorTypeInvalidRequest)
+	}
+}
+
+func TestInsufficientBalanceEnvelope(t *testing.T) {
+	_, ts := newTestServer(t)
+	tooMuch := data.DefaultAccountBalances[types.PaymentTypeCash] + 1
+	requests := map[string]any{
+		"/withdraw": types.WithdrawRequest{CustomerID: "cus_mock_12345", Type: types.PaymentTypeCash, Amount: tooMuch},
+		"/process-payment": types.ProcessPaymentRequest{
+			CustomerID: "cus_mock_12345", Type: types.PaymentTypeCash, Amount: tooMuch, OrderID: "order-1",
+		},
+		"/holds": types.CreateHoldRequest{CustomerID: "cus_mock_12345", Type: types.PaymentTypeMeowthWallet, Amount: tooMuch},
+	}
+	for path, req := range requests {
+		var envelope types.ErrorEnvelope
+		status := call(t, ts, http.MethodPost, path, "", req, &envelope)
+		if status != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", path, status)
+		}
+		got := envelope.Error
+		if got.Type != types.ErrorTypeInvalidRequest || got.Code != data.CodeBalanceInsufficient ||
+			got.Param != "amount" || got.DeclineCode != "" || got.RequestID == "" {
+			t.Errorf("%s: error = %+v, want invalid_request_error %s on amount without decline code", path, got, data.CodeBalanceInsufficient)
+		}
+	}
+}
+
+func TestInsufficientBalanceLegacyResponse(t *testing.T) {
+	s, ts := newTestServer(t)
+	s.LegacyErrors = true
+	req := types.WithdrawRequest{CustomerID: "cus_mock_12345", Type: types.PaymentTypeCash, Amount: data.DefaultAccountBalances[types.PaymentTypeCash] + 1}
+	var resp types.WithdrawResponse
+	if status := call(t, ts, http.MethodPost, "/withdraw", "", req, &resp); status != http.StatusOK {
+		t.Fatalf("status = %d, want 200", status)
+	}
+	if resp.Success || resp.Message != "Insufficient balance" {
+		t.Errorf("response = %+v, want success false with the insufficient balance message", resp)
+	}
+}
+
+func TestDecliningCardRespondsPaymentRequired(t *testing.T) {
+	_, ts := newTestServer(t)
+	var created types.CreatePaymentIntentResponse
+	req := types.CreatePaymentIntentRequest{Amount: 1200, Currency: "thb", PaymentMethod: "4000000000000002"}
+	if status := call(t, ts, http.MethodPost, "/payment-intents", "", req, &created); status != http.StatusCreated {
+		t.Fatalf("create intent: status = %d, want 201", status)
+	}
+	var envelope types.ErrorEnvelope
+	status := call(t, ts, http.MethodPost, "/payment-intents/confirm", "", types.ConfirmPaymentIntentRequest{ID: created.PaymentIntent.ID}, &envelope)
+	if status != http.StatusPaymentRequired {
+		t.Fatalf("confirm: status = %d, want 402", status)
+	}
+	if got := envelope.Error; got.Type != types.ErrorTypeCard || got.Code != "card_declined" || got.DeclineCode != "generic_decline" {
+		t.Errorf("error = %+v, want card_error card_declined generic_decline", got)
+	}
+}
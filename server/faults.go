@@ -0,0 +1,408 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nerdgarten/mock-payment-service/data"
+	"github.com/nerdgarten/mock-payment-service/types"
+)
+
+const (
+	// faultHeader triggers faults for a single request, e.g. "timeout",
+	// "drop", "503" or "latency=250ms". Several faults are separated by commas.
+	faultHeader = "X-Mock-Fault"
+	// faultInjectedHeader names the fault injected into a response.
+	faultInjectedHeader = "X-Mock-Fault-Injected"
+	codeInjectedFault   = "injected_fault"
+	// defaultFaultTimeout is how long a timeout fault holds the connection.
+	defaultFaultTimeout = 30 * time.Second
+)
+
+// faultStatuses lists the statuses an error fault may respond with.
+var faultStatuses = []int{
+	http.StatusTooManyRequests,
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+}
+
+// faultInjector holds the fault rules applied in front of every route.
+type faultInjector struct {
+	mu    sync.RWMutex
+	rules []types.FaultRule
+}
+
+// add validates and appends rules, assigning IDs to rules without one.
+func (f *faultInjector) add(rules ...types.FaultRule) ([]types.FaultRule, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	added := make([]types.FaultRule, 0, len(rules))
+	for i, rule := range rules {
+		if err := normalizeFaultRule(&rule); err != nil {
+			return nil, fmt.Errorf("fault rule %d: %w", i, err)
+		}
+		if rule.ID == "" {
+			rule.ID = data.NewRandomIDGenerator().NewID("fault")
+		}
+		sameID := func(existing types.FaultRule) bool { return existing.ID == rule.ID }
+		if slices.ContainsFunc(f.rules, sameID) || slices.ContainsFunc(added, sameID) {
+			return nil, fmt.Errorf("fault rule %d: id %s is already in use", i, rule.ID)
+		}
+		added = append(added, rule)
+	}
+	f.rules = append(f.rules, added...)
+	return added, nil
+}
+
+func (f *faultInjector) list() []types.FaultRule {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return append([]types.FaultRule{}, f.rules...)
+}
+
+func (f *faultInjector) remove(id string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := len(f.rules)
+	f.rules = slices.DeleteFunc(f.rules, func(rule types.FaultRule) bool { return rule.ID == id })
+	return len(f.rules) != n
+}
+
+func (f *faultInjector) clear() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rules = nil
+}
+
+func faultRate(rate float64) *float64 {
+	return &rate
+}
+
+// normalizeFaultRule fills in defaults and rejects unusable rules. An omitted
+// rate becomes 1; an explicit 0 keeps the rule but never fires it.
+func normalizeFaultRule(rule *types.FaultRule) error {
+	rule.Object = "fault_rule"
+	rule.Method = strings.ToUpper(rule.Method)
+	if rule.Rate == nil {
+		rule.Rate = faultRate(1)
+	}
+	if *rule.Rate < 0 || *rule.Rate > 1 {
+		return fmt.Errorf("rate must be between 0 and 1")
+	}
+	if rule.PaymentType != "" && !slices.Contains(types.PaymentTypes, rule.PaymentType) {
+		return fmt.Errorf("unsupported payment_type %q", rule.PaymentType)
+	}
+	switch rule.Kind {
+	case types.FaultKindLatency:
+		if rule.Latency == nil {
+			return fmt.Errorf("latency faults need a latency distribution")
+		}
+		if err := validateLatency(rule.Latency); err != nil {
+			return err
+		}
+	case types.FaultKindError:
+		if rule.Status == 0 {
+			rule.Status = http.StatusInternalServerError
+		}
+		if !slices.Contains(faultStatuses, rule.Status) {
+			return fmt.Errorf("status must be one of %v", faultStatuses)
+		}
+	case types.FaultKindDrop:
+	case types.FaultKindTimeout:
+		if rule.TimeoutMS < 0 {
+			return fmt.Errorf("timeout_ms must not be negative")
+		}
+	default:
+		return fmt.Errorf("kind must be latency, error, drop or timeout")
+	}
+	return nil
+}
+
+func validateLatency(latency *types.Latency) error {
+	if latency.MinMS < 0 || latency.MaxMS < 0 || latency.MeanMS < 0 || latency.StdDevMS < 0 {
+		return fmt.Errorf("latency values must not be negative")
+	}
+	switch latency.Distribution {
+	case "fixed", "exponential":
+		if latency.MeanMS == 0 {
+			return fmt.Errorf("%s latency needs mean_ms", latency.Distribution)
+		}
+	case "uniform":
+		if latency.MaxMS < latency.MinMS || latency.MaxMS == 0 {
+			return fmt.Errorf("uniform latency needs 0 <= min_ms <= max_ms")
+		}
+	case "normal":
+		if latency.MeanMS == 0 {
+			return fmt.Errorf("normal latency needs mean_ms")
+		}
+	default:
+		return fmt.Errorf("latency distribution must be fixed, uniform, normal or exponential")
+	}
+	return nil
+}
+
+// sampleLatency draws a delay from a validated distribution.
+func sampleLatency(latency *types.Latency) time.Duration {
+	var ms float64
+	switch latency.Distribution {
+	case "fixed":
+		ms = float64(latency.MeanMS)
+	case "uniform":
+		ms = float64(latency.MinMS) + rand.Float64()*float64(latency.MaxMS-latency.MinMS)
+	case "normal":
+		ms = math.Max(0, float64(latency.MeanMS)+rand.NormFloat64()*float64(latency.StdDevMS))
+	case "exponential":
+		ms = rand.ExpFloat64() * float64(latency.MeanMS)
+	}
+	return time.Duration(ms * float64(time.Millisecond))
+}
+
+// faultRuleMatches reports whether rule applies to r. paymentType lazily reads the
+// request's payment type.
+func faultRuleMatches(rule types.FaultRule, r *http.Request, paymentType func() types.PaymentType) bool {
+	if rule.Method != "" && rule.Method != r.Method {
+		return false
+	}
+	if prefix, ok := strings.CutSuffix(rule.Route, "*"); ok {
+		if !strings.HasPrefix(r.URL.Path, prefix) {
+			return false
+		}
+	} else if rule.Route != "" && rule.Route != r.URL.Path {
+		return false
+	}
+	return rule.PaymentType == "" || rule.PaymentType == paymentType()
+}
+
+// requestPaymentType returns the payment type of an /accounts path or of the
+// "type" field of a JSON body, restoring the body for the handler. Only the
+// first maxIdempotentRequestBytes are inspected; the rest is left unread.
+func requestPaymentType(r *http.Request) types.PaymentType {
+	if rest, ok := strings.CutPrefix(r.URL.Path, "/accounts/"); ok {
+		paymentType, _, _ := strings.Cut(rest, "/")
+		return types.PaymentType(paymentType)
+	}
+	if r.Body == nil {
+		return ""
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxIdempotentRequestBytes))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+	if err != nil {
+		return ""
+	}
+	var fields struct {
+		Type types.PaymentType `json:"type"`
+	}
+	_ = json.Unmarshal(body, &fields)
+	return fields.Type
+}
+
+// parseFaultHeader converts an X-Mock-Fault header into rules that always fire.
+func parseFaultHeader(header string) ([]types.FaultRule, error) {
+	var rules []types.FaultRule
+	for _, part := range strings.Split(header, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		rule := types.FaultRule{Rate: faultRate(1)}
+		switch name {
+		case "":
+			continue
+		case "latency":
+			delay, err := time.ParseDuration(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid latency %q", value)
+			}
+			rule.Kind = types.FaultKindLatency
+			rule.Latency = &types.Latency{Distribution: "fixed", MeanMS: int(delay.Milliseconds())}
+		case "timeout":
+			rule.Kind = types.FaultKindTimeout
+			if value != "" {
+				timeout, err := time.ParseDuration(value)
+				if err != nil {
+					return nil, fmt.Errorf("invalid timeout %q", value)
+				}
+				rule.TimeoutMS = int(timeout.Milliseconds())
+			}
+		case "drop":
+			rule.Kind = types.FaultKindDrop
+		case "error":
+			rule.Kind = types.FaultKindError
+			rule.Status = http.StatusInternalServerError
+		default:
+			status, err := strconv.Atoi(name)
+			if err != nil || !slices.Contains(faultStatuses, status) {
+				return nil, fmt.Errorf("unknown fault %q", part)
+			}
+			rule.Kind = types.FaultKindError
+			rule.Status = status
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// wrap applies the X-Mock-Fault header and the configured rules to requests.
+// Latency faults add up; the first error, drop or timeout fault that fires
+// ends the evaluation. Admin routes are never faulted. It runs after
+// authentication, so requests without a valid key cannot trigger faults.
+func (f *faultInjector) wrap(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/admin/") {
+			next(w, r)
+			return
+		}
+		rules, err := parseFaultHeader(r.Header.Get(faultHeader))
+		if err != nil {
+			writeAPIError(w, r, http.StatusBadRequest, types.APIError{Message: err.Error(), Code: data.CodeParameterInvalid, Param: faultHeader})
+			return
+		}
+		var (
+			paymentType types.PaymentType
+			peeked      bool
+		)
+		lazyPaymentType := func() types.PaymentType {
+			if !peeked {
+				paymentType, peeked = requestPaymentType(r), true
+			}
+			return paymentType
+		}
+		for _, rule := range f.list() {
+			if faultRuleMatches(rule, r, lazyPaymentType) && rand.Float64() < *rule.Rate {
+				rules = append(rules, rule)
+			}
+		}
+
+		var delay time.Duration
+		for _, rule := range rules {
+			if rule.Kind == types.FaultKindLatency {
+				delay += sampleLatency(rule.Latency)
+				continue
+			}
+			if !sleepContext(r, delay) {
+				return
+			}
+			log.Printf("fault: injecting %s into %s %s", rule.Kind, r.Method, r.URL.Path)
+			injectFault(w, r, rule, next)
+			return
+		}
+		if delay > 0 {
+			log.Printf("fault: delaying %s %s by %s", r.Method, r.URL.Path, delay)
+			w.Header().Set(faultInjectedHeader, string(types.FaultKindLatency))
+			if !sleepContext(r, delay) {
+				return
+			}
+		}
+		next(w, r)
+	}
+}
+
+func injectFault(w http.ResponseWriter, r *http.Request, rule types.FaultRule, next http.HandlerFunc) {
+	switch rule.Kind {
+	case types.FaultKindError:
+		w.Header().Set(faultInjectedHeader, string(rule.Kind))
+		if rule.Status == http.StatusTooManyRequests {
+			w.Header().Set("Retry-After", "1")
+		}
+		writeAPIError(w, r, rule.Status, types.APIError{
+			Message: fmt.Sprintf("Injected fault: %d %s", rule.Status, http.StatusText(rule.Status)),
+			Code:    codeInjectedFault,
+		})
+	case types.FaultKindDrop:
+		rec := &responseRecorder{header: make(http.Header), status: http.StatusOK}
+		next(rec, r)
+		body := rec.body.Bytes()
+		for name, values := range rec.header {
+			w.Header()[name] = values
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.WriteHeader(rec.status)
+		_, _ = w.Write(body[:len(body)/2])
+		if flusher, ok := w.(http.Flusher); ok {
+			flusher.Flush()
+		}
+		panic(http.ErrAbortHandler)
+	case types.FaultKindTimeout:
+		timeout := defaultFaultTimeout
+		if rule.TimeoutMS > 0 {
+			timeout = time.Duration(rule.TimeoutMS) * time.Millisecond
+		}
+		sleepContext(r, timeout)
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// sleepContext waits for d unless the client goes away first, reporting
+// whether the full duration elapsed.
+func sleepContext(r *http.Request, d time.Duration) bool {
+	if d <= 0 {
+		return true
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-r.Context().Done():
+		return false
+	}
+}
+
+func (s *PaymentServer) handleFaults(w http.ResponseWriter, r *http.Request) {
+	if !requireTenantAdmin(w, r) {
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		log.Printf("REST ListFaultRules called")
+		writeJSON(w, http.StatusOK, types.FaultRules{Data: s.faults.list()})
+	case http.MethodPost:
+		var rule types.FaultRule
+		if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+			writeDecodeError(w, r, err)
+			return
+		}
+		log.Printf("REST CreateFaultRule called kind=%s route=%s", rule.Kind, rule.Route)
+		added, err := s.faults.add(rule)
+		if err != nil {
+			writeAPIError(w, r, http.StatusBadRequest, types.APIError{Message: err.Error(), Code: data.CodeParameterInvalid})
+			return
+		}
+		writeJSON(w, http.StatusCreated, added[0])
+	case http.MethodDelete:
+		log.Printf("REST ClearFaultRules called")
+		s.faults.clear()
+		writeJSON(w, http.StatusOK, types.FaultRules{Data: []types.FaultRule{}})
+	default:
+		writeMethodNotAllowed(w, r)
+	}
+}
+
+func (s *PaymentServer) handleFaultByID(w http.ResponseWriter, r *http.Request) {
+	if !requireTenantAdmin(w, r) {
+		return
+	}
+	if r.Method != http.MethodDelete {
+		writeMethodNotAllowed(w, r)
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, "/admin/faults/")
+	log.Printf("REST DeleteFaultRule called id=%s", id)
+	if !s.faults.remove(id) {
+		writeAPIError(w, r, http.StatusNotFound, types.APIError{Message: "fault rule not found: " + id, Code: data.CodeResourceMissing})
+		return
+	}
+	writeJSON(w, http.StatusOK, types.DeletedObject{ID: id, Object: "fault_rule", Deleted: true})
+}
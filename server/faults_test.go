@@ -0,0 +1,100 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/nerdgarten/mock-payment-service/types"
+)
+
+func TestNormalizeFaultRuleRate(t *testing.T) {
+	tests := []struct {
+		name    string
+		rate    *float64
+		want    float64
+		wantErr bool
+	}{
+		{name: "omitted", rate: nil, want: 1},
+		{name: "zero", rate: faultRate(0), want: 0},
+		{name: "share", rate: faultRate(0.25), want: 0.25},
+		{name: "negative", rate: faultRate(-0.1), wantErr: true},
+		{name: "above one", rate: faultRate(1.5), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := types.FaultRule{Kind: types.FaultKindDrop, Rate: tt.rate}
+			err := normalizeFaultRule(&rule)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("normalizeFaultRule accepted rate %v", *tt.rate)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("normalizeFaultRule: %v", err)
+			}
+			if rule.Rate == nil || *rule.Rate != tt.want {
+				t.Errorf("rate = %v, want %v", rule.Rate, tt.want)
+			}
+		})
+	}
+}
+
+func TestFaultInjectorRejectsDuplicateIDsInBatch(t *testing.T) {
+	var f faultInjector
+	_, err := f.add(
+		types.FaultRule{ID: "fault_a", Kind: types.FaultKindDrop},
+		types.FaultRule{ID: "fault_a", Kind: types.FaultKindTimeout},
+	)
+	if err == nil {
+		t.Fatal("add accepted two rules with the same id")
+	}
+	if rules := f.list(); len(rules) != 0 {
+		t.Errorf("rejected batch left %d rules", len(rules))
+	}
+	if _, err := f.add(types.FaultRule{ID: "fault_a", Kind: types.FaultKindDrop}); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	if _, err := f.add(types.FaultRule{ID: "fault_a", Kind: types.FaultKindDrop}); err == nil {
+		t.Error("add accepted an id that is already in use")
+	}
+}
+
+func TestZeroRateFaultNeverFires(t *testing.T) {
+	s, ts := newTestServer(t)
+	if err := s.AddFaultRules(types.FaultRule{Route: "/customers", Kind: types.FaultKindError, Rate: faultRate(0)}); err != nil {
+		t.Fatalf("AddFaultRules: %v", err)
+	}
+	for range 20 {
+		if status := call(t, ts, http.MethodGet, "/customers", "", nil, nil); status != http.StatusOK {
+			t.Fatalf("status = %d, want 200", status)
+		}
+	}
+
+	var rule types.FaultRule
+	if status := call(t, ts, http.MethodPost, "/admin/faults", "", map[string]any{"kind": "error"}, &rule); status != http.StatusCreated {
+		t.Fatalf("add fault: status = %d, want 201", status)
+	}
+	if rule.Rate == nil || *rule.Rate != 1 {
+		t.Errorf("omitted rate = %v, want 1", rule.Rate)
+	}
+}
+
+func TestFaultsApplyAfterAuthentication(t *testing.T) {
+	s, ts := newTestServer(t)
+	if err := s.RequireAPIKeys(testAdminKey); err != nil {
+		t.Fatalf("RequireAPIKeys: %v", err)
+	}
+	if err := s.AddFaultRules(types.FaultRule{Kind: types.FaultKindError, Status: http.StatusServiceUnavailable}); err != nil {
+		t.Fatalf("AddFaultRules: %v", err)
+	}
+	if status := call(t, ts, http.MethodGet, "/customers", "", nil, nil); status != http.StatusUnauthorized {
+		t.Errorf("anonymous request: status = %d, want 401", status)
+	}
+	if status := call(t, ts, http.MethodGet, "/customers", "sk_test_unknown000000000000", nil, nil); status != http.StatusUnauthorized {
+		t.Errorf("unknown key: status = %d, want 401", status)
+	}
+	if status := call(t, ts, http.MethodGet, "/customers", testAdminKey, nil, nil); status != http.StatusServiceUnavailable {
+		t.Errorf("authenticated request: status = %d, want 503", status)
+	}
+}
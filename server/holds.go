@@ -0,0 +1,129 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/nerdgarten/mock-payment-service/data"
+	"github.com/nerdgarten/mock-payment-service/types"
+)
+
+func (s *PaymentServer) handleHolds(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		s.handleCreateHold(w, r)
+	case http.MethodGet:
+		s.handleListHolds(w, r)
+	default:
+		writeMethodNotAllowed(w, r)
+	}
+}
+
+func (s *PaymentServer) handleCreateHold(w http.ResponseWriter, r *http.Request) {
+	var req types.CreateHoldRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDecodeError(w, r, err)
+		return
+	}
+	if strings.TrimSpace(req.CustomerID) == "" {
+		writeError(w, r, http.StatusBadRequest, "customer_id is required")
+		return
+	}
+	log.Printf("REST CreateHold called customer=%s type=%s amount=%d orderID=%s", req.CustomerID, req.Type, req.Amount, req.OrderID)
+	hold, account, err := data.CreateMockHold(s.storeFor(r), req.CustomerID, req.Type, types.Money{Amount: req.Amount, Currency: req.Currency}, req.OrderID, req.PaymentMethod)
+	if err != nil {
+		writeDataError(w, r, err, nil)
+		return
+	}
+	writeJSON(w, http.StatusCreated, types.CreateHoldResponse{Hold: *hold, Account: *account})
+}
+
+// handleListHolds lists holds, optionally narrowed by customer_id, type,
+// status and created[gte]/created[lte].
+func (s *PaymentServer) handleListHolds(w http.ResponseWriter, r *http.Request) {
+	params, err := parseListParams(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	created, err := parseCreatedRange(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	query := r.URL.Query()
+	filter := data.HoldFilter{
+		CustomerID: query.Get("customer_id"),
+		Type:       types.PaymentType(query.Get("type")),
+		Status:     types.HoldStatus(query.Get("status")),
+		CreatedGTE: created.gte,
+		CreatedLTE: created.lte,
+	}
+	log.Printf("REST ListHolds called customer=%s type=%s status=%s", filter.CustomerID, filter.Type, filter.Status)
+	holds, hasMore, err := data.ListMockHolds(s.storeFor(r), filter, params)
+	if err != nil {
+		writeDataError(w, r, err, nil)
+		return
+	}
+	writeJSON(w, http.StatusOK, types.List[types.Hold]{
+		Object:  "list",
+		Data:    holds,
+		HasMore: hasMore,
+		URL:     r.URL.Path,
+	})
+}
+
+// handleHoldByID serves /holds/{id}, /holds/{id}/capture and
+// /holds/{id}/release.
+func (s *PaymentServer) handleHoldByID(w http.ResponseWriter, r *http.Request) {
+	id, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/holds/"), "/")
+	if id == "" {
+		writeError(w, r, http.StatusBadRequest, "missing hold id")
+		return
+	}
+	if action == "" {
+		if r.Method != http.MethodGet {
+			writeMethodNotAllowed(w, r)
+			return
+		}
+		log.Printf("REST RetrieveHold called id=%s", id)
+		hold := data.GetMockHold(s.storeFor(r), id)
+		if hold == nil {
+			writeError(w, r, http.StatusNotFound, "hold not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, types.RetrieveHoldResponse{Hold: *hold})
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, r)
+		return
+	}
+	switch action {
+	case "capture":
+		var req types.CaptureHoldRequest
+		if err := decodeOptionalJSON(r, &req); err != nil {
+			writeDecodeError(w, r, err)
+			return
+		}
+		log.Printf("REST CaptureHold called id=%s", id)
+		hold, account, err := data.CaptureMockHold(s.storeFor(r), id, req.AmountToCapture)
+		if err != nil {
+			writeDataError(w, r, err, nil)
+			return
+		}
+		writeJSON(w, http.StatusOK, types.CaptureHoldResponse{Hold: *hold, Account: *account})
+	case "release":
+		log.Printf("REST ReleaseHold called id=%s", id)
+		hold, account, err := data.ReleaseMockHold(s.storeFor(r), id)
+		if err != nil {
+			writeDataError(w, r, err, nil)
+			return
+		}
+		writeJSON(w, http.StatusOK, types.ReleaseHoldResponse{Hold: *hold, Account: *account})
+	default:
+		writeError(w, r, http.StatusNotFound, "not found")
+	}
+}
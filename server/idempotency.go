@@ -0,0 +1,215 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/nerdgarten/mock-payment-service/types"
+)
+
+const (
+	idempotencyKeyHeader      = "Idempotency-Key"
+	idempotentReplayedHeader  = "Idempotent-Replayed"
+	idempotencyKeyTTL         = 24 * time.Hour
+	idempotencySweepInterval  = time.Minute
+	codeIdempotencyKeyInUse   = "idempotency_key_in_use"
+	codeIdempotencyKeyReused  = "idempotency_key_reused"
+	maxIdempotentRequestBytes = 1 << 20
+)
+
+// idempotencyCache remembers the first response produced for each Idempotency-Key.
+// Expired entries are swept when new keys are reserved, at most once per
+// idempotencySweepInterval.
+type idempotencyCache struct {
+	mu      sync.Mutex
+	entries map[string]*idempotencyEntry
+	swept   time.Time
+}
+
+// idempotencyEntry is a cached response. done is closed once the response has
+// been recorded; until then the key is in use by the original request.
+type idempotencyEntry struct {
+	fingerprint string
+	created     time.Time
+	done        chan struct{}
+	status      int
+	header      http.Header
+	body        []byte
+}
+
+func newIdempotencyCache() *idempotencyCache {
+	return &idempotencyCache{entries: make(map[string]*idempotencyEntry)}
+}
+
+// wrap makes POST requests carrying an Idempotency-Key header safe to retry.
+// The first response for a key is stored and replayed for retries with the
+// same method, path and body; reusing the key with a different request is
+// rejected with 422, and bodies over maxIdempotentRequestBytes with 413.
+func (c *idempotencyCache) wrap(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyKeyHeader)
+		if r.Method != http.MethodPost || key == "" {
+			next(w, r)
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIdempotentRequestBytes))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				writeError(w, r, http.StatusRequestEntityTooLarge, fmt.Sprintf("request body exceeds %d bytes", tooLarge.Limit))
+				return
+			}
+			writeError(w, r, http.StatusBadRequest, "invalid request payload")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		fingerprint := requestFingerprint(r, body)
+
+		entry, existing := c.reserve(key, fingerprint)
+		if existing {
+			c.replay(w, r, key, fingerprint, entry)
+			return
+		}
+
+		// A panicking handler is completed as a server error, which is not
+		// cached, so the key does not stay in progress until it expires.
+		defer func() {
+			if p := recover(); p != nil {
+				c.complete(key, entry, &responseRecorder{header: make(http.Header), status: http.StatusInternalServerError})
+				panic(p)
+			}
+		}()
+		rec := &responseRecorder{header: make(http.Header), status: http.StatusOK}
+		next(rec, r)
+		c.complete(key, entry, rec)
+		rec.writeTo(w)
+	}
+}
+
+// reserve returns the entry for key, creating an in-flight one if the key is
+// new or expired. existing reports whether the entry was already present.
+func (c *idempotencyCache) reserve(key, fingerprint string) (*idempotencyEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if entry, ok := c.entries[key]; ok && now.Sub(entry.created) < idempotencyKeyTTL {
+		return entry, true
+	}
+	if now.Sub(c.swept) >= idempotencySweepInterval {
+		c.sweep(now)
+	}
+	entry := &idempotencyEntry{
+		fingerprint: fingerprint,
+		created:     now,
+		done:        make(chan struct{}),
+	}
+	c.entries[key] = entry
+	return entry, false
+}
+
+// sweep removes the entries that expired by now. The caller holds c.mu.
+func (c *idempotencyCache) sweep(now time.Time) {
+	for key, entry := range c.entries {
+		if now.Sub(entry.created) >= idempotencyKeyTTL {
+			delete(c.entries, key)
+		}
+	}
+	c.swept = now
+}
+
+// complete records the response for key. Server errors are not cached so the
+// request can be retried.
+func (c *idempotencyCache) complete(key string, entry *idempotencyEntry, rec *responseRecorder) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry.status = rec.status
+	entry.header = rec.header.Clone()
+	entry.body = rec.body.Bytes()
+	if rec.status >= http.StatusInternalServerError && c.entries[key] == entry {
+		delete(c.entries, key)
+	}
+	close(entry.done)
+}
+
+// clear forgets every cached response, e.g. after the state they describe
+// has been replaced.
+func (c *idempotencyCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]*idempotencyEntry)
+}
+
+func (c *idempotencyCache) replay(w http.ResponseWriter, r *http.Request, key, fingerprint string, entry *idempotencyEntry) {
+	if entry.fingerprint != fingerprint {
+		writeAPIError(w, r, http.StatusUnprocessableEntity, types.APIError{
+			Message: "idempotency key " + key + " was already used with different request parameters",
+			Type:    types.ErrorTypeIdempotency,
+			Code:    codeIdempotencyKeyReused,
+		})
+		return
+	}
+	select {
+	case <-entry.done:
+	default:
+		writeAPIError(w, r, http.StatusConflict, types.APIError{
+			Message: "a request with idempotency key " + key + " is still in progress",
+			Type:    types.ErrorTypeIdempotency,
+			Code:    codeIdempotencyKeyInUse,
+		})
+		return
+	}
+	log.Printf("REST replaying response for idempotency key %s", key)
+	for name, values := range entry.header {
+		w.Header()[name] = values
+	}
+	w.Header().Set(idempotentReplayedHeader, "true")
+	w.WriteHeader(entry.status)
+	if _, err := w.Write(entry.body); err != nil {
+		log.Printf("failed to replay response: %v", err)
+	}
+}
+
+// requestFingerprint identifies a request by method, path and body.
+func requestFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method+" "+r.URL.Path+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// responseRecorder buffers a handler's response so it can be cached.
+type responseRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) Header() http.Header {
+	return r.header
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+}
+
+func (r *responseRecorder) Write(p []byte) (int, error) {
+	return r.body.Write(p)
+}
+
+func (r *responseRecorder) writeTo(w http.ResponseWriter) {
+	for name, values := range r.header {
+		w.Header()[name] = values
+	}
+	w.WriteHeader(r.status)
+	if _, err := w.Write(r.body.Bytes()); err != nil {
+		log.Printf("failed to write response: %v", err)
+	}
+}
@@ -0,0 +1,115 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nerdgarten/mock-payment-service/types"
+)
+
+func postWithIdempotencyKey(t *testing.T, url, key, body string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(idempotencyKeyHeader, key)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST %s: %v", url, err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestIdempotentRequestReplaysResponse(t *testing.T) {
+	_, ts := newTestServer(t)
+	body := `{"name":"Ruff","email":"ruff@example.com"}`
+	first := postWithIdempotencyKey(t, ts.URL+"/customers", "key-1", body)
+	var created types.CreateCustomerResponse
+	if err := json.NewDecoder(first.Body).Decode(&created); err != nil {
+		t.Fatalf("decode first response: %v", err)
+	}
+	second := postWithIdempotencyKey(t, ts.URL+"/customers", "key-1", body)
+	var replayed types.CreateCustomerResponse
+	if err := json.NewDecoder(second.Body).Decode(&replayed); err != nil {
+		t.Fatalf("decode replayed response: %v", err)
+	}
+	if second.Header.Get(idempotentReplayedHeader) != "true" {
+		t.Errorf("%s header missing on retry", idempotentReplayedHeader)
+	}
+	if replayed.Customer.ID != created.Customer.ID {
+		t.Errorf("retry created customer %s, want replay of %s", replayed.Customer.ID, created.Customer.ID)
+	}
+
+	reused := postWithIdempotencyKey(t, ts.URL+"/customers", "key-1", `{"name":"Other"}`)
+	if reused.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("reused key status = %d, want 422", reused.StatusCode)
+	}
+}
+
+func TestIdempotentRequestRejectsOversizedBody(t *testing.T) {
+	_, ts := newTestServer(t)
+	name := strings.Repeat("a", maxIdempotentRequestBytes)
+	resp := postWithIdempotencyKey(t, ts.URL+"/customers", "key-large", `{"name":"`+name+`"}`)
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status = %d, want 413", resp.StatusCode)
+	}
+	var envelope types.ErrorEnvelope
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	if envelope.Error.Type != types.ErrorTypeInvalidRequest {
+		t.Errorf("error type = %q, want %q", envelope.Error.Type, types.ErrorTypeInvalidRequest)
+	}
+
+	// The key was not used, so a retry with a smaller body goes through.
+	retry := postWithIdempotencyKey(t, ts.URL+"/customers", "key-large", `{"name":"Ruff"}`)
+	if retry.StatusCode != http.StatusCreated {
+		t.Errorf("retry status = %d, want 201", retry.StatusCode)
+	}
+}
+
+func TestIdempotentRequestPanicReleasesKey(t *testing.T) {
+	cache := newIdempotencyCache()
+	serve := func(handler http.HandlerFunc) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/customers", strings.NewReader(`{}`))
+		req.Header.Set(idempotencyKeyHeader, "key-panic")
+		rec := httptest.NewRecorder()
+		cache.wrap(handler)(rec, req)
+		return rec
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("the handler's panic was swallowed")
+			}
+		}()
+		serve(func(http.ResponseWriter, *http.Request) { panic("boom") })
+	}()
+
+	retry := serve(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusCreated) })
+	if retry.Code != http.StatusCreated {
+		t.Errorf("retry after a panic status = %d, want 201", retry.Code)
+	}
+}
+
+func TestIdempotencyCacheSweepsExpiredEntries(t *testing.T) {
+	cache := newIdempotencyCache()
+	expired, _ := cache.reserve("key-old", "fingerprint")
+	expired.created = time.Now().Add(-idempotencyKeyTTL)
+	cache.swept = time.Time{}
+
+	cache.reserve("key-new", "fingerprint")
+	if _, ok := cache.entries["key-old"]; ok {
+		t.Error("expired entry survived the sweep")
+	}
+	if _, ok := cache.entries["key-new"]; !ok {
+		t.Error("new entry missing")
+	}
+}
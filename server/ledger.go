@@ -0,0 +1,64 @@
+package server
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/nerdgarten/mock-payment-service/data"
+	"github.com/nerdgarten/mock-payment-service/types"
+)
+
+func (s *PaymentServer) handleTransactionByID(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, r)
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, "/transactions/")
+	if id == "" {
+		writeError(w, r, http.StatusBadRequest, "missing transaction id")
+		return
+	}
+	log.Printf("REST RetrieveTransaction called id=%s", id)
+	txn := data.GetTransaction(s.storeFor(r), id)
+	if txn == nil {
+		writeError(w, r, http.StatusNotFound, "transaction not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, types.RetrieveTransactionResponse{Transaction: *txn})
+}
+
+// handleAccountTransactions lists the ledger of one payment type, optionally
+// narrowed by customer_id, kind and created[gte]/created[lte].
+func (s *PaymentServer) handleAccountTransactions(w http.ResponseWriter, r *http.Request, paymentType types.PaymentType) {
+	params, err := parseListParams(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	created, err := parseCreatedRange(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	query := r.URL.Query()
+	filter := data.TransactionFilter{
+		CustomerID:  query.Get("customer_id"),
+		AccountType: paymentType,
+		Kind:        types.TransactionKind(query.Get("kind")),
+		CreatedGTE:  created.gte,
+		CreatedLTE:  created.lte,
+	}
+	log.Printf("REST ListTransactions called type=%s customer=%s", paymentType, filter.CustomerID)
+	txns, hasMore, err := data.ListTransactions(s.storeFor(r), filter, params)
+	if err != nil {
+		writeDataError(w, r, err, nil)
+		return
+	}
+	writeJSON(w, http.StatusOK, types.List[types.Transaction]{
+		Object:  "list",
+		Data:    txns,
+		HasMore: hasMore,
+		URL:     r.URL.Path,
+	})
+}
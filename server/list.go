@@ -0,0 +1,79 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/nerdgarten/mock-payment-service/data"
+)
+
+// parseListParams reads limit, starting_after and ending_before from the query.
+func parseListParams(r *http.Request) (data.ListParams, error) {
+	query := r.URL.Query()
+	params := data.ListParams{
+		StartingAfter: query.Get("starting_after"),
+		EndingBefore:  query.Get("ending_before"),
+	}
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil {
+			return params, fmt.Errorf("invalid limit %q", v)
+		}
+		// A zero Limit selects the default page size, so an explicit 0 is
+		// rejected here rather than passed on.
+		if limit < 1 {
+			return params, fmt.Errorf("limit must be between 1 and %d", data.MaxListLimit)
+		}
+		params.Limit = limit
+	}
+	return params, nil
+}
+
+// createdRange is an inclusive range of Unix timestamps; zero bounds are open.
+type createdRange struct {
+	gte int64
+	lte int64
+}
+
+// parseCreatedRange reads the created[gt], created[gte], created[lt] and
+// created[lte] filters from the query. When both forms of a bound are given,
+// the tighter one applies. Lower bounds before the epoch leave the range open;
+// upper bounds before the first second of the epoch would read as open, so
+// they are rejected.
+func parseCreatedRange(r *http.Request) (createdRange, error) {
+	var rng createdRange
+	hasUpper := false
+	query := r.URL.Query()
+	for _, op := range []string{"gt", "gte", "lt", "lte"} {
+		key := "created[" + op + "]"
+		v := query.Get(key)
+		if v == "" {
+			continue
+		}
+		ts, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return rng, fmt.Errorf("invalid %s %q", key, v)
+		}
+		switch op {
+		case "gt", "gte":
+			if op == "gt" && ts < math.MaxInt64 {
+				ts++
+			}
+			rng.gte = max(rng.gte, ts)
+		case "lt", "lte":
+			if op == "lt" {
+				ts--
+			}
+			if ts < 1 {
+				return rng, fmt.Errorf("invalid %s %q: must be after the Unix epoch", key, v)
+			}
+			if !hasUpper || ts < rng.lte {
+				rng.lte = ts
+			}
+			hasUpper = true
+		}
+	}
+	return rng, nil
+}
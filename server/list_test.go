@@ -0,0 +1,34 @@
+package server
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseCreatedRange(t *testing.T) {
+	tests := []struct {
+		query string
+		want  createdRange
+	}{
+		{"", createdRange{}},
+		{"created[gt]=100&created[lt]=200", createdRange{gte: 101, lte: 199}},
+		{"created[gt]=-1", createdRange{}},
+		{"created[gte]=100&created[gt]=100", createdRange{gte: 101}},
+		{"created[gt]=100&created[gte]=150", createdRange{gte: 150}},
+		{"created[lte]=200&created[lt]=200", createdRange{lte: 199}},
+		{"created[lt]=300&created[lte]=250", createdRange{lte: 250}},
+		{"created[lt]=2", createdRange{lte: 1}},
+	}
+	for _, tt := range tests {
+		got, err := parseCreatedRange(httptest.NewRequest("GET", "/customers?"+tt.query, nil))
+		if err != nil || got != tt.want {
+			t.Errorf("parseCreatedRange(%q) = %+v, %v, want %+v", tt.query, got, err, tt.want)
+		}
+	}
+
+	for _, query := range []string{"created[lt]=1", "created[lte]=0", "created[lte]=-5", "created[lte]=100&created[lt]=0"} {
+		if _, err := parseCreatedRange(httptest.NewRequest("GET", "/customers?"+query, nil)); err == nil {
+			t.Errorf("parseCreatedRange(%q) accepted an upper bound that reads as open", query)
+		}
+	}
+}
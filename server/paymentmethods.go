@@ -0,0 +1,130 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/nerdgarten/mock-payment-service/data"
+	"github.com/nerdgarten/mock-payment-service/types"
+)
+
+// handlePaymentMethods creates payment methods, also with publishable keys so
+// that card details can be sent straight from a browser, and lists them.
+func (s *PaymentServer) handlePaymentMethods(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		s.handleCreatePaymentMethod(w, r)
+	case http.MethodGet:
+		if publishableRequest(r) {
+			writeSecretKeyRequired(w, r)
+			return
+		}
+		s.handleListPaymentMethods(w, r)
+	default:
+		writeMethodNotAllowed(w, r)
+	}
+}
+
+func (s *PaymentServer) handleCreatePaymentMethod(w http.ResponseWriter, r *http.Request) {
+	var req types.CreatePaymentMethodRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDecodeError(w, r, err)
+		return
+	}
+	if req.Customer != "" && publishableRequest(r) {
+		writeSecretKeyRequired(w, r)
+		return
+	}
+	log.Printf("REST CreatePaymentMethod called type=%s", req.Type)
+	method, err := data.CreateMockPaymentMethod(s.storeFor(r), req)
+	if err != nil {
+		writeDataError(w, r, err, nil)
+		return
+	}
+	writeJSON(w, http.StatusCreated, types.CreatePaymentMethodResponse{PaymentMethod: *method})
+}
+
+// handleListPaymentMethods lists payment methods, optionally narrowed by
+// customer, type and card fingerprint.
+func (s *PaymentServer) handleListPaymentMethods(w http.ResponseWriter, r *http.Request) {
+	params, err := parseListParams(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	query := r.URL.Query()
+	filter := data.PaymentMethodFilter{
+		Customer:    query.Get("customer"),
+		Type:        types.PaymentMethodType(query.Get("type")),
+		Fingerprint: query.Get("fingerprint"),
+	}
+	log.Printf("REST ListPaymentMethods called customer=%s type=%s", filter.Customer, filter.Type)
+	methods, hasMore, err := data.ListMockPaymentMethods(s.storeFor(r), filter, params)
+	if err != nil {
+		writeDataError(w, r, err, nil)
+		return
+	}
+	writeJSON(w, http.StatusOK, types.List[types.PaymentMethod]{
+		Object:  "list",
+		Data:    methods,
+		HasMore: hasMore,
+		URL:     r.URL.Path,
+	})
+}
+
+// handlePaymentMethodByID serves GET /payment-methods/{id} and
+// POST /payment-methods/{id}/attach or /detach.
+func (s *PaymentServer) handlePaymentMethodByID(w http.ResponseWriter, r *http.Request) {
+	id, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/payment-methods/"), "/")
+	if id == "" {
+		writeError(w, r, http.StatusBadRequest, "missing payment method id")
+		return
+	}
+	switch action {
+	case "":
+		if r.Method != http.MethodGet {
+			writeMethodNotAllowed(w, r)
+			return
+		}
+		log.Printf("REST RetrievePaymentMethod called id=%s", id)
+		method := data.GetMockPaymentMethod(s.storeFor(r), id)
+		if method == nil {
+			writeError(w, r, http.StatusNotFound, "payment method not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, types.RetrievePaymentMethodResponse{PaymentMethod: *method})
+	case "attach":
+		if r.Method != http.MethodPost {
+			writeMethodNotAllowed(w, r)
+			return
+		}
+		var req types.AttachPaymentMethodRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeDecodeError(w, r, err)
+			return
+		}
+		log.Printf("REST AttachPaymentMethod called id=%s customer=%s", id, req.Customer)
+		method, err := data.AttachMockPaymentMethod(s.storeFor(r), id, req.Customer)
+		if err != nil {
+			writeDataError(w, r, err, nil)
+			return
+		}
+		writeJSON(w, http.StatusOK, types.AttachPaymentMethodResponse{PaymentMethod: *method})
+	case "detach":
+		if r.Method != http.MethodPost {
+			writeMethodNotAllowed(w, r)
+			return
+		}
+		log.Printf("REST DetachPaymentMethod called id=%s", id)
+		method, err := data.DetachMockPaymentMethod(s.storeFor(r), id)
+		if err != nil {
+			writeDataError(w, r, err, nil)
+			return
+		}
+		writeJSON(w, http.StatusOK, types.DetachPaymentMethodResponse{PaymentMethod: *method})
+	default:
+		writeError(w, r, http.StatusNotFound, "not found")
+	}
+}
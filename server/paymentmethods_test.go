@@ -0,0 +1,52 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/nerdgarten/mock-payment-service/data"
+	"github.com/nerdgarten/mock-payment-service/types"
+)
+
+func TestPaymentMethodEndpoints(t *testing.T) {
+	_, ts := newTestServer(t)
+	var created types.CreatePaymentMethodResponse
+	req := types.CreatePaymentMethodRequest{Type: types.PaymentMethodTypeMobileBanking, MobileBanking: &types.MobileBanking{Bank: "scb"}}
+	if status := call(t, ts, http.MethodPost, "/payment-methods", "", req, &created); status != http.StatusCreated {
+		t.Fatalf("create payment method: status = %d, want 201", status)
+	}
+	id := created.PaymentMethod.ID
+
+	var attached types.AttachPaymentMethodResponse
+	attach := types.AttachPaymentMethodRequest{Customer: "cus_mock_12345"}
+	if status := call(t, ts, http.MethodPost, "/payment-methods/"+id+"/attach", "", attach, &attached); status != http.StatusOK {
+		t.Fatalf("attach: status = %d, want 200", status)
+	}
+	if attached.PaymentMethod.Customer != "cus_mock_12345" {
+		t.Errorf("attached customer = %q", attached.PaymentMethod.Customer)
+	}
+	var list types.List[types.PaymentMethod]
+	if status := call(t, ts, http.MethodGet, "/payment-methods?customer=cus_mock_12345", "", nil, &list); status != http.StatusOK {
+		t.Fatalf("list: status = %d, want 200", status)
+	}
+	if len(list.Data) != 1 || list.Data[0].ID != id {
+		t.Errorf("listed payment methods = %+v, want %s", list.Data, id)
+	}
+
+	var envelope types.ErrorEnvelope
+	status := call(t, ts, http.MethodPost, "/payment-methods/"+id+"/attach", "", types.AttachPaymentMethodRequest{Customer: "cus_mock_67890"}, &envelope)
+	if status != http.StatusConflict || envelope.Error.Code != data.CodePaymentMethodUnexpectedState {
+		t.Errorf("attach to another customer: status %d code %q, want 409 %q", status, envelope.Error.Code, data.CodePaymentMethodUnexpectedState)
+	}
+
+	var detached types.DetachPaymentMethodResponse
+	if status := call(t, ts, http.MethodPost, "/payment-methods/"+id+"/detach", "", nil, &detached); status != http.StatusOK {
+		t.Fatalf("detach: status = %d, want 200", status)
+	}
+	if detached.PaymentMethod.Customer != "" {
+		t.Errorf("detached customer = %q, want none", detached.PaymentMethod.Customer)
+	}
+	if status := call(t, ts, http.MethodGet, "/payment-methods/pm_missing", "", nil, nil); status != http.StatusNotFound {
+		t.Errorf("retrieve missing: status = %d, want 404", status)
+	}
+}
@@ -2,106 +2,414 @@ package server
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
 	"log"
 	"net/http"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/nerdgarten/mock-payment-service/data"
 	"github.com/nerdgarten/mock-payment-service/types"
 )
 
-// PaymentServer exposes HTTP handlers for the mock payment API.
-type PaymentServer struct{}
+// PaymentServer exposes HTTP handlers for the mock payment API. Each API key
+// belongs to a tenant with its own store.
+type PaymentServer struct {
+	tenants *tenantRegistry
+	faults  *faultInjector
+	// addKeysMu serializes the check and registration of new API keys, so
+	// a tenant store is only created once its keys are known to be free.
+	addKeysMu sync.Mutex
 
-// NewPaymentServer creates a new PaymentServer instance.
-func NewPaymentServer() *PaymentServer {
-	return &PaymentServer{}
+	// NewTenantStore creates the store of each tenant added through
+	// /admin/tenants, e.g. to subscribe a webhook dispatcher to it. The
+	// default tenant's fixtures are restored into the new store.
+	NewTenantStore func() data.Store
+	// LegacyErrors restores the original error responses: a flat
+	// types.ErrorResponse body, and HTTP 200 with success false from the
+	// wallet endpoints.
+	LegacyErrors bool
 }
 
-// RegisterRoutes registers HTTP endpoints on the provided mux.
+// NewPaymentServer creates a new PaymentServer instance whose default tenant
+// is backed by store. The store's current state becomes the fixtures restored
+// by /admin/reset and copied into new tenants.
+func NewPaymentServer(store data.Store) *PaymentServer {
+	defaultTenant := newTenant(types.Tenant{
+		ID:      DefaultTenantID,
+		Object:  "tenant",
+		Name:    "default",
+		APIKeys: []string{},
+		Created: time.Now().Unix(),
+	}, store)
+	return &PaymentServer{
+		tenants: newTenantRegistry(defaultTenant),
+		faults:  &faultInjector{},
+		NewTenantStore: func() data.Store {
+			return data.NewEmptyMemoryStore()
+		},
+	}
+}
+
+// RequireAPIKeys adds keys to the default tenant. Once it has a key, every
+// route requires a bearer token; publishable keys are only accepted by
+// client-side-safe routes. Without keys the default tenant is open.
+func (s *PaymentServer) RequireAPIKeys(keys ...string) error {
+	parsed, err := parseAPIKeys(keys)
+	if err != nil {
+		return err
+	}
+	s.addKeysMu.Lock()
+	defer s.addKeysMu.Unlock()
+	return s.tenants.add(s.tenants.defaultTenant(), parsed)
+}
+
+// AddFaultRules validates rules and adds them to the faults injected in front
+// of every route except the admin endpoints.
+func (s *PaymentServer) AddFaultRules(rules ...types.FaultRule) error {
+	_, err := s.faults.add(rules...)
+	return err
+}
+
+// idempotent applies the Idempotency-Key handling of the request's tenant.
+func (s *PaymentServer) idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		requestTenant(r).idempotency.wrap(next)(w, r)
+	}
+}
+
+// expire cancels the tenant's authorizations that passed their capture
+// deadline and expires its overdue holds before serving the request, so that
+// no route observes them.
+func (s *PaymentServer) expire(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		store, now := s.storeFor(r), time.Now()
+		if n, err := data.ExpireMockAuthorizations(store, now); err != nil {
+			log.Printf("expire authorizations: %v", err)
+		} else if n > 0 {
+			log.Printf("Expired %d uncaptured authorizations", n)
+		}
+		if n, err := data.ExpireMockHolds(store, now); err != nil {
+			log.Printf("expire holds: %v", err)
+		} else if n > 0 {
+			log.Printf("Expired %d holds", n)
+		}
+		next(w, r)
+	}
+}
+
+// RegisterRoutes registers HTTP endpoints on the provided mux. Every route
+// checks the API key, passes through fault injection and expires overdue
+// authorizations and holds, and POST requests honour the Idempotency-Key header.
 func (s *PaymentServer) RegisterRoutes(mux *http.ServeMux) {
-	mux.HandleFunc("/customers", s.handleCustomers)
-	mux.HandleFunc("/customers/", s.handleCustomerByID)
-	mux.HandleFunc("/payment-intents", s.handlePaymentIntents)
-	mux.HandleFunc("/payment-intents/confirm", s.handleConfirmPaymentIntent)
-	mux.HandleFunc("/refunds", s.handleCreateRefund)
-	mux.HandleFunc("/webhooks/test", s.handleTestWebhook)
+	handle := func(pattern string, handler http.HandlerFunc) {
+		mux.HandleFunc(pattern, s.withRequestID(s.authenticate(s.faults.wrap(s.expire(s.idempotent(handler))), false)))
+	}
+	// handlePublishable registers a client-side-safe route that also accepts
+	// publishable keys.
+	handlePublishable := func(pattern string, handler http.HandlerFunc) {
+		mux.HandleFunc(pattern, s.withRequestID(s.authenticate(s.faults.wrap(s.expire(s.idempotent(handler))), true)))
+	}
+
+	handle("/customers", s.handleCustomers)
+	handle("/customers/", s.handleCustomerByID)
+	handlePublishable("/payment-methods", s.handlePaymentMethods)
+	handle("/payment-methods/", s.handlePaymentMethodByID)
+	handle("/payment-intents", s.handlePaymentIntents)
+	handlePublishable("/payment-intents/confirm", s.handleConfirmPaymentIntent)
+	handle("/payment-intents/", s.handlePaymentIntentAction)
+	handle("/charges", s.handleCharges)
+	handle("/charges/", s.handleChargeByID)
+	handle("/refunds", s.handleRefunds)
+	handle("/refunds/", s.handleRefundByID)
+	handle("/webhooks/test", s.handleTestWebhook)
+	handle("/webhook-endpoints", s.handleWebhookEndpoints)
+	handle("/webhook-endpoints/", s.handleWebhookEndpointByID)
 
 	// New payment gateway endpoints
-	mux.HandleFunc("/accounts/", s.handleGetAccount)
-	mux.HandleFunc("/deposit", s.handleDeposit)
-	mux.HandleFunc("/withdraw", s.handleWithdraw)
-	mux.HandleFunc("/refund", s.handleRefund)
-	mux.HandleFunc("/process-payment", s.handleProcessPayment)
+	handle("/accounts/", s.handleGetAccount)
+	handle("/transactions/", s.handleTransactionByID)
+	handle("/deposit", s.handleDeposit)
+	handle("/withdraw", s.handleWithdraw)
+	handle("/refund", s.handleRefund)
+	handle("/process-payment", s.handleProcessPayment)
+	handle("/holds", s.handleHolds)
+	handle("/holds/", s.handleHoldByID)
+
+	// The 3-D Secure challenge page is opened by the customer's browser and
+	// authorized by the client secret in its URL instead of an API key.
+	mux.HandleFunc("/3ds/", s.withRequestID(s.handleThreeDSecure))
+
+	// Admin endpoints for test isolation
+	handle("/admin/reset", s.handleAdminReset)
+	handle("/admin/snapshot", s.handleAdminSnapshot)
+	handle("/admin/restore", s.handleAdminRestore)
+	handle("/admin/tenants", s.handleTenants)
+	handle("/admin/tenants/", s.handleTenantByID)
+	handle("/admin/faults", s.handleFaults)
+	handle("/admin/faults/", s.handleFaultByID)
 }
 
 func (s *PaymentServer) handleCustomers(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		writeMethodNotAllowed(w)
-		return
+	switch r.Method {
+	case http.MethodPost:
+		s.handleCreateCustomer(w, r)
+	case http.MethodGet:
+		s.handleListCustomers(w, r)
+	default:
+		writeMethodNotAllowed(w, r)
 	}
+}
+
+func (s *PaymentServer) handleCreateCustomer(w http.ResponseWriter, r *http.Request) {
 	var req types.CreateCustomerRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid request payload")
+		writeDecodeError(w, r, err)
 		return
 	}
 	log.Printf("REST CreateCustomer called name=%s email=%s", req.Name, req.Email)
-	customer := data.CreateMockCustomer(req.Name, req.Email)
+	customer, err := data.CreateMockCustomer(s.storeFor(r), req.Name, req.Email)
+	if err != nil {
+		writeDataError(w, r, err, nil)
+		return
+	}
 	writeJSON(w, http.StatusCreated, types.CreateCustomerResponse{Customer: *customer})
 }
 
-func (s *PaymentServer) handleCustomerByID(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		writeMethodNotAllowed(w)
+// handleListCustomers lists customers, optionally narrowed by email and
+// created[gte]/created[lte].
+func (s *PaymentServer) handleListCustomers(w http.ResponseWriter, r *http.Request) {
+	params, err := parseListParams(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	created, err := parseCreatedRange(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	filter := data.CustomerFilter{
+		Email:      r.URL.Query().Get("email"),
+		CreatedGTE: created.gte,
+		CreatedLTE: created.lte,
+	}
+	log.Printf("REST ListCustomers called email=%s", filter.Email)
+	customers, hasMore, err := data.ListMockCustomers(s.storeFor(r), filter, params)
+	if err != nil {
+		writeDataError(w, r, err, nil)
 		return
 	}
-	id := strings.TrimPrefix(r.URL.Path, "/customers/")
+	writeJSON(w, http.StatusOK, types.List[types.Customer]{
+		Object:  "list",
+		Data:    customers,
+		HasMore: hasMore,
+		URL:     r.URL.Path,
+	})
+}
+
+// handleCustomerByID retrieves, updates or deletes a customer, and serves
+// /customers/search and /customers/{id}/accounts.
+func (s *PaymentServer) handleCustomerByID(w http.ResponseWriter, r *http.Request) {
+	id, sub, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/customers/"), "/")
 	if id == "" {
-		writeError(w, http.StatusBadRequest, "missing customer id")
+		writeError(w, r, http.StatusBadRequest, "missing customer id")
 		return
 	}
-	log.Printf("REST RetrieveCustomer called id=%s", id)
-	customer := data.GetMockCustomer(id)
-	if customer == nil {
-		writeError(w, http.StatusNotFound, "customer not found")
+	switch {
+	case id == "search" && sub == "":
+		if r.Method != http.MethodGet {
+			writeMethodNotAllowed(w, r)
+			return
+		}
+		s.handleSearchCustomers(w, r)
+		return
+	case sub == "accounts":
+		if r.Method != http.MethodGet {
+			writeMethodNotAllowed(w, r)
+			return
+		}
+		s.handleCustomerAccounts(w, r, id)
+		return
+	case sub != "":
+		writeError(w, r, http.StatusNotFound, "not found")
 		return
 	}
-	writeJSON(w, http.StatusOK, types.RetrieveCustomerResponse{Customer: *customer})
+	switch r.Method {
+	case http.MethodGet:
+		log.Printf("REST RetrieveCustomer called id=%s", id)
+		customer := data.GetMockCustomer(s.storeFor(r), id)
+		if customer == nil {
+			writeError(w, r, http.StatusNotFound, "customer not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, types.RetrieveCustomerResponse{Customer: *customer})
+	case http.MethodPost:
+		var req types.UpdateCustomerRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeDecodeError(w, r, err)
+			return
+		}
+		log.Printf("REST UpdateCustomer called id=%s", id)
+		customer, err := data.UpdateMockCustomer(s.storeFor(r), id, req.Name, req.Email, req.DefaultPaymentMethod)
+		if err != nil {
+			writeDataError(w, r, err, nil)
+			return
+		}
+		writeJSON(w, http.StatusOK, types.UpdateCustomerResponse{Customer: *customer})
+	case http.MethodDelete:
+		log.Printf("REST DeleteCustomer called id=%s", id)
+		if err := data.DeleteMockCustomer(s.storeFor(r), id); err != nil {
+			writeDataError(w, r, err, nil)
+			return
+		}
+		writeJSON(w, http.StatusOK, types.DeletedObject{ID: id, Object: "customer", Deleted: true})
+	default:
+		writeMethodNotAllowed(w, r)
+	}
 }
 
-func (s *PaymentServer) handlePaymentIntents(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		writeMethodNotAllowed(w)
+// handleSearchCustomers runs a search query such as email:'ruff@example.com'.
+func (s *PaymentServer) handleSearchCustomers(w http.ResponseWriter, r *http.Request) {
+	params, err := parseListParams(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	query := r.URL.Query()
+	log.Printf("REST SearchCustomers called query=%s", query.Get("query"))
+	customers, hasMore, err := data.SearchMockCustomers(s.storeFor(r), query.Get("query"), params.Limit, query.Get("page"))
+	if err != nil {
+		writeDataError(w, r, err, nil)
 		return
 	}
+	result := types.SearchResult[types.Customer]{
+		Object:  "search_result",
+		Data:    customers,
+		HasMore: hasMore,
+		URL:     r.URL.Path,
+	}
+	if hasMore {
+		result.NextPage = customers[len(customers)-1].ID
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *PaymentServer) handleCustomerAccounts(w http.ResponseWriter, r *http.Request, customerID string) {
+	log.Printf("REST ListCustomerAccounts called customer=%s", customerID)
+	accounts, err := data.ListAccounts(s.storeFor(r), customerID)
+	if err != nil {
+		writeDataError(w, r, err, nil)
+		return
+	}
+	writeJSON(w, http.StatusOK, types.Accounts{Data: accounts})
+}
+
+func (s *PaymentServer) handlePaymentIntents(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		s.handleCreatePaymentIntent(w, r)
+	case http.MethodGet:
+		s.handleListPaymentIntents(w, r)
+	default:
+		writeMethodNotAllowed(w, r)
+	}
+}
+
+func (s *PaymentServer) handleCreatePaymentIntent(w http.ResponseWriter, r *http.Request) {
 	var req types.CreatePaymentIntentRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid request payload")
+		writeDecodeError(w, r, err)
+		return
+	}
+	log.Printf("REST CreatePaymentIntent called amount=%d currency=%s customer=%s capture_method=%s", req.Amount, req.Currency, req.Customer, req.CaptureMethod)
+	intent, err := data.CreateMockPaymentIntent(s.storeFor(r), types.Money{Amount: req.Amount, Currency: req.Currency}, req.Customer, req.PaymentMethod, req.Description, req.CaptureMethod)
+	if err != nil {
+		writeDataError(w, r, err, nil)
 		return
 	}
-	log.Printf("REST CreatePaymentIntent called amount=%d currency=%s", req.Amount, req.Currency)
-	intent := data.CreateMockPaymentIntent(req.Amount, req.Currency, req.PaymentMethod, req.Description)
 	writeJSON(w, http.StatusCreated, types.CreatePaymentIntentResponse{PaymentIntent: *intent})
 }
 
+// handleListPaymentIntents lists payment intents, optionally narrowed by
+// customer, status and created[gte]/created[lte].
+func (s *PaymentServer) handleListPaymentIntents(w http.ResponseWriter, r *http.Request) {
+	params, err := parseListParams(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	created, err := parseCreatedRange(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	query := r.URL.Query()
+	filter := data.PaymentIntentFilter{
+		Customer:   query.Get("customer"),
+		Status:     types.PaymentIntentStatus(query.Get("status")),
+		CreatedGTE: created.gte,
+		CreatedLTE: created.lte,
+	}
+	log.Printf("REST ListPaymentIntents called customer=%s status=%s", filter.Customer, filter.Status)
+	intents, hasMore, err := data.ListMockPaymentIntents(s.storeFor(r), filter, params)
+	if err != nil {
+		writeDataError(w, r, err, nil)
+		return
+	}
+	writeJSON(w, http.StatusOK, types.List[types.PaymentIntent]{
+		Object:  "list",
+		Data:    intents,
+		HasMore: hasMore,
+		URL:     r.URL.Path,
+	})
+}
+
 func (s *PaymentServer) handleConfirmPaymentIntent(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		writeMethodNotAllowed(w)
+		writeMethodNotAllowed(w, r)
 		return
 	}
 	var req types.ConfirmPaymentIntentRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid request payload")
+		writeDecodeError(w, r, err)
 		return
 	}
+	if req.ID == "" {
+		// Client-side confirmations identify the intent by its client secret.
+		req.ID, _, _ = strings.Cut(req.ClientSecret, "_secret_")
+	}
 	if strings.TrimSpace(req.ID) == "" {
-		writeError(w, http.StatusBadRequest, "payment intent id is required")
+		writeError(w, r, http.StatusBadRequest, "payment intent id is required")
+		return
+	}
+	if publishableRequest(r) && req.ClientSecret == "" {
+		writeAPIError(w, r, http.StatusBadRequest, types.APIError{
+			Message: "client_secret is required when confirming with a publishable key",
+			Code:    data.CodeParameterMissing,
+			Param:   "client_secret",
+		})
+		return
+	}
+	if req.ReturnURL != "" && !validReturnURL(req.ReturnURL) {
+		writeAPIError(w, r, http.StatusBadRequest, types.APIError{
+			Message: "return_url must be an absolute http or https URL",
+			Code:    data.CodeParameterInvalid,
+			Param:   "return_url",
+		})
 		return
 	}
 	log.Printf("REST ConfirmPaymentIntent called id=%s", req.ID)
-	intent, charges := data.ConfirmMockPaymentIntent(req.ID)
-	if intent == nil {
-		writeError(w, http.StatusNotFound, "payment intent not found")
+	intent, charges, err := data.ConfirmMockPaymentIntent(s.storeFor(r), req.ID, data.ConfirmParams{
+		PaymentMethod: req.PaymentMethod,
+		ClientSecret:  req.ClientSecret,
+		ReturnURL:     req.ReturnURL,
+		ChallengeURL:  challengeURL(r),
+	})
+	if err != nil {
+		writeDataError(w, r, err, intent)
 		return
 	}
 	resp := types.ConfirmPaymentIntentResponse{PaymentIntent: *intent}
@@ -111,37 +419,277 @@ func (s *PaymentServer) handleConfirmPaymentIntent(w http.ResponseWriter, r *htt
 	writeJSON(w, http.StatusOK, resp)
 }
 
-func (s *PaymentServer) handleCreateRefund(w http.ResponseWriter, r *http.Request) {
+// handlePaymentIntentAction retrieves a payment intent or applies an action
+// to it.
+func (s *PaymentServer) handlePaymentIntentAction(w http.ResponseWriter, r *http.Request) {
+	id, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/payment-intents/"), "/")
+	if id == "" {
+		writeError(w, r, http.StatusNotFound, "not found")
+		return
+	}
+	if action == "" {
+		if r.Method != http.MethodGet {
+			writeMethodNotAllowed(w, r)
+			return
+		}
+		log.Printf("REST RetrievePaymentIntent called id=%s", id)
+		intent := data.GetMockPaymentIntent(s.storeFor(r), id)
+		if intent == nil {
+			writeError(w, r, http.StatusNotFound, "payment intent not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, types.RetrievePaymentIntentResponse{PaymentIntent: *intent})
+		return
+	}
 	if r.Method != http.MethodPost {
-		writeMethodNotAllowed(w)
+		writeMethodNotAllowed(w, r)
+		return
+	}
+	switch action {
+	case "cancel":
+		var req types.CancelPaymentIntentRequest
+		if err := decodeOptionalJSON(r, &req); err != nil {
+			writeDecodeError(w, r, err)
+			return
+		}
+		log.Printf("REST CancelPaymentIntent called id=%s reason=%s", id, req.CancellationReason)
+		intent, err := data.CancelMockPaymentIntent(s.storeFor(r), id, req.CancellationReason)
+		if err != nil {
+			writeDataError(w, r, err, nil)
+			return
+		}
+		writeJSON(w, http.StatusOK, types.CancelPaymentIntentResponse{PaymentIntent: *intent})
+	case "capture":
+		var req types.CapturePaymentIntentRequest
+		if err := decodeOptionalJSON(r, &req); err != nil {
+			writeDecodeError(w, r, err)
+			return
+		}
+		log.Printf("REST CapturePaymentIntent called id=%s", id)
+		intent, charges, err := data.CaptureMockPaymentIntent(s.storeFor(r), id, req.AmountToCapture)
+		if err != nil {
+			writeDataError(w, r, err, nil)
+			return
+		}
+		writeJSON(w, http.StatusOK, types.CapturePaymentIntentResponse{PaymentIntent: *intent, Charges: *charges})
+	default:
+		writeError(w, r, http.StatusNotFound, "not found")
+	}
+}
+
+// handleCharges lists charges, optionally narrowed by customer,
+// payment_intent, status and created[gte]/created[lte].
+func (s *PaymentServer) handleCharges(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, r)
+		return
+	}
+	params, err := parseListParams(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	created, err := parseCreatedRange(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	query := r.URL.Query()
+	filter := data.ChargeFilter{
+		Customer:      query.Get("customer"),
+		PaymentIntent: query.Get("payment_intent"),
+		Status:        query.Get("status"),
+		CreatedGTE:    created.gte,
+		CreatedLTE:    created.lte,
+	}
+	log.Printf("REST ListCharges called customer=%s payment_intent=%s", filter.Customer, filter.PaymentIntent)
+	charges, hasMore, err := data.ListMockCharges(s.storeFor(r), filter, params)
+	if err != nil {
+		writeDataError(w, r, err, nil)
+		return
+	}
+	writeJSON(w, http.StatusOK, types.List[types.Charge]{
+		Object:  "list",
+		Data:    charges,
+		HasMore: hasMore,
+		URL:     r.URL.Path,
+	})
+}
+
+func (s *PaymentServer) handleChargeByID(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, r)
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, "/charges/")
+	if id == "" {
+		writeError(w, r, http.StatusBadRequest, "missing charge id")
 		return
 	}
+	log.Printf("REST RetrieveCharge called id=%s", id)
+	charge := data.GetMockCharge(s.storeFor(r), id)
+	if charge == nil {
+		writeError(w, r, http.StatusNotFound, "charge not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, types.RetrieveChargeResponse{Charge: *charge})
+}
+
+func (s *PaymentServer) handleRefunds(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		s.handleCreateRefund(w, r)
+	case http.MethodGet:
+		s.handleListRefunds(w, r)
+	default:
+		writeMethodNotAllowed(w, r)
+	}
+}
+
+func (s *PaymentServer) handleCreateRefund(w http.ResponseWriter, r *http.Request) {
 	var req types.CreateRefundRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid request payload")
+		writeDecodeError(w, r, err)
 		return
 	}
 	if strings.TrimSpace(req.PaymentIntent) == "" {
-		writeError(w, http.StatusBadRequest, "payment_intent is required")
+		writeError(w, r, http.StatusBadRequest, "payment_intent is required")
+		return
+	}
+	log.Printf("REST CreateRefund called payment_intent=%s", req.PaymentIntent)
+	refund, err := data.CreateMockRefund(s.storeFor(r), req.PaymentIntent, req.Amount, req.Reason)
+	if err != nil {
+		writeDataError(w, r, err, nil)
 		return
 	}
-	log.Printf("REST CreateRefund called payment_intent=%s amount=%d", req.PaymentIntent, req.Amount)
-	refund := data.CreateMockRefund(req.PaymentIntent, req.Amount)
 	writeJSON(w, http.StatusCreated, types.CreateRefundResponse{Refund: *refund})
 }
 
+// handleListRefunds lists refunds, optionally narrowed by payment_intent,
+// charge, status and created[gte]/created[lte].
+func (s *PaymentServer) handleListRefunds(w http.ResponseWriter, r *http.Request) {
+	params, err := parseListParams(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	created, err := parseCreatedRange(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	query := r.URL.Query()
+	filter := data.RefundFilter{
+		PaymentIntent: query.Get("payment_intent"),
+		Charge:        query.Get("charge"),
+		Status:        query.Get("status"),
+		CreatedGTE:    created.gte,
+		CreatedLTE:    created.lte,
+	}
+	log.Printf("REST ListRefunds called payment_intent=%s charge=%s", filter.PaymentIntent, filter.Charge)
+	refunds, hasMore, err := data.ListMockRefunds(s.storeFor(r), filter, params)
+	if err != nil {
+		writeDataError(w, r, err, nil)
+		return
+	}
+	writeJSON(w, http.StatusOK, types.List[types.Refund]{
+		Object:  "list",
+		Data:    refunds,
+		HasMore: hasMore,
+		URL:     r.URL.Path,
+	})
+}
+
+func (s *PaymentServer) handleRefundByID(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, r)
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, "/refunds/")
+	if id == "" {
+		writeError(w, r, http.StatusBadRequest, "missing refund id")
+		return
+	}
+	log.Printf("REST RetrieveRefund called id=%s", id)
+	refund := data.GetMockRefund(s.storeFor(r), id)
+	if refund == nil {
+		writeError(w, r, http.StatusNotFound, "refund not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, types.RetrieveRefundResponse{Refund: *refund})
+}
+
 func (s *PaymentServer) handleTestWebhook(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		writeMethodNotAllowed(w)
+		writeMethodNotAllowed(w, r)
 		return
 	}
 	var req types.TestWebhookRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid request payload")
+		writeDecodeError(w, r, err)
 		return
 	}
 	log.Printf("REST TestWebhook called type=%s", req.Type)
-	writeJSON(w, http.StatusOK, types.TestWebhookResponse{Received: true})
+	event, err := data.EmitTestEvent(s.storeFor(r), req.Type, req.Data)
+	if err != nil {
+		writeDataError(w, r, err, nil)
+		return
+	}
+	writeJSON(w, http.StatusOK, types.TestWebhookResponse{Received: true, EventID: event.ID})
+}
+
+func (s *PaymentServer) handleWebhookEndpoints(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		log.Printf("REST ListWebhookEndpoints called")
+		endpoints := data.ListWebhookEndpoints(s.storeFor(r))
+		if endpoints == nil {
+			endpoints = []types.WebhookEndpoint{}
+		}
+		writeJSON(w, http.StatusOK, types.WebhookEndpoints{Data: endpoints})
+	case http.MethodPost:
+		var req types.CreateWebhookEndpointRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeDecodeError(w, r, err)
+			return
+		}
+		log.Printf("REST CreateWebhookEndpoint called url=%s", req.URL)
+		endpoint, err := data.CreateWebhookEndpoint(s.storeFor(r), req.URL, req.EnabledEvents, req.Secret)
+		if err != nil {
+			writeDataError(w, r, err, nil)
+			return
+		}
+		writeJSON(w, http.StatusCreated, types.WebhookEndpointResponse{WebhookEndpoint: *endpoint})
+	default:
+		writeMethodNotAllowed(w, r)
+	}
+}
+
+func (s *PaymentServer) handleWebhookEndpointByID(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/webhook-endpoints/")
+	if id == "" {
+		writeError(w, r, http.StatusBadRequest, "missing webhook endpoint id")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		log.Printf("REST RetrieveWebhookEndpoint called id=%s", id)
+		endpoint := data.GetWebhookEndpoint(s.storeFor(r), id)
+		if endpoint == nil {
+			writeError(w, r, http.StatusNotFound, "webhook endpoint not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, types.WebhookEndpointResponse{WebhookEndpoint: *endpoint})
+	case http.MethodDelete:
+		log.Printf("REST DeleteWebhookEndpoint called id=%s", id)
+		if err := data.DeleteWebhookEndpoint(s.storeFor(r), id); err != nil {
+			writeDataError(w, r, err, nil)
+			return
+		}
+		writeJSON(w, http.StatusOK, types.DeletedObject{ID: id, Object: "webhook_endpoint", Deleted: true})
+	default:
+		writeMethodNotAllowed(w, r)
+	}
 }
 
 func writeJSON(w http.ResponseWriter, status int, payload any) {
@@ -152,22 +700,22 @@ func writeJSON(w http.ResponseWriter, status int, payload any) {
 	}
 }
 
-func writeError(w http.ResponseWriter, status int, message string) {
-	writeJSON(w, status, types.ErrorResponse{Error: message})
-}
-
-func writeMethodNotAllowed(w http.ResponseWriter) {
-	writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+// decodeOptionalJSON decodes the request body into v, accepting an empty body.
+func decodeOptionalJSON(r *http.Request, v any) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
 }
 
 func (s *PaymentServer) handleGetAccount(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		writeMethodNotAllowed(w)
+		writeMethodNotAllowed(w, r)
 		return
 	}
-	paymentTypeStr := strings.TrimPrefix(r.URL.Path, "/accounts/")
+	paymentTypeStr, sub, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/accounts/"), "/")
 	if paymentTypeStr == "" {
-		writeError(w, http.StatusBadRequest, "missing payment type")
+		writeError(w, r, http.StatusBadRequest, "missing payment type")
 		return
 	}
 
@@ -182,14 +730,30 @@ func (s *PaymentServer) handleGetAccount(w http.ResponseWriter, r *http.Request)
 	case "meowth-wallet":
 		paymentType = types.PaymentTypeMeowthWallet
 	default:
-		writeError(w, http.StatusBadRequest, "invalid payment type")
+		writeError(w, r, http.StatusBadRequest, "invalid payment type")
 		return
 	}
 
-	log.Printf("REST GetAccount called type=%s", paymentType)
-	account := data.GetAccount(paymentType)
+	switch sub {
+	case "":
+	case "transactions":
+		s.handleAccountTransactions(w, r, paymentType)
+		return
+	default:
+		writeError(w, r, http.StatusNotFound, "not found")
+		return
+	}
+
+	customerID := r.URL.Query().Get("customer_id")
+	if customerID == "" {
+		writeError(w, r, http.StatusBadRequest, "customer_id is required")
+		return
+	}
+
+	log.Printf("REST GetAccount called customer=%s type=%s", customerID, paymentType)
+	account := data.GetAccount(s.storeFor(r), customerID, paymentType)
 	if account == nil {
-		writeError(w, http.StatusNotFound, "account not found")
+		writeError(w, r, http.StatusNotFound, "account not found")
 		return
 	}
 	writeJSON(w, http.StatusOK, account)
@@ -197,60 +761,115 @@ func (s *PaymentServer) handleGetAccount(w http.ResponseWriter, r *http.Request)
 
 func (s *PaymentServer) handleDeposit(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		writeMethodNotAllowed(w)
+		writeMethodNotAllowed(w, r)
 		return
 	}
 	var req types.DepositRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid request payload")
+		writeDecodeError(w, r, err)
+		return
+	}
+	if strings.TrimSpace(req.CustomerID) == "" {
+		writeError(w, r, http.StatusBadRequest, "customer_id is required")
+		return
+	}
+	log.Printf("REST Deposit called customer=%s type=%s amount=%d", req.CustomerID, req.Type, req.Amount)
+	result, err := data.Deposit(s.storeFor(r), req.CustomerID, req.Type, types.Money{Amount: req.Amount, Currency: req.Currency})
+	if err != nil {
+		if legacyErrors(r) {
+			writeJSON(w, http.StatusOK, types.DepositResponse{Success: false, Message: err.Error()})
+			return
+		}
+		writeDataError(w, r, err, nil)
 		return
 	}
-	log.Printf("REST Deposit called type=%s amount=%d", req.Type, req.Amount)
-	result := data.Deposit(req.Type, req.Amount)
 	writeJSON(w, http.StatusOK, result)
 }
 
 func (s *PaymentServer) handleWithdraw(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		writeMethodNotAllowed(w)
+		writeMethodNotAllowed(w, r)
 		return
 	}
 	var req types.WithdrawRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid request payload")
+		writeDecodeError(w, r, err)
+		return
+	}
+	if strings.TrimSpace(req.CustomerID) == "" {
+		writeError(w, r, http.StatusBadRequest, "customer_id is required")
+		return
+	}
+	log.Printf("REST Withdraw called customer=%s type=%s amount=%d", req.CustomerID, req.Type, req.Amount)
+	result, err := data.Withdraw(s.storeFor(r), req.CustomerID, req.Type, types.Money{Amount: req.Amount, Currency: req.Currency})
+	if err != nil {
+		if legacyErrors(r) {
+			writeJSON(w, http.StatusOK, types.WithdrawResponse{Success: false, Message: err.Error()})
+			return
+		}
+		writeDataError(w, r, err, nil)
 		return
 	}
-	log.Printf("REST Withdraw called type=%s amount=%d", req.Type, req.Amount)
-	result := data.Withdraw(req.Type, req.Amount)
 	writeJSON(w, http.StatusOK, result)
 }
 
 func (s *PaymentServer) handleRefund(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		writeMethodNotAllowed(w)
+		writeMethodNotAllowed(w, r)
 		return
 	}
 	var req types.RefundRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid request payload")
+		writeDecodeError(w, r, err)
+		return
+	}
+	if strings.TrimSpace(req.CustomerID) == "" {
+		writeError(w, r, http.StatusBadRequest, "customer_id is required")
+		return
+	}
+	log.Printf("REST Refund called customer=%s type=%s amount=%d reference=%s", req.CustomerID, req.Type, req.Amount, req.ReferenceID)
+	result, err := data.Refund(s.storeFor(r), req.CustomerID, req.Type, types.Money{Amount: req.Amount, Currency: req.Currency}, req.ReferenceID)
+	if err != nil {
+		if legacyErrors(r) {
+			writeJSON(w, http.StatusOK, types.RefundResponse{Success: false, Message: err.Error()})
+			return
+		}
+		writeDataError(w, r, err, nil)
 		return
 	}
-	log.Printf("REST Refund called type=%s amount=%d reference=%s", req.Type, req.Amount, req.ReferenceID)
-	result := data.Refund(req.Type, req.Amount, req.ReferenceID)
 	writeJSON(w, http.StatusOK, result)
 }
 
 func (s *PaymentServer) handleProcessPayment(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		writeMethodNotAllowed(w)
+		writeMethodNotAllowed(w, r)
 		return
 	}
 	var req types.ProcessPaymentRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid request payload")
+		writeDecodeError(w, r, err)
+		return
+	}
+	if strings.TrimSpace(req.CustomerID) == "" {
+		writeError(w, r, http.StatusBadRequest, "customer_id is required")
+		return
+	}
+	log.Printf("REST ProcessPayment called customer=%s type=%s amount=%d orderID=%s", req.CustomerID, req.Type, req.Amount, req.OrderID)
+	result, err := data.ProcessPayment(s.storeFor(r), req.CustomerID, req.Type, types.Money{Amount: req.Amount, Currency: req.Currency}, req.OrderID, req.PaymentMethod)
+	if err != nil {
+		if legacyErrors(r) {
+			if result == nil {
+				result = &types.ProcessPaymentResponse{Success: false, Message: err.Error()}
+			}
+			writeJSON(w, http.StatusOK, result)
+			return
+		}
+		status, apiErr := dataAPIError(err)
+		if result != nil && result.Charge != nil {
+			apiErr.Charge = result.Charge.ID
+		}
+		writeAPIError(w, r, status, apiErr)
 		return
 	}
-	log.Printf("REST ProcessPayment called type=%s amount=%d orderID=%s", req.Type, req.Amount, req.OrderID)
-	result := data.ProcessPayment(req.Type, req.Amount, req.OrderID)
 	writeJSON(w, http.StatusOK, result)
 }
@@ -0,0 +1,276 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nerdgarten/mock-payment-service/data"
+	"github.com/nerdgarten/mock-payment-service/types"
+)
+
+// newTestServer serves a PaymentServer backed by the standard mock fixtures.
+func newTestServer(t *testing.T) (*PaymentServer, *httptest.Server) {
+	t.Helper()
+	s := NewPaymentServer(data.NewMemoryStore())
+	mux := http.NewServeMux()
+	s.RegisterRoutes(mux)
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+	return s, ts
+}
+
+// call sends a JSON request with an optional bearer token and decodes a JSON
+// response into out when it is not nil. It returns the status code.
+func call(t *testing.T, ts *httptest.Server, method, path, token string, body, out any) int {
+	t.Helper()
+	var reader io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("marshal request: %v", err)
+		}
+		reader = bytes.NewReader(raw)
+	}
+	req, err := http.NewRequest(method, ts.URL+path, reader)
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("%s %s: decode response: %v", method, path, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestIllegalPaymentIntentTransitionsRespondConflict(t *testing.T) {
+	_, ts := newTestServer(t)
+	// pi_mock_24680 is a seeded succeeded intent.
+	for _, action := range []string{"cancel", "capture"} {
+		var envelope types.ErrorEnvelope
+		status := call(t, ts, http.MethodPost, "/payment-intents/pi_mock_24680/"+action, "", nil, &envelope)
+		if status != http.StatusConflict {
+			t.Errorf("%s: status = %d, want 409", action, status)
+		}
+		if envelope.Error.Code != data.CodePaymentIntentUnexpectedState {
+			t.Errorf("%s: code = %q, want %q", action, envelope.Error.Code, data.CodePaymentIntentUnexpectedState)
+		}
+	}
+	var envelope types.ErrorEnvelope
+	status := call(t, ts, http.MethodPost, "/payment-intents/confirm", "", types.ConfirmPaymentIntentRequest{ID: "pi_mock_24680"}, &envelope)
+	if status != http.StatusConflict || envelope.Error.Code != data.CodePaymentIntentUnexpectedState {
+		t.Errorf("confirm: status %d code %q, want 409 %q", status, envelope.Error.Code, data.CodePaymentIntentUnexpectedState)
+	}
+}
+
+func TestCreatePaymentIntentWithTestCards(t *testing.T) {
+	_, ts := newTestServer(t)
+	for _, paymentMethod := range []string{"4242424242424242", "pm_card_visa", "4000000000000002", "4000002760003184"} {
+		var resp types.CreatePaymentIntentResponse
+		req := types.CreatePaymentIntentRequest{Amount: 1200, Currency: "thb", PaymentMethod: paymentMethod}
+		if status := call(t, ts, http.MethodPost, "/payment-intents", "", req, &resp); status != http.StatusCreated {
+			t.Errorf("%s: status = %d, want 201", paymentMethod, status)
+			continue
+		}
+		if resp.PaymentIntent.PaymentMethod != paymentMethod {
+			t.Errorf("%s: payment_method = %q", paymentMethod, resp.PaymentIntent.PaymentMethod)
+		}
+	}
+}
+
+// createManualIntent creates and confirms a manual capture intent for 1200 THB.
+func createManualIntent(t *testing.T, ts *httptest.Server) types.PaymentIntent {
+	t.Helper()
+	var created types.CreatePaymentIntentResponse
+	req := types.CreatePaymentIntentRequest{Amount: 1200, Currency: "thb", PaymentMethod: "pm_card_visa", CaptureMethod: types.CaptureMethodManual}
+	if status := call(t, ts, http.MethodPost, "/payment-intents", "", req, &created); status != http.StatusCreated {
+		t.Fatalf("create intent: status = %d, want 201", status)
+	}
+	var confirmed types.ConfirmPaymentIntentResponse
+	if status := call(t, ts, http.MethodPost, "/payment-intents/confirm", "", types.ConfirmPaymentIntentRequest{ID: created.PaymentIntent.ID}, &confirmed); status != http.StatusOK {
+		t.Fatalf("confirm intent: status = %d, want 200", status)
+	}
+	return confirmed.PaymentIntent
+}
+
+func TestPartialCaptureEndpoint(t *testing.T) {
+	_, ts := newTestServer(t)
+	intent := createManualIntent(t, ts)
+	if intent.Status != types.PaymentIntentStatusRequiresCapture {
+		t.Fatalf("confirmed status = %s, want requires_capture", intent.Status)
+	}
+	amount := types.Amount(500)
+	var resp types.CapturePaymentIntentResponse
+	status := call(t, ts, http.MethodPost, "/payment-intents/"+intent.ID+"/capture", "", types.CapturePaymentIntentRequest{AmountToCapture: &amount}, &resp)
+	if status != http.StatusOK {
+		t.Fatalf("capture: status = %d, want 200", status)
+	}
+	if len(resp.Charges.Data) != 1 || resp.Charges.Data[0].AmountCaptured != 500 || resp.Charges.Data[0].AmountReleased != 700 {
+		t.Errorf("capture charges = %+v, want 500 captured and 700 released", resp.Charges.Data)
+	}
+}
+
+func TestOverdueAuthorizationsExpireOnRequest(t *testing.T) {
+	store := data.NewMemoryStore()
+	store.SetAuthorizationWindow(-time.Second)
+	mux := http.NewServeMux()
+	NewPaymentServer(store).RegisterRoutes(mux)
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+
+	intent := createManualIntent(t, ts)
+	var resp types.RetrievePaymentIntentResponse
+	if status := call(t, ts, http.MethodGet, "/payment-intents/"+intent.ID, "", nil, &resp); status != http.StatusOK {
+		t.Fatalf("retrieve: status = %d, want 200", status)
+	}
+	if resp.PaymentIntent.Status != types.PaymentIntentStatusCanceled || resp.PaymentIntent.CancellationReason != "expired" {
+		t.Errorf("intent status %s reason %q, want canceled as expired", resp.PaymentIntent.Status, resp.PaymentIntent.CancellationReason)
+	}
+}
+
+func TestAccountEndpoints(t *testing.T) {
+	_, ts := newTestServer(t)
+	var created types.CreateCustomerResponse
+	if status := call(t, ts, http.MethodPost, "/customers", "", types.CreateCustomerRequest{Name: "Alice"}, &created); status != http.StatusCreated {
+		t.Fatalf("create customer: status = %d, want 201", status)
+	}
+	customer := created.Customer
+	deposit := types.DepositRequest{CustomerID: customer.ID, Type: types.PaymentTypeCash, Amount: 100}
+	if status := call(t, ts, http.MethodPost, "/deposit", "", deposit, nil); status != http.StatusOK {
+		t.Fatalf("deposit: status = %d, want 200", status)
+	}
+
+	var account types.Account
+	if status := call(t, ts, http.MethodGet, "/accounts/cash?customer_id="+customer.ID, "", nil, &account); status != http.StatusOK {
+		t.Fatalf("get account: status = %d, want 200", status)
+	}
+	if account.CustomerID != customer.ID || account.Balance != data.DefaultAccountBalances[types.PaymentTypeCash]+100 {
+		t.Errorf("account = %+v", account)
+	}
+	var seeded types.Account
+	call(t, ts, http.MethodGet, "/accounts/cash?customer_id=cus_mock_12345", "", nil, &seeded)
+	if seeded.Balance != data.DefaultAccountBalances[types.PaymentTypeCash] {
+		t.Errorf("deposit changed another customer's balance to %d", seeded.Balance)
+	}
+
+	var accounts types.Accounts
+	if status := call(t, ts, http.MethodGet, "/customers/"+customer.ID+"/accounts", "", nil, &accounts); status != http.StatusOK {
+		t.Fatalf("list accounts: status = %d, want 200", status)
+	}
+	if len(accounts.Data) != len(types.PaymentTypes) {
+		t.Errorf("listed %d accounts, want %d", len(accounts.Data), len(types.PaymentTypes))
+	}
+
+	for path, want := range map[string]int{
+		"/accounts/cash": http.StatusBadRequest,
+		"/accounts/bitcoin?customer_id=" + customer.ID: http.StatusBadRequest,
+		"/accounts/cash?customer_id=cus_missing":       http.StatusNotFound,
+		"/customers/cus_missing/accounts":              http.StatusNotFound,
+	} {
+		if status := call(t, ts, http.MethodGet, path, "", nil, nil); status != want {
+			t.Errorf("GET %s: status = %d, want %d", path, status, want)
+		}
+	}
+}
+
+func TestRefundEndpoint(t *testing.T) {
+	_, ts := newTestServer(t)
+	// pi_mock_24680 is a seeded succeeded intent.
+	amount := types.Amount(100)
+	var created types.CreateRefundResponse
+	req := types.CreateRefundRequest{PaymentIntent: "pi_mock_24680", Amount: &amount}
+	if status := call(t, ts, http.MethodPost, "/refunds", "", req, &created); status != http.StatusCreated {
+		t.Fatalf("create refund: status = %d, want 201", status)
+	}
+	if created.Refund.Amount != 100 || created.Refund.PaymentIntent != "pi_mock_24680" {
+		t.Errorf("refund = %+v", created.Refund)
+	}
+
+	tests := []struct {
+		req    types.CreateRefundRequest
+		status int
+		code   string
+	}{
+		{types.CreateRefundRequest{PaymentIntent: "pi_missing"}, http.StatusNotFound, data.CodeResourceMissing},
+		{types.CreateRefundRequest{PaymentIntent: "pi_mock_24680", Amount: new(types.Amount)}, http.StatusBadRequest, data.CodeParameterInvalid},
+	}
+	for _, tt := range tests {
+		var envelope types.ErrorEnvelope
+		status := call(t, ts, http.MethodPost, "/refunds", "", tt.req, &envelope)
+		if status != tt.status || envelope.Error.Code != tt.code {
+			t.Errorf("refund %+v: status %d code %q, want %d %q", tt.req, status, envelope.Error.Code, tt.status, tt.code)
+		}
+	}
+	if status := call(t, ts, http.MethodPost, "/refunds", "", types.CreateRefundRequest{PaymentIntent: "pi_mock_24680"}, nil); status != http.StatusCreated {
+		t.Fatalf("refund the rest: status = %d, want 201", status)
+	}
+	var envelope types.ErrorEnvelope
+	status := call(t, ts, http.MethodPost, "/refunds", "", types.CreateRefundRequest{PaymentIntent: "pi_mock_24680"}, &envelope)
+	if status != http.StatusConflict || envelope.Error.Code != data.CodeChargeAlreadyRefunded {
+		t.Errorf("refund of a refunded charge: status %d code %q, want 409 %q", status, envelope.Error.Code, data.CodeChargeAlreadyRefunded)
+	}
+}
+
+func TestListCustomersPagination(t *testing.T) {
+	_, ts := newTestServer(t)
+	var created []string
+	for _, name := range []string{"Mia", "Noah", "Olivia"} {
+		var resp types.CreateCustomerResponse
+		if status := call(t, ts, http.MethodPost, "/customers", "", types.CreateCustomerRequest{Name: name}, &resp); status != http.StatusCreated {
+			t.Fatalf("create customer: status = %d, want 201", status)
+		}
+		created = append(created, resp.Customer.ID)
+	}
+
+	var seen []string
+	path := "/customers?limit=2"
+	for pages := 0; ; pages++ {
+		if pages == 5 {
+			t.Fatal("pagination did not end")
+		}
+		var list types.List[types.Customer]
+		if status := call(t, ts, http.MethodGet, path, "", nil, &list); status != http.StatusOK {
+			t.Fatalf("GET %s: status = %d, want 200", path, status)
+		}
+		if list.Object != "list" || list.URL != "/customers" || len(list.Data) > 2 {
+			t.Fatalf("GET %s = %+v", path, list)
+		}
+		for _, customer := range list.Data {
+			seen = append(seen, customer.ID)
+		}
+		if !list.HasMore {
+			break
+		}
+		path = "/customers?limit=2&starting_after=" + list.Data[len(list.Data)-1].ID
+	}
+	// Two seeded customers follow the three created ones, newest first.
+	if len(seen) != 5 || seen[0] != created[2] || seen[1] != created[1] || seen[2] != created[0] {
+		t.Errorf("listed customers = %v, want %v reversed followed by the seeded customers", seen, created)
+	}
+
+	var before types.List[types.Customer]
+	call(t, ts, http.MethodGet, "/customers?limit=2&ending_before="+created[0], "", nil, &before)
+	if len(before.Data) != 2 || before.Data[0].ID != created[2] || before.Data[1].ID != created[1] || before.HasMore {
+		t.Errorf("ending_before page = %+v", before)
+	}
+
+	for _, path := range []string{"/customers?limit=abc", "/customers?limit=0", "/customers?limit=101", "/customers?starting_after=cus_missing", "/customers?created[gte]=soon", "/customers?created[lt]=1"} {
+		if status := call(t, ts, http.MethodGet, path, "", nil, nil); status != http.StatusBadRequest {
+			t.Errorf("GET %s: status = %d, want 400", path, status)
+		}
+	}
+}
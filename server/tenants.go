@@ -0,0 +1,306 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nerdgarten/mock-payment-service/data"
+	"github.com/nerdgarten/mock-payment-service/types"
+)
+
+// DefaultTenantID identifies the tenant that owns the store passed to
+// NewPaymentServer and the keys passed to RequireAPIKeys. Only its keys may
+// manage tenants.
+const DefaultTenantID = "tenant_default"
+
+const codeTenantAdminRequired = "tenant_admin_required"
+
+// tenant is an isolated store with its own fixtures and idempotency cache.
+type tenant struct {
+	mu          sync.Mutex
+	info        types.Tenant
+	store       data.Store
+	fixtures    *types.Snapshot
+	idempotency *idempotencyCache
+}
+
+func newTenant(info types.Tenant, store data.Store) *tenant {
+	return &tenant{
+		info:        info,
+		store:       store,
+		fixtures:    data.TakeSnapshot(store),
+		idempotency: newIdempotencyCache(),
+	}
+}
+
+// reset restores the tenant's fixtures and ID sequence and forgets its
+// idempotent responses.
+func (t *tenant) reset() error {
+	if err := data.ResetStore(t.store, t.fixtures); err != nil {
+		return err
+	}
+	t.idempotency.clear()
+	return nil
+}
+
+func (t *tenant) snapshot() types.Tenant {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	info := t.info
+	info.APIKeys = append([]string(nil), t.info.APIKeys...)
+	return info
+}
+
+type tenantContextKey struct{}
+
+// requestTenant returns the tenant that r was authenticated for.
+func requestTenant(r *http.Request) *tenant {
+	return r.Context().Value(tenantContextKey{}).(*tenant)
+}
+
+// storeFor returns the store of the tenant that r was authenticated for.
+func (s *PaymentServer) storeFor(r *http.Request) data.Store {
+	return requestTenant(r).store
+}
+
+// tenantRegistry maps API keys to tenants.
+type tenantRegistry struct {
+	mu      sync.RWMutex
+	tenants map[string]*tenant
+	order   []string
+	keys    map[string]tenantKey
+}
+
+type tenantKey struct {
+	key    APIKey
+	tenant *tenant
+}
+
+func newTenantRegistry(defaultTenant *tenant) *tenantRegistry {
+	return &tenantRegistry{
+		tenants: map[string]*tenant{defaultTenant.info.ID: defaultTenant},
+		order:   []string{defaultTenant.info.ID},
+		keys:    make(map[string]tenantKey),
+	}
+}
+
+func (g *tenantRegistry) defaultTenant() *tenant {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.tenants[DefaultTenantID]
+}
+
+func (g *tenantRegistry) lookup(value string) (APIKey, *tenant, bool) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	entry, ok := g.keys[value]
+	return entry.key, entry.tenant, ok
+}
+
+// defaultRequiresKey reports whether anonymous requests are rejected.
+func (g *tenantRegistry) defaultRequiresKey() bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return len(g.tenants[DefaultTenantID].info.APIKeys) > 0
+}
+
+func (g *tenantRegistry) hasKeys() bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return len(g.keys) > 0
+}
+
+func (g *tenantRegistry) get(id string) *tenant {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.tenants[id]
+}
+
+func (g *tenantRegistry) list() []types.Tenant {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	out := make([]types.Tenant, 0, len(g.order))
+	for _, id := range g.order {
+		out = append(out, g.tenants[id].snapshot())
+	}
+	return out
+}
+
+// add registers t, or only keys when t is already registered. No key is
+// registered when any of them is already in use.
+func (g *tenantRegistry) add(t *tenant, keys []APIKey) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if err := g.checkKeysLocked(keys); err != nil {
+		return err
+	}
+	if _, ok := g.tenants[t.info.ID]; !ok {
+		g.tenants[t.info.ID] = t
+		g.order = append(g.order, t.info.ID)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, key := range keys {
+		g.keys[key.Value] = tenantKey{key: key, tenant: t}
+		t.info.APIKeys = append(t.info.APIKeys, key.Value)
+	}
+	return nil
+}
+
+// checkKeys reports a conflict when any of keys is already in use or repeated.
+func (g *tenantRegistry) checkKeys(keys []APIKey) error {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.checkKeysLocked(keys)
+}
+
+func (g *tenantRegistry) checkKeysLocked(keys []APIKey) error {
+	seen := make(map[string]bool, len(keys))
+	for i, key := range keys {
+		if _, ok := g.keys[key.Value]; ok || seen[key.Value] {
+			return &data.Error{Kind: data.ErrorKindConflict, Code: data.CodeParameterInvalid, Param: fmt.Sprintf("api_keys[%d]", i), Message: fmt.Sprintf("API key %s is already in use", redactAPIKey(key.Value))}
+		}
+		seen[key.Value] = true
+	}
+	return nil
+}
+
+func parseAPIKeys(values []string) ([]APIKey, error) {
+	keys := make([]APIKey, 0, len(values))
+	for i, value := range values {
+		key, err := ParseAPIKey(value)
+		if err != nil {
+			return nil, &data.Error{Kind: data.ErrorKindInvalid, Code: data.CodeParameterInvalid, Param: fmt.Sprintf("api_keys[%d]", i), Message: err.Error()}
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// createTenant adds a tenant whose store starts with the fixtures of the
+// default tenant. Its keys are checked before the store is created, since a
+// store from NewTenantStore may already have a webhook dispatcher subscribed.
+func (s *PaymentServer) createTenant(req types.CreateTenantRequest) (*types.Tenant, error) {
+	values := req.APIKeys
+	if len(values) == 0 {
+		ids := data.NewRandomIDGenerator()
+		values = []string{ids.NewID("sk_test"), ids.NewID("pk_test")}
+	}
+	keys, err := parseAPIKeys(values)
+	if err != nil {
+		return nil, err
+	}
+	s.addKeysMu.Lock()
+	defer s.addKeysMu.Unlock()
+	if err := s.tenants.checkKeys(keys); err != nil {
+		return nil, err
+	}
+	store := s.NewTenantStore()
+	if err := data.RestoreSnapshot(store, s.tenants.defaultTenant().fixtures); err != nil {
+		return nil, err
+	}
+	t := newTenant(types.Tenant{
+		ID:      data.NewRandomIDGenerator().NewID("tenant"),
+		Object:  "tenant",
+		Name:    req.Name,
+		APIKeys: []string{},
+		Created: time.Now().Unix(),
+	}, store)
+	if err := s.tenants.add(t, keys); err != nil {
+		return nil, err
+	}
+	info := t.snapshot()
+	return &info, nil
+}
+
+// requireTenantAdmin rejects requests that did not authenticate with a key of
+// the default tenant. Anonymous requests are allowed while the default tenant
+// requires no key.
+func requireTenantAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if requestTenant(r).info.ID == DefaultTenantID {
+		return true
+	}
+	writeAPIError(w, r, http.StatusForbidden, types.APIError{
+		Message: "Managing tenants requires an API key of the default tenant.",
+		Code:    codeTenantAdminRequired,
+	})
+	return false
+}
+
+// requireTenantAdminKey is requireTenantAdmin for routes that reveal other
+// tenants' API keys, which anonymous requests may never read.
+func requireTenantAdminKey(w http.ResponseWriter, r *http.Request) bool {
+	if _, ok := requestAPIKey(r); ok {
+		return requireTenantAdmin(w, r)
+	}
+	writeAPIError(w, r, http.StatusForbidden, types.APIError{
+		Message: "Reading tenants requires an API key of the default tenant.",
+		Code:    codeTenantAdminRequired,
+	})
+	return false
+}
+
+func (s *PaymentServer) handleTenants(w http.ResponseWriter, r *http.Request) {
+	if !requireTenantAdmin(w, r) {
+		return
+	}
+	switch r.Method {
+	case http.MethodPost:
+		var req types.CreateTenantRequest
+		if err := decodeOptionalJSON(r, &req); err != nil {
+			writeDecodeError(w, r, err)
+			return
+		}
+		log.Printf("REST CreateTenant called name=%s", req.Name)
+		tenant, err := s.createTenant(req)
+		if err != nil {
+			writeDataError(w, r, err, nil)
+			return
+		}
+		writeJSON(w, http.StatusCreated, tenant)
+	case http.MethodGet:
+		if !requireTenantAdminKey(w, r) {
+			return
+		}
+		log.Printf("REST ListTenants called")
+		writeJSON(w, http.StatusOK, types.Tenants{Data: s.tenants.list()})
+	default:
+		writeMethodNotAllowed(w, r)
+	}
+}
+
+// handleTenantByID serves /admin/tenants/{id} and /admin/tenants/{id}/reset.
+func (s *PaymentServer) handleTenantByID(w http.ResponseWriter, r *http.Request) {
+	if !requireTenantAdmin(w, r) {
+		return
+	}
+	id, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/admin/tenants/"), "/")
+	t := s.tenants.get(id)
+	if t == nil {
+		writeAPIError(w, r, http.StatusNotFound, types.APIError{Message: "tenant not found: " + id, Code: data.CodeResourceMissing})
+		return
+	}
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		if !requireTenantAdminKey(w, r) {
+			return
+		}
+		log.Printf("REST RetrieveTenant called id=%s", id)
+		writeJSON(w, http.StatusOK, t.snapshot())
+	case action == "reset" && r.Method == http.MethodPost:
+		log.Printf("REST ResetTenant called id=%s", id)
+		if err := t.reset(); err != nil {
+			writeDataError(w, r, err, nil)
+			return
+		}
+		writeJSON(w, http.StatusOK, data.TakeSnapshot(t.store))
+	case action == "" || action == "reset":
+		writeMethodNotAllowed(w, r)
+	default:
+		writeError(w, r, http.StatusNotFound, "not found")
+	}
+}
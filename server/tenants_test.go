@@ -0,0 +1,66 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/nerdgarten/mock-payment-service/data"
+	"github.com/nerdgarten/mock-payment-service/types"
+)
+
+const testAdminKey = "sk_test_admin0000000000000000"
+
+func TestCreateTenantChecksKeysBeforeCreatingStore(t *testing.T) {
+	s, ts := newTestServer(t)
+	if err := s.RequireAPIKeys(testAdminKey); err != nil {
+		t.Fatalf("RequireAPIKeys: %v", err)
+	}
+	var stores int
+	s.NewTenantStore = func() data.Store {
+		stores++
+		return data.NewEmptyMemoryStore()
+	}
+
+	tests := map[string][]string{
+		"key in use":       {testAdminKey},
+		"key repeated":     {"sk_test_team0000000000000000", "sk_test_team0000000000000000"},
+		"second key taken": {"sk_test_team0000000000000000", testAdminKey},
+	}
+	for name, keys := range tests {
+		var envelope types.ErrorEnvelope
+		status := call(t, ts, http.MethodPost, "/admin/tenants", testAdminKey, types.CreateTenantRequest{Name: "team", APIKeys: keys}, &envelope)
+		if status != http.StatusConflict {
+			t.Errorf("%s: status = %d, want 409", name, status)
+		}
+	}
+	if stores != 0 {
+		t.Errorf("NewTenantStore called %d times for rejected tenants", stores)
+	}
+
+	var tenants types.Tenants
+	if status := call(t, ts, http.MethodGet, "/admin/tenants", testAdminKey, nil, &tenants); status != http.StatusOK {
+		t.Fatalf("list tenants: status = %d, want 200", status)
+	}
+	if len(tenants.Data) != 1 || len(tenants.Data[0].APIKeys) != 1 {
+		t.Errorf("tenants = %+v, want only the default tenant with one key", tenants.Data)
+	}
+}
+
+func TestReadingTenantsRequiresAdminKey(t *testing.T) {
+	_, ts := newTestServer(t)
+	var created types.Tenant
+	if status := call(t, ts, http.MethodPost, "/admin/tenants", "", types.CreateTenantRequest{Name: "team"}, &created); status != http.StatusCreated {
+		t.Fatalf("create tenant: status = %d, want 201", status)
+	}
+	for _, path := range []string{"/admin/tenants", "/admin/tenants/" + created.ID} {
+		var envelope types.ErrorEnvelope
+		status := call(t, ts, http.MethodGet, path, "", nil, &envelope)
+		if status != http.StatusForbidden || envelope.Error.Code != codeTenantAdminRequired {
+			t.Errorf("anonymous GET %s: status %d code %q, want 403 %s", path, status, envelope.Error.Code, codeTenantAdminRequired)
+		}
+		status = call(t, ts, http.MethodGet, path, created.APIKeys[0], nil, &envelope)
+		if status != http.StatusForbidden {
+			t.Errorf("tenant key GET %s: status %d, want 403", path, status)
+		}
+	}
+}
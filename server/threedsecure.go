@@ -0,0 +1,147 @@
+package server
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/nerdgarten/mock-payment-service/data"
+	"github.com/nerdgarten/mock-payment-service/types"
+)
+
+// challengePage renders the 3-D Secure challenge of an intent, or its outcome
+// when Result is set.
+var challengePage = template.Must(template.New("3ds").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>3D Secure Test Page</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 28rem; margin: 4rem auto; padding: 0 1rem; color: #1a1a1a; }
+.amount { font-size: 1.75rem; font-weight: 600; }
+.muted { color: #6b6b6b; }
+form { display: flex; gap: 0.75rem; margin-top: 2rem; }
+button { flex: 1; padding: 0.75rem; font-size: 1rem; border: 0; border-radius: 0.375rem; cursor: pointer; }
+.complete { background: #2f6fed; color: #fff; }
+.fail { background: #e8e8e8; color: #1a1a1a; }
+</style>
+</head>
+<body>
+<h1>3D Secure Test Page</h1>
+{{if .Result}}
+<p>{{.Result}}</p>
+<p class="muted">Payment intent {{.Intent.ID}} is now <code>{{.Intent.Status}}</code>. You can close this window.</p>
+{{else}}
+<p class="muted">This is a simulated authentication challenge of the mock payment service.</p>
+<p class="amount">{{.Amount}}</p>
+{{with .Intent.Description}}<p>{{.}}</p>{{end}}
+<p class="muted">Payment method <code>{{.Intent.PaymentMethod}}</code></p>
+<form method="post">
+<button class="complete" type="submit" name="result" value="complete">Complete authentication</button>
+<button class="fail" type="submit" name="result" value="fail">Fail authentication</button>
+</form>
+{{end}}
+</body>
+</html>
+`))
+
+type challengePageData struct {
+	Intent types.PaymentIntent
+	Amount string
+	Result string
+}
+
+// challengeURL returns the URL of the challenge pages of the request's tenant,
+// on the host the request was sent to. Confirmation appends the client secret.
+func challengeURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	return scheme + "://" + r.Host + "/3ds/" + url.PathEscape(requestTenant(r).info.ID) + "/"
+}
+
+// validReturnURL reports whether u is an absolute http or https URL.
+func validReturnURL(u string) bool {
+	parsed, err := url.Parse(u)
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
+
+// handleThreeDSecure serves the challenge page at /3ds/{tenant}/{client_secret}
+// and submits it. After submission the customer is redirected to the intent's
+// return URL with payment_intent, payment_intent_client_secret and
+// redirect_status added to its query.
+func (s *PaymentServer) handleThreeDSecure(w http.ResponseWriter, r *http.Request) {
+	tenantID, clientSecret, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/3ds/"), "/")
+	owner := s.tenants.get(tenantID)
+	if owner == nil || clientSecret == "" {
+		http.Error(w, "3D Secure challenge not found", http.StatusNotFound)
+		return
+	}
+	intent := data.GetMockPaymentIntentBySecret(owner.store, clientSecret)
+	if intent == nil {
+		http.Error(w, "3D Secure challenge not found", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if intent.Status != types.PaymentIntentStatusRequiresAction {
+			http.Error(w, "This 3D Secure challenge is no longer pending; the payment intent is "+string(intent.Status)+".", http.StatusConflict)
+			return
+		}
+		log.Printf("REST ThreeDSecureChallenge called id=%s", intent.ID)
+		renderChallengePage(w, challengePageData{
+			Intent: *intent,
+			Amount: types.Money{Amount: intent.Amount, Currency: intent.Currency}.String(),
+		})
+	case http.MethodPost:
+		result := r.FormValue("result")
+		if result != "complete" && result != "fail" {
+			http.Error(w, "result must be complete or fail", http.StatusBadRequest)
+			return
+		}
+		log.Printf("REST ThreeDSecureSubmit called id=%s result=%s", intent.ID, result)
+		updated, returnURL, err := data.AuthenticateMockPaymentIntent(owner.store, clientSecret, result == "complete")
+		if err != nil {
+			status, apiErr := dataAPIError(err)
+			http.Error(w, apiErr.Message, status)
+			return
+		}
+		redirectStatus := "succeeded"
+		outcome := "Authentication completed."
+		if updated.Status == types.PaymentIntentStatusRequiresPaymentMethod {
+			redirectStatus = "failed"
+			outcome = "Authentication failed."
+			if result == "complete" {
+				outcome = "Authentication completed, but the payment was declined."
+			}
+		}
+		if returnURL == "" {
+			renderChallengePage(w, challengePageData{Intent: *updated, Result: outcome})
+			return
+		}
+		target, _ := url.Parse(returnURL)
+		query := target.Query()
+		query.Set("payment_intent", updated.ID)
+		query.Set("payment_intent_client_secret", updated.ClientSecret)
+		query.Set("redirect_status", redirectStatus)
+		target.RawQuery = query.Encode()
+		http.Redirect(w, r, target.String(), http.StatusSeeOther)
+	default:
+		writeMethodNotAllowed(w, r)
+	}
+}
+
+func renderChallengePage(w http.ResponseWriter, page challengePageData) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := challengePage.Execute(w, page); err != nil {
+		log.Printf("failed to render 3D Secure page: %v", err)
+	}
+}
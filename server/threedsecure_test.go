@@ -0,0 +1,105 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/nerdgarten/mock-payment-service/types"
+)
+
+// confirmThreeDSecure creates and confirms an intent paid with a 3-D Secure
+// test card and returns it in requires_action.
+func confirmThreeDSecure(t *testing.T, ts *httptest.Server) types.PaymentIntent {
+	t.Helper()
+	var created types.CreatePaymentIntentResponse
+	req := types.CreatePaymentIntentRequest{Amount: 1200, Currency: "thb", PaymentMethod: "4000002760003184"}
+	if status := call(t, ts, http.MethodPost, "/payment-intents", "", req, &created); status != http.StatusCreated {
+		t.Fatalf("create intent: status = %d, want 201", status)
+	}
+	var confirmed types.ConfirmPaymentIntentResponse
+	confirm := types.ConfirmPaymentIntentRequest{ID: created.PaymentIntent.ID, ReturnURL: "https://shop.example/return?order=1"}
+	if status := call(t, ts, http.MethodPost, "/payment-intents/confirm", "", confirm, &confirmed); status != http.StatusOK {
+		t.Fatalf("confirm intent: status = %d, want 200", status)
+	}
+	intent := confirmed.PaymentIntent
+	if intent.Status != types.PaymentIntentStatusRequiresAction || intent.NextAction == nil || intent.NextAction.RedirectToURL == nil {
+		t.Fatalf("confirmed intent = %+v, want requires_action with a redirect", intent)
+	}
+	if challenge := intent.NextAction.RedirectToURL.URL; !strings.HasPrefix(challenge, ts.URL+"/3ds/") {
+		t.Fatalf("challenge URL = %s, want one on %s", challenge, ts.URL)
+	}
+	return intent
+}
+
+func TestThreeDSecureChallengePage(t *testing.T) {
+	_, ts := newTestServer(t)
+	intent := confirmThreeDSecure(t, ts)
+	challenge := intent.NextAction.RedirectToURL.URL
+
+	resp, err := ts.Client().Get(challenge)
+	if err != nil {
+		t.Fatalf("GET challenge: %v", err)
+	}
+	page, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(page), intent.PaymentMethod) {
+		t.Fatalf("GET challenge: status %d, page %s", resp.StatusCode, page)
+	}
+
+	noRedirect := *ts.Client()
+	noRedirect.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	resp, err = noRedirect.PostForm(challenge, url.Values{"result": {"bogus"}})
+	if err != nil {
+		t.Fatalf("POST challenge: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("POST bogus result: status = %d, want 400", resp.StatusCode)
+	}
+
+	resp, err = noRedirect.PostForm(challenge, url.Values{"result": {"complete"}})
+	if err != nil {
+		t.Fatalf("POST challenge: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("POST complete: status = %d, want 303", resp.StatusCode)
+	}
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("parse redirect: %v", err)
+	}
+	query := location.Query()
+	if location.Host != "shop.example" || query.Get("order") != "1" || query.Get("payment_intent") != intent.ID ||
+		query.Get("payment_intent_client_secret") != intent.ClientSecret || query.Get("redirect_status") != "succeeded" {
+		t.Errorf("redirect = %s", location)
+	}
+
+	var retrieved types.RetrievePaymentIntentResponse
+	call(t, ts, http.MethodGet, "/payment-intents/"+intent.ID, "", nil, &retrieved)
+	if retrieved.PaymentIntent.Status != types.PaymentIntentStatusSucceeded {
+		t.Errorf("intent status = %s, want succeeded", retrieved.PaymentIntent.Status)
+	}
+
+	// The challenge can only be submitted once.
+	resp, err = ts.Client().Get(challenge)
+	if err != nil {
+		t.Fatalf("GET challenge: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("GET submitted challenge: status = %d, want 409", resp.StatusCode)
+	}
+	resp, err = ts.Client().Get(ts.URL + "/3ds/tenant_default/pi_missing_secret_x")
+	if err != nil {
+		t.Fatalf("GET challenge: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GET unknown challenge: status = %d, want 404", resp.StatusCode)
+	}
+}
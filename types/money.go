@@ -0,0 +1,132 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency is the currency of wallet accounts and of objects created
+// without an explicit currency.
+const DefaultCurrency = "thb"
+
+// currencyExponents maps lowercase ISO 4217 codes to the number of decimal
+// places of their minor unit.
+var currencyExponents = map[string]int{
+	"aud": 2,
+	"bhd": 3,
+	"cad": 2,
+	"chf": 2,
+	"cny": 2,
+	"eur": 2,
+	"gbp": 2,
+	"hkd": 2,
+	"idr": 2,
+	"inr": 2,
+	"jod": 3,
+	"jpy": 0,
+	"krw": 0,
+	"kwd": 3,
+	"lak": 2,
+	"mmk": 2,
+	"myr": 2,
+	"omr": 3,
+	"php": 2,
+	"sgd": 2,
+	"thb": 2,
+	"tnd": 3,
+	"twd": 2,
+	"usd": 2,
+	"vnd": 0,
+}
+
+// CurrencyExponent returns the number of decimal places of a currency's minor
+// unit and whether the currency is supported. Codes are case-insensitive.
+func CurrencyExponent(currency string) (int, bool) {
+	exponent, ok := currencyExponents[strings.ToLower(currency)]
+	return exponent, ok
+}
+
+// Amount is a monetary amount in the minor unit of its currency, e.g. satang
+// for THB or yen for JPY. In JSON it is an integer; fractional values are
+// rejected with an *AmountError.
+type Amount int64
+
+// AmountError reports a JSON amount that is not a whole number of minor units.
+type AmountError struct {
+	Value string
+}
+
+func (e *AmountError) Error() string {
+	return fmt.Sprintf("amount %s must be a whole number of the currency's minor unit", e.Value)
+}
+
+// UnmarshalJSON decodes an integer number of minor units.
+func (a *Amount) UnmarshalJSON(b []byte) error {
+	raw := string(b)
+	if raw == "null" {
+		return nil
+	}
+	if n, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		*a = Amount(n)
+		return nil
+	}
+	var number json.Number
+	if err := json.Unmarshal(b, &number); err != nil {
+		return &AmountError{Value: raw}
+	}
+	f, err := number.Float64()
+	if err != nil || f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+		return &AmountError{Value: raw}
+	}
+	*a = Amount(f)
+	return nil
+}
+
+// FromMajor converts a whole amount in major units, such as 5000 THB, to minor
+// units. Unknown currencies are treated as having two decimal places.
+func FromMajor(major int64, currency string) Amount {
+	exponent, ok := CurrencyExponent(currency)
+	if !ok {
+		exponent = 2
+	}
+	return Amount(major * int64(math.Pow10(exponent)))
+}
+
+// Money pairs an amount in minor units with its currency.
+type Money struct {
+	Amount   Amount `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// Validate reports an error when the currency is unknown.
+func (m Money) Validate() error {
+	if m.Currency == "" {
+		return fmt.Errorf("currency is required")
+	}
+	if _, ok := CurrencyExponent(m.Currency); !ok {
+		return fmt.Errorf("unsupported currency %q", m.Currency)
+	}
+	return nil
+}
+
+// String formats the amount in major units, e.g. "12.99 THB" or "1200 JPY".
+func (m Money) String() string {
+	exponent, ok := CurrencyExponent(m.Currency)
+	if !ok {
+		return fmt.Sprintf("%d %s", m.Amount, strings.ToUpper(m.Currency))
+	}
+	sign := ""
+	amount := int64(m.Amount)
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	if exponent == 0 {
+		return fmt.Sprintf("%s%d %s", sign, amount, strings.ToUpper(m.Currency))
+	}
+	unit := int64(math.Pow10(exponent))
+	return fmt.Sprintf("%s%d.%0*d %s", sign, amount/unit, exponent, amount%unit, strings.ToUpper(m.Currency))
+}
@@ -0,0 +1,122 @@
+package types
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestAmountUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    Amount
+		wantErr bool
+	}{
+		{raw: `1299`, want: 1299},
+		{raw: `-50`, want: -50},
+		{raw: `0`, want: 0},
+		{raw: `1299.0`, want: 1299},
+		{raw: `1.2e3`, want: 1200},
+		{raw: `null`, want: 0},
+		{raw: `12.99`, wantErr: true},
+		{raw: `0.5`, wantErr: true},
+		{raw: `1e30`, wantErr: true},
+		{raw: `"1299"`, want: 1299},
+		{raw: `"12.99"`, wantErr: true},
+		{raw: `true`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			var got Amount
+			err := json.Unmarshal([]byte(tt.raw), &got)
+			if tt.wantErr {
+				var amountErr *AmountError
+				if !errors.As(err, &amountErr) {
+					t.Fatalf("Unmarshal(%s) error = %v, want *AmountError", tt.raw, err)
+				}
+				if amountErr.Value != tt.raw {
+					t.Errorf("AmountError.Value = %q, want %q", amountErr.Value, tt.raw)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unmarshal(%s): %v", tt.raw, err)
+			}
+			if got != tt.want {
+				t.Errorf("Unmarshal(%s) = %d, want %d", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCurrencyExponent(t *testing.T) {
+	tests := []struct {
+		currency string
+		want     int
+		ok       bool
+	}{
+		{"thb", 2, true},
+		{"THB", 2, true},
+		{"jpy", 0, true},
+		{"krw", 0, true},
+		{"vnd", 0, true},
+		{"kwd", 3, true},
+		{"bhd", 3, true},
+		{"xyz", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := CurrencyExponent(tt.currency)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("CurrencyExponent(%q) = %d, %t, want %d, %t", tt.currency, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestFromMajor(t *testing.T) {
+	tests := []struct {
+		major    int64
+		currency string
+		want     Amount
+	}{
+		{5000, "thb", 500000},
+		{1200, "jpy", 1200},
+		{3, "kwd", 3000},
+		{7, "xyz", 700},
+	}
+	for _, tt := range tests {
+		if got := FromMajor(tt.major, tt.currency); got != tt.want {
+			t.Errorf("FromMajor(%d, %q) = %d, want %d", tt.major, tt.currency, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyValidate(t *testing.T) {
+	if err := (Money{Amount: 100, Currency: "USD"}).Validate(); err != nil {
+		t.Errorf("Validate(USD) = %v, want nil", err)
+	}
+	for _, currency := range []string{"", "xyz"} {
+		if err := (Money{Amount: 100, Currency: currency}).Validate(); err == nil {
+			t.Errorf("Validate(%q) = nil, want error", currency)
+		}
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	tests := []struct {
+		money Money
+		want  string
+	}{
+		{Money{1299, "thb"}, "12.99 THB"},
+		{Money{5, "thb"}, "0.05 THB"},
+		{Money{-1299, "usd"}, "-12.99 USD"},
+		{Money{1200, "jpy"}, "1200 JPY"},
+		{Money{1500, "kwd"}, "1.500 KWD"},
+		{Money{1299, "xyz"}, "1299 XYZ"},
+	}
+	for _, tt := range tests {
+		if got := tt.money.String(); got != tt.want {
+			t.Errorf("%#v.String() = %q, want %q", tt.money, got, tt.want)
+		}
+	}
+}
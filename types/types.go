@@ -1,20 +1,40 @@
 package types
 
-// Customer represents a mock customer record.
+import "encoding/json"
+
+// Customer represents a mock customer record. Deleted customers are kept,
+// marked Deleted, so that objects referring to them stay valid.
+// DefaultPaymentMethod is one of the payment methods attached to the customer.
 type Customer struct {
-	ID      string `json:"id"`
-	Object  string `json:"object"`
-	Name    string `json:"name"`
-	Email   string `json:"email"`
-	Created int64  `json:"created"`
+	ID                   string `json:"id"`
+	Object               string `json:"object"`
+	Name                 string `json:"name"`
+	Email                string `json:"email"`
+	DefaultPaymentMethod string `json:"default_payment_method,omitempty"`
+	Created              int64  `json:"created"`
+	Deleted              bool   `json:"deleted,omitempty"`
 }
 
-// CreateCustomerRequest is the payload used to create a customer.
+// CreateCustomerRequest is the payload used to create a customer. Email is
+// optional but must be a valid address not used by another customer.
 type CreateCustomerRequest struct {
 	Name  string `json:"name"`
 	Email string `json:"email"`
 }
 
+// UpdateCustomerRequest changes the fields that are set and keeps the rest.
+// DefaultPaymentMethod must be attached to the customer; "" unsets it.
+type UpdateCustomerRequest struct {
+	Name                 *string `json:"name,omitempty"`
+	Email                *string `json:"email,omitempty"`
+	DefaultPaymentMethod *string `json:"default_payment_method,omitempty"`
+}
+
+// UpdateCustomerResponse wraps the updated customer.
+type UpdateCustomerResponse struct {
+	Customer Customer `json:"customer"`
+}
+
 // CreateCustomerResponse wraps the created customer.
 type CreateCustomerResponse struct {
 	Customer Customer `json:"customer"`
@@ -25,24 +45,200 @@ type RetrieveCustomerResponse struct {
 	Customer Customer `json:"customer"`
 }
 
+// PaymentMethodType is the kind of a PaymentMethod. Each type has a field of
+// the same name on PaymentMethod holding its details.
+type PaymentMethodType string
+
+const (
+	PaymentMethodTypeCard          PaymentMethodType = "card"
+	PaymentMethodTypeMobileBanking PaymentMethodType = "mobilebanking"
+	PaymentMethodTypeMeowthWallet  PaymentMethodType = "meowth_wallet"
+	PaymentMethodTypeCashVoucher   PaymentMethodType = "cash_voucher"
+)
+
+// PaymentMethodTypes lists every supported payment method type.
+var PaymentMethodTypes = []PaymentMethodType{
+	PaymentMethodTypeCard,
+	PaymentMethodTypeMobileBanking,
+	PaymentMethodTypeMeowthWallet,
+	PaymentMethodTypeCashVoucher,
+}
+
+// Card holds the non-sensitive details of a card. The full number and CVC are
+// never stored. Fingerprint is the same for every payment method created from
+// the same card number.
+type Card struct {
+	Brand       string `json:"brand"`
+	Last4       string `json:"last4"`
+	ExpMonth    int    `json:"exp_month"`
+	ExpYear     int    `json:"exp_year"`
+	Fingerprint string `json:"fingerprint"`
+}
+
+// MobileBanking is a Thai bank app that approves payments, e.g. "kbank".
+type MobileBanking struct {
+	Bank string `json:"bank"`
+}
+
+// MeowthWallet is a Meowth Wallet identified by its phone number.
+type MeowthWallet struct {
+	Phone string `json:"phone,omitempty"`
+}
+
+// CashVoucher is a voucher paid in cash at a convenience store before
+// ExpiresAt.
+type CashVoucher struct {
+	Number    string `json:"number"`
+	ExpiresAt int64  `json:"expires_at"`
+}
+
+// PaymentMethod is a saved way to pay. Exactly the detail field matching Type
+// is set. Customer is set while the payment method is attached to a customer.
+type PaymentMethod struct {
+	ID            string            `json:"id"`
+	Object        string            `json:"object"`
+	Type          PaymentMethodType `json:"type"`
+	Card          *Card             `json:"card,omitempty"`
+	MobileBanking *MobileBanking    `json:"mobilebanking,omitempty"`
+	MeowthWallet  *MeowthWallet     `json:"meowth_wallet,omitempty"`
+	CashVoucher   *CashVoucher      `json:"cash_voucher,omitempty"`
+	Customer      string            `json:"customer,omitempty"`
+	Created       int64             `json:"created"`
+}
+
+// CardParams are the raw card details used to create a card payment method.
+type CardParams struct {
+	Number   string `json:"number"`
+	ExpMonth int    `json:"exp_month"`
+	ExpYear  int    `json:"exp_year"`
+	CVC      string `json:"cvc,omitempty"`
+}
+
+// CashVoucherParams configure a new cash voucher. ExpiresAfterDays defaults
+// to 3.
+type CashVoucherParams struct {
+	ExpiresAfterDays int `json:"expires_after_days,omitempty"`
+}
+
+// CreatePaymentMethodRequest creates a payment method of Type with the details
+// in the field of the same name. Customer optionally attaches it right away.
+type CreatePaymentMethodRequest struct {
+	Type          PaymentMethodType  `json:"type"`
+	Card          *CardParams        `json:"card,omitempty"`
+	MobileBanking *MobileBanking     `json:"mobilebanking,omitempty"`
+	MeowthWallet  *MeowthWallet      `json:"meowth_wallet,omitempty"`
+	CashVoucher   *CashVoucherParams `json:"cash_voucher,omitempty"`
+	Customer      string             `json:"customer,omitempty"`
+}
+
+// CreatePaymentMethodResponse wraps the created payment method.
+type CreatePaymentMethodResponse struct {
+	PaymentMethod PaymentMethod `json:"payment_method"`
+}
+
+// RetrievePaymentMethodResponse wraps a retrieved payment method.
+type RetrievePaymentMethodResponse struct {
+	PaymentMethod PaymentMethod `json:"payment_method"`
+}
+
+// AttachPaymentMethodRequest names the customer to attach a payment method to.
+type AttachPaymentMethodRequest struct {
+	Customer string `json:"customer"`
+}
+
+// AttachPaymentMethodResponse wraps the attached payment method.
+type AttachPaymentMethodResponse struct {
+	PaymentMethod PaymentMethod `json:"payment_method"`
+}
+
+// DetachPaymentMethodResponse wraps the detached payment method.
+type DetachPaymentMethodResponse struct {
+	PaymentMethod PaymentMethod `json:"payment_method"`
+}
+
+// PaymentIntentStatus is a state in the PaymentIntent lifecycle.
+type PaymentIntentStatus string
+
+const (
+	PaymentIntentStatusRequiresPaymentMethod PaymentIntentStatus = "requires_payment_method"
+	PaymentIntentStatusRequiresConfirmation  PaymentIntentStatus = "requires_confirmation"
+	PaymentIntentStatusRequiresAction        PaymentIntentStatus = "requires_action"
+	PaymentIntentStatusProcessing            PaymentIntentStatus = "processing"
+	PaymentIntentStatusRequiresCapture       PaymentIntentStatus = "requires_capture"
+	PaymentIntentStatusSucceeded             PaymentIntentStatus = "succeeded"
+	PaymentIntentStatusCanceled              PaymentIntentStatus = "canceled"
+)
+
+// PaymentIntentStatuses lists every PaymentIntent status in lifecycle order.
+var PaymentIntentStatuses = []PaymentIntentStatus{
+	PaymentIntentStatusRequiresPaymentMethod,
+	PaymentIntentStatusRequiresConfirmation,
+	PaymentIntentStatusRequiresAction,
+	PaymentIntentStatusProcessing,
+	PaymentIntentStatusRequiresCapture,
+	PaymentIntentStatusSucceeded,
+	PaymentIntentStatusCanceled,
+}
+
+// CaptureMethod controls when the funds of a confirmed PaymentIntent are
+// collected.
+type CaptureMethod string
+
+const (
+	// CaptureMethodAutomatic captures the funds as soon as the intent is
+	// confirmed.
+	CaptureMethodAutomatic CaptureMethod = "automatic"
+	// CaptureMethodManual only authorizes the funds on confirmation; the
+	// intent waits in requires_capture until it is captured or canceled.
+	CaptureMethodManual CaptureMethod = "manual"
+)
+
 // PaymentIntent models an intent to collect a payment.
 type PaymentIntent struct {
-	ID            string `json:"id"`
-	Object        string `json:"object"`
-	Amount        int64  `json:"amount"`
-	Currency      string `json:"currency"`
-	Status        string `json:"status"`
-	ClientSecret  string `json:"client_secret"`
-	Description   string `json:"description"`
-	PaymentMethod string `json:"payment_method"`
+	ID                 string              `json:"id"`
+	Object             string              `json:"object"`
+	Amount             Amount              `json:"amount"`
+	Currency           string              `json:"currency"`
+	Status             PaymentIntentStatus `json:"status"`
+	CaptureMethod      CaptureMethod       `json:"capture_method"`
+	AmountCapturable   Amount              `json:"amount_capturable"`
+	ClientSecret       string              `json:"client_secret"`
+	Description        string              `json:"description"`
+	PaymentMethod      string              `json:"payment_method"`
+	AmountRefunded     Amount              `json:"amount_refunded"`
+	LatestCharge       string              `json:"latest_charge,omitempty"`
+	LastPaymentError   *PaymentError       `json:"last_payment_error,omitempty"`
+	NextAction         *NextAction         `json:"next_action,omitempty"`
+	CanceledAt         int64               `json:"canceled_at,omitempty"`
+	CancellationReason string              `json:"cancellation_reason,omitempty"`
+	Customer           string              `json:"customer,omitempty"`
+	Created            int64               `json:"created"`
+}
+
+// NextAction is what the customer must do to continue paying an intent in
+// requires_action. Type is "redirect_to_url".
+type NextAction struct {
+	Type          string         `json:"type"`
+	RedirectToURL *RedirectToURL `json:"redirect_to_url,omitempty"`
 }
 
-// CreatePaymentIntentRequest defines the required parameters to create an intent.
+// RedirectToURL sends the customer to URL, a 3-D Secure challenge page that
+// redirects to ReturnURL when it is completed.
+type RedirectToURL struct {
+	URL       string `json:"url"`
+	ReturnURL string `json:"return_url,omitempty"`
+}
+
+// CreatePaymentIntentRequest defines the required parameters to create an
+// intent. Customer optionally links the intent to an existing customer.
 type CreatePaymentIntentRequest struct {
-	Amount        float64 `json:"amount"`
-	Currency      string  `json:"currency"`
-	PaymentMethod string  `json:"payment_method"`
-	Description   string  `json:"description"`
+	Amount        Amount `json:"amount"`
+	Currency      string `json:"currency"`
+	PaymentMethod string `json:"payment_method"`
+	Description   string `json:"description"`
+	Customer      string `json:"customer,omitempty"`
+	// CaptureMethod defaults to automatic.
+	CaptureMethod CaptureMethod `json:"capture_method,omitempty"`
 }
 
 // CreatePaymentIntentResponse wraps the created payment intent.
@@ -50,13 +246,43 @@ type CreatePaymentIntentResponse struct {
 	PaymentIntent PaymentIntent `json:"payment_intent"`
 }
 
-// Charge represents a processed charge linked to a payment intent.
+// RetrievePaymentIntentResponse wraps a retrieved payment intent.
+type RetrievePaymentIntentResponse struct {
+	PaymentIntent PaymentIntent `json:"payment_intent"`
+}
+
+// PaymentError describes why a payment attempt failed.
+type PaymentError struct {
+	Type          string `json:"type"`
+	Code          string `json:"code"`
+	DeclineCode   string `json:"decline_code,omitempty"`
+	Message       string `json:"message"`
+	PaymentMethod string `json:"payment_method,omitempty"`
+}
+
+// Charge represents a processed charge linked to a payment intent. A
+// succeeded charge that is not Captured is an authorization holding Amount
+// until it is captured, or released after CaptureBefore. AmountReleased is the
+// part of the authorization that was never captured.
 type Charge struct {
-	ID            string `json:"id"`
-	Status        string `json:"status"`
-	Amount        int64  `json:"amount"`
-	Currency      string `json:"currency"`
-	PaymentMethod string `json:"payment_method"`
+	ID             string `json:"id"`
+	Object         string `json:"object"`
+	Status         string `json:"status"`
+	Amount         Amount `json:"amount"`
+	Currency       string `json:"currency"`
+	PaymentMethod  string `json:"payment_method"`
+	PaymentIntent  string `json:"payment_intent,omitempty"`
+	Captured       bool   `json:"captured"`
+	AmountCaptured Amount `json:"amount_captured"`
+	AmountReleased Amount `json:"amount_released,omitempty"`
+	CaptureBefore  int64  `json:"capture_before,omitempty"`
+	AmountRefunded Amount `json:"amount_refunded"`
+	Refunded       bool   `json:"refunded"`
+	FailureCode    string `json:"failure_code,omitempty"`
+	FailureMessage string `json:"failure_message,omitempty"`
+	DeclineCode    string `json:"decline_code,omitempty"`
+	Customer       string `json:"customer,omitempty"`
+	Created        int64  `json:"created"`
 }
 
 // Charges is a collection wrapper used for responses.
@@ -64,9 +290,20 @@ type Charges struct {
 	Data []Charge `json:"data"`
 }
 
-// ConfirmPaymentIntentRequest identifies which intent to confirm.
+// RetrieveChargeResponse wraps a retrieved charge.
+type RetrieveChargeResponse struct {
+	Charge Charge `json:"charge"`
+}
+
+// ConfirmPaymentIntentRequest identifies which intent to confirm. PaymentMethod
+// optionally attaches a payment method before confirming. ClientSecret is
+// required with publishable keys; the intent ID may then be omitted.
+// ReturnURL is where the customer is sent after a 3-D Secure challenge.
 type ConfirmPaymentIntentRequest struct {
-	ID string `json:"id"`
+	ID            string `json:"id"`
+	PaymentMethod string `json:"payment_method,omitempty"`
+	ClientSecret  string `json:"client_secret,omitempty"`
+	ReturnURL     string `json:"return_url,omitempty"`
 }
 
 // ConfirmPaymentIntentResponse returns the updated intent and associated charges.
@@ -75,20 +312,47 @@ type ConfirmPaymentIntentResponse struct {
 	Charges       Charges       `json:"charges"`
 }
 
+// CancelPaymentIntentRequest optionally records why an intent was canceled.
+type CancelPaymentIntentRequest struct {
+	CancellationReason string `json:"cancellation_reason"`
+}
+
+// CancelPaymentIntentResponse wraps the canceled payment intent.
+type CancelPaymentIntentResponse struct {
+	PaymentIntent PaymentIntent `json:"payment_intent"`
+}
+
+// CapturePaymentIntentRequest optionally captures less than the authorized
+// amount; the rest is released. A nil AmountToCapture captures everything.
+type CapturePaymentIntentRequest struct {
+	AmountToCapture *Amount `json:"amount_to_capture,omitempty"`
+}
+
+// CapturePaymentIntentResponse returns the captured intent and associated charges.
+type CapturePaymentIntentResponse struct {
+	PaymentIntent PaymentIntent `json:"payment_intent"`
+	Charges       Charges       `json:"charges"`
+}
+
 // Refund represents a returned payment.
 type Refund struct {
 	ID            string `json:"id"`
 	Object        string `json:"object"`
-	Amount        int64  `json:"amount"`
+	Amount        Amount `json:"amount"`
 	Currency      string `json:"currency"`
 	Status        string `json:"status"`
 	PaymentIntent string `json:"payment_intent"`
+	Charge        string `json:"charge,omitempty"`
+	Reason        string `json:"reason,omitempty"`
+	Created       int64  `json:"created,omitempty"`
 }
 
-// CreateRefundRequest specifies the refund payload.
+// CreateRefundRequest specifies the refund payload. Omitting Amount refunds
+// everything not refunded yet.
 type CreateRefundRequest struct {
 	PaymentIntent string  `json:"payment_intent"`
-	Amount        float64 `json:"amount"`
+	Amount        *Amount `json:"amount,omitempty"`
+	Reason        string  `json:"reason,omitempty"`
 }
 
 // CreateRefundResponse wraps the mock refund result.
@@ -96,7 +360,13 @@ type CreateRefundResponse struct {
 	Refund Refund `json:"refund"`
 }
 
-// TestWebhookRequest imitates a webhook trigger payload.
+// RetrieveRefundResponse wraps a retrieved refund.
+type RetrieveRefundResponse struct {
+	Refund Refund `json:"refund"`
+}
+
+// TestWebhookRequest imitates a webhook trigger payload. Data is sent as the
+// event's data.object; it is embedded verbatim when it is valid JSON.
 type TestWebhookRequest struct {
 	Type string `json:"type"`
 	Data string `json:"data"`
@@ -104,12 +374,134 @@ type TestWebhookRequest struct {
 
 // TestWebhookResponse acknowledges webhook delivery.
 type TestWebhookResponse struct {
-	Received bool `json:"received"`
+	Received bool   `json:"received"`
+	EventID  string `json:"event_id,omitempty"`
+}
+
+// Event types emitted when mock objects change state.
+const (
+	EventCustomerCreated             = "customer.created"
+	EventCustomerUpdated             = "customer.updated"
+	EventCustomerDeleted             = "customer.deleted"
+	EventPaymentIntentCreated        = "payment_intent.created"
+	EventPaymentIntentSucceeded      = "payment_intent.succeeded"
+	EventPaymentIntentCanceled       = "payment_intent.canceled"
+	EventPaymentIntentRequiresAction = "payment_intent.requires_action"
+	EventPaymentIntentCapturable     = "payment_intent.amount_capturable_updated"
+	EventChargeSucceeded             = "charge.succeeded"
+	EventChargeCaptured              = "charge.captured"
+	EventChargeFailed                = "charge.failed"
+	EventChargeExpired               = "charge.expired"
+	EventPaymentIntentFailed         = "payment_intent.payment_failed"
+	EventRefundCreated               = "refund.created"
+	EventChargeRefunded              = "charge.refunded"
+	EventAccountDeposited            = "account.deposited"
+	EventAccountWithdrawn            = "account.withdrawn"
+	EventAccountRefunded             = "account.refunded"
+	EventPaymentProcessed            = "payment.processed"
+	EventAccountAdjusted             = "account.adjusted"
+	EventPaymentMethodAttached       = "payment_method.attached"
+	EventPaymentMethodDetached       = "payment_method.detached"
+	EventHoldCreated                 = "hold.created"
+	EventHoldCaptured                = "hold.captured"
+	EventHoldReleased                = "hold.released"
+	EventHoldExpired                 = "hold.expired"
+)
+
+// WebhookEndpointAllEvents subscribes a webhook endpoint to every event type.
+const WebhookEndpointAllEvents = "*"
+
+// Event describes a state change delivered to webhook endpoints.
+type Event struct {
+	ID      string    `json:"id"`
+	Object  string    `json:"object"`
+	Type    string    `json:"type"`
+	Created int64     `json:"created"`
+	Data    EventData `json:"data"`
+}
+
+// EventData carries the object affected by an event.
+type EventData struct {
+	Object json.RawMessage `json:"object"`
+}
+
+// WebhookEndpoint is a registered receiver for events. EnabledEvents lists the
+// event types to deliver; "*" or an empty list selects every event.
+type WebhookEndpoint struct {
+	ID            string   `json:"id"`
+	Object        string   `json:"object"`
+	URL           string   `json:"url"`
+	EnabledEvents []string `json:"enabled_events"`
+	Secret        string   `json:"secret"`
+	Created       int64    `json:"created"`
+}
+
+// CreateWebhookEndpointRequest registers a webhook endpoint. A signing secret
+// is generated when Secret is empty.
+type CreateWebhookEndpointRequest struct {
+	URL           string   `json:"url"`
+	EnabledEvents []string `json:"enabled_events"`
+	Secret        string   `json:"secret"`
+}
+
+// WebhookEndpointResponse wraps a single webhook endpoint.
+type WebhookEndpointResponse struct {
+	WebhookEndpoint WebhookEndpoint `json:"webhook_endpoint"`
+}
+
+// WebhookEndpoints is a collection wrapper used for responses.
+type WebhookEndpoints struct {
+	Data []WebhookEndpoint `json:"data"`
+}
+
+// DeletedObject acknowledges the deletion of an object.
+type DeletedObject struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Deleted bool   `json:"deleted"`
+}
+
+// ErrorType is the category of an API error.
+type ErrorType string
+
+const (
+	ErrorTypeAPI            ErrorType = "api_error"
+	ErrorTypeAuthentication ErrorType = "authentication_error"
+	ErrorTypeCard           ErrorType = "card_error"
+	ErrorTypeIdempotency    ErrorType = "idempotency_error"
+	ErrorTypeInvalidRequest ErrorType = "invalid_request_error"
+	ErrorTypePermission     ErrorType = "permission_error"
+	ErrorTypeRateLimit      ErrorType = "rate_limit_error"
+)
+
+// APIError describes a failed request. Code is a machine readable identifier,
+// Param names the offending request field and RequestID matches the
+// Request-Id response header. Declined payments also carry the decline code
+// and the affected intent or charge.
+type APIError struct {
+	Type          ErrorType      `json:"type"`
+	Code          string         `json:"code,omitempty"`
+	DeclineCode   string         `json:"decline_code,omitempty"`
+	Param         string         `json:"param,omitempty"`
+	Message       string         `json:"message"`
+	RequestID     string         `json:"request_id,omitempty"`
+	Charge        string         `json:"charge,omitempty"`
+	PaymentIntent *PaymentIntent `json:"payment_intent,omitempty"`
+}
+
+// ErrorEnvelope is the body of every error response.
+type ErrorEnvelope struct {
+	Error APIError `json:"error"`
 }
 
-// ErrorResponse standardises error messages returned by the API.
+// ErrorResponse is the legacy error body, a flat object whose error field is
+// the message. It is returned instead of ErrorEnvelope in legacy error mode.
 type ErrorResponse struct {
-	Error string `json:"error"`
+	Error         string         `json:"error"`
+	Code          string         `json:"code,omitempty"`
+	DeclineCode   string         `json:"decline_code,omitempty"`
+	Param         string         `json:"param,omitempty"`
+	PaymentIntent *PaymentIntent `json:"payment_intent,omitempty"`
 }
 
 // PaymentType represents different payment methods
@@ -122,16 +514,55 @@ const (
 	PaymentTypeMeowthWallet  PaymentType = "meowth-wallet"
 )
 
-// Account represents a payment account with balance
+// PaymentTypes lists every supported payment type in display order.
+var PaymentTypes = []PaymentType{
+	PaymentTypeCash,
+	PaymentTypeMobileBanking,
+	PaymentTypeCreditCard,
+	PaymentTypeMeowthWallet,
+}
+
+// Account represents a customer's payment account with balance. Balance is
+// the ledger balance; Held is the part of it reserved by active holds. In
+// JSON the account also reports its ledger and available balances.
 type Account struct {
-	Type    PaymentType `json:"type"`
-	Balance float64     `json:"balance"`
+	CustomerID string      `json:"customer_id"`
+	Type       PaymentType `json:"type"`
+	Currency   string      `json:"currency"`
+	Balance    Amount      `json:"balance"`
+	Held       Amount      `json:"held"`
+}
+
+// Available returns the balance that can still be spent or held.
+func (a Account) Available() Amount {
+	return a.Balance - a.Held
+}
+
+// MarshalJSON adds the ledger and available balances to the account's fields.
+// Hold and release transactions only move funds between the available and
+// held sub-accounts, so the ledger balance is Balance and the available
+// balance is what remains after the held funds.
+func (a Account) MarshalJSON() ([]byte, error) {
+	type account Account
+	return json.Marshal(struct {
+		account
+		Ledger    Amount `json:"ledger"`
+		Available Amount `json:"available"`
+	}{account(a), a.Balance, a.Available()})
 }
 
-// DepositRequest represents a deposit request
+// Accounts is a collection wrapper used for responses.
+type Accounts struct {
+	Data []Account `json:"data"`
+}
+
+// DepositRequest represents a deposit request. Amounts are in the account
+// currency's minor unit; Currency, when set, must match the account.
 type DepositRequest struct {
-	Type   PaymentType `json:"type"`
-	Amount float64     `json:"amount"`
+	CustomerID string      `json:"customer_id"`
+	Type       PaymentType `json:"type"`
+	Currency   string      `json:"currency,omitempty"`
+	Amount     Amount      `json:"amount"`
 }
 
 // DepositResponse represents a deposit response
@@ -144,8 +575,10 @@ type DepositResponse struct {
 
 // WithdrawRequest represents a withdrawal request
 type WithdrawRequest struct {
-	Type   PaymentType `json:"type"`
-	Amount float64     `json:"amount"`
+	CustomerID string      `json:"customer_id"`
+	Type       PaymentType `json:"type"`
+	Currency   string      `json:"currency,omitempty"`
+	Amount     Amount      `json:"amount"`
 }
 
 // WithdrawResponse represents a withdrawal response
@@ -158,8 +591,10 @@ type WithdrawResponse struct {
 
 // RefundRequest represents a refund request
 type RefundRequest struct {
+	CustomerID  string      `json:"customer_id"`
 	Type        PaymentType `json:"type"`
-	Amount      float64     `json:"amount"`
+	Currency    string      `json:"currency,omitempty"`
+	Amount      Amount      `json:"amount"`
 	ReferenceID string      `json:"reference_id"`
 }
 
@@ -171,18 +606,266 @@ type RefundResponse struct {
 	Account       Account `json:"account"`
 }
 
-// ProcessPaymentRequest represents a payment processing request
+// ProcessPaymentRequest represents a payment processing request. PaymentMethod
+// identifies the card (a test card number or pm_card_* token) for credit card payments.
 type ProcessPaymentRequest struct {
-	Type    PaymentType `json:"type"`
-	Amount  float64     `json:"amount"`
-	OrderID string      `json:"order_id"`
+	CustomerID    string      `json:"customer_id"`
+	Type          PaymentType `json:"type"`
+	Currency      string      `json:"currency,omitempty"`
+	Amount        Amount      `json:"amount"`
+	OrderID       string      `json:"order_id"`
+	PaymentMethod string      `json:"payment_method,omitempty"`
 }
 
-// ProcessPaymentResponse represents a payment processing response
+// ProcessPaymentResponse represents a payment processing response. Credit card
+// payments include the resulting charge and, when declined, the decline code.
 type ProcessPaymentResponse struct {
 	Success       bool    `json:"success"`
 	TransactionID string  `json:"transaction_id"`
 	Message       string  `json:"message"`
 	OrderID       string  `json:"order_id"`
 	Account       Account `json:"account"`
+	DeclineCode   string  `json:"decline_code,omitempty"`
+	Charge        *Charge `json:"charge,omitempty"`
+}
+
+// HoldStatus is a state in the Hold lifecycle. Only active holds reserve funds.
+type HoldStatus string
+
+const (
+	HoldStatusActive   HoldStatus = "active"
+	HoldStatusCaptured HoldStatus = "captured"
+	HoldStatusReleased HoldStatus = "released"
+	HoldStatusExpired  HoldStatus = "expired"
+)
+
+// HoldStatuses lists every Hold status.
+var HoldStatuses = []HoldStatus{
+	HoldStatusActive,
+	HoldStatusCaptured,
+	HoldStatusReleased,
+	HoldStatusExpired,
+}
+
+// Hold reserves funds on a customer's meowth-wallet or credit card account
+// until it is captured, released or expires at ExpiresAt. AmountCaptured is
+// what the capture debited; the rest of Amount was released. TransactionID is
+// the capture's ledger transaction.
+type Hold struct {
+	ID             string      `json:"id"`
+	Object         string      `json:"object"`
+	CustomerID     string      `json:"customer_id"`
+	Type           PaymentType `json:"type"`
+	Amount         Amount      `json:"amount"`
+	Currency       string      `json:"currency"`
+	Status         HoldStatus  `json:"status"`
+	AmountCaptured Amount      `json:"amount_captured"`
+	OrderID        string      `json:"order_id,omitempty"`
+	TransactionID  string      `json:"transaction_id,omitempty"`
+	ExpiresAt      int64       `json:"expires_at"`
+	Created        int64       `json:"created"`
+}
+
+// CreateHoldRequest reserves Amount on an account. PaymentMethod identifies
+// the card, e.g. a declining test card, of credit card holds.
+type CreateHoldRequest struct {
+	CustomerID    string      `json:"customer_id"`
+	Type          PaymentType `json:"type"`
+	Currency      string      `json:"currency,omitempty"`
+	Amount        Amount      `json:"amount"`
+	OrderID       string      `json:"order_id,omitempty"`
+	PaymentMethod string      `json:"payment_method,omitempty"`
+}
+
+// CreateHoldResponse returns the created hold and the account it reserves
+// funds on.
+type CreateHoldResponse struct {
+	Hold    Hold    `json:"hold"`
+	Account Account `json:"account"`
+}
+
+// RetrieveHoldResponse wraps a retrieved hold.
+type RetrieveHoldResponse struct {
+	Hold Hold `json:"hold"`
+}
+
+// CaptureHoldRequest optionally captures less than the held amount; the rest
+// is released. A nil AmountToCapture captures everything.
+type CaptureHoldRequest struct {
+	AmountToCapture *Amount `json:"amount_to_capture,omitempty"`
+}
+
+// CaptureHoldResponse returns the captured hold and the debited account.
+type CaptureHoldResponse struct {
+	Hold    Hold    `json:"hold"`
+	Account Account `json:"account"`
+}
+
+// ReleaseHoldResponse returns the released hold and its account.
+type ReleaseHoldResponse struct {
+	Hold    Hold    `json:"hold"`
+	Account Account `json:"account"`
+}
+
+// TransactionKind identifies the operation that produced a ledger transaction.
+type TransactionKind string
+
+const (
+	TransactionKindDeposit    TransactionKind = "deposit"
+	TransactionKindWithdrawal TransactionKind = "withdrawal"
+	TransactionKindRefund     TransactionKind = "refund"
+	TransactionKindPayment    TransactionKind = "payment"
+	// TransactionKindAdjustment records a balance set directly, e.g. by a
+	// test fixture, rather than by a customer operation.
+	TransactionKindAdjustment TransactionKind = "adjustment"
+	// TransactionKindHold reserves funds for a hold, and
+	// TransactionKindRelease frees them when the hold is captured, released
+	// or expires. Neither changes the ledger balance.
+	TransactionKindHold    TransactionKind = "hold"
+	TransactionKindRelease TransactionKind = "release"
+)
+
+// EntryDirection is the side of the ledger an entry is posted to.
+type EntryDirection string
+
+const (
+	EntryDirectionDebit  EntryDirection = "debit"
+	EntryDirectionCredit EntryDirection = "credit"
+)
+
+// LedgerEntry is one side of a double-entry transaction. Customer accounts are
+// named "customer:<id>:<type>"; their counterparts are "external:<type>" for
+// deposits and withdrawals and "merchant:<type>" for payments and refunds.
+// Holds and releases post to the sub-accounts "customer:<id>:<type>:available"
+// and "customer:<id>:<type>:held". BalanceAfter is only reported for customer
+// accounts and their available sub-accounts.
+type LedgerEntry struct {
+	Account      string         `json:"account"`
+	Direction    EntryDirection `json:"direction"`
+	Amount       Amount         `json:"amount"`
+	BalanceAfter *Amount        `json:"balance_after,omitempty"`
+}
+
+// Transaction is an immutable ledger record of a balance movement.
+type Transaction struct {
+	ID          string          `json:"id"`
+	Object      string          `json:"object"`
+	Kind        TransactionKind `json:"kind"`
+	CustomerID  string          `json:"customer_id"`
+	AccountType PaymentType     `json:"account_type"`
+	Amount      Amount          `json:"amount"`
+	Reference   string          `json:"reference,omitempty"`
+	OrderID     string          `json:"order_id,omitempty"`
+	Created     int64           `json:"created"`
+	Entries     []LedgerEntry   `json:"entries"`
+}
+
+// RetrieveTransactionResponse wraps a retrieved ledger transaction.
+type RetrieveTransactionResponse struct {
+	Transaction Transaction `json:"transaction"`
+}
+
+// List is a page of objects returned by list endpoints.
+type List[T any] struct {
+	Object  string `json:"object"`
+	Data    []T    `json:"data"`
+	HasMore bool   `json:"has_more"`
+	URL     string `json:"url"`
+}
+
+// SearchResult is a page of search results, newest first. NextPage, when set,
+// is passed as the page parameter to fetch the following page.
+type SearchResult[T any] struct {
+	Object   string `json:"object"`
+	Data     []T    `json:"data"`
+	HasMore  bool   `json:"has_more"`
+	NextPage string `json:"next_page,omitempty"`
+	URL      string `json:"url"`
+}
+
+// Snapshot is the full state of the mock datasets, exported by
+// /admin/snapshot and imported by /admin/restore. Every list is in creation
+// order. Webhook endpoints are configuration and are not part of a snapshot.
+type Snapshot struct {
+	Object         string          `json:"object"`
+	Customers      []Customer      `json:"customers"`
+	PaymentMethods []PaymentMethod `json:"payment_methods"`
+	PaymentIntents []PaymentIntent `json:"payment_intents"`
+	Charges        []Charge        `json:"charges"`
+	Refunds        []Refund        `json:"refunds"`
+	Holds          []Hold          `json:"holds"`
+	Accounts       []Account       `json:"accounts"`
+	Transactions   []Transaction   `json:"transactions"`
+}
+
+// Tenant is an isolated namespace of customers, payment intents, accounts and
+// webhook endpoints, selected by the API key of each request.
+type Tenant struct {
+	ID      string   `json:"id"`
+	Object  string   `json:"object"`
+	Name    string   `json:"name"`
+	APIKeys []string `json:"api_keys"`
+	Created int64    `json:"created"`
+}
+
+// CreateTenantRequest creates a tenant. A secret and a publishable test key
+// are generated when APIKeys is empty.
+type CreateTenantRequest struct {
+	Name    string   `json:"name"`
+	APIKeys []string `json:"api_keys,omitempty"`
+}
+
+// Tenants wraps a list of tenants.
+type Tenants struct {
+	Data []Tenant `json:"data"`
+}
+
+// FaultKind is the failure injected by a FaultRule.
+type FaultKind string
+
+const (
+	// FaultKindLatency delays the request and then serves it normally.
+	FaultKindLatency FaultKind = "latency"
+	// FaultKindError responds with Status without serving the request.
+	FaultKindError FaultKind = "error"
+	// FaultKindDrop serves the request, then closes the connection halfway
+	// through the response body.
+	FaultKindDrop FaultKind = "drop"
+	// FaultKindTimeout holds the connection open without serving the request
+	// and then closes it without a response.
+	FaultKindTimeout FaultKind = "timeout"
+)
+
+// Latency is a delay distribution in milliseconds. Distribution is "fixed"
+// (MeanMS), "uniform" (MinMS to MaxMS), "normal" (MeanMS and StdDevMS) or
+// "exponential" (MeanMS).
+type Latency struct {
+	Distribution string `json:"distribution"`
+	MinMS        int    `json:"min_ms,omitempty"`
+	MaxMS        int    `json:"max_ms,omitempty"`
+	MeanMS       int    `json:"mean_ms,omitempty"`
+	StdDevMS     int    `json:"stddev_ms,omitempty"`
+}
+
+// FaultRule injects a fault into a share of the requests it matches. Empty
+// match fields match every request; Route is an exact path or, ending in "*",
+// a path prefix. PaymentType matches the "type" field of wallet requests and
+// the payment type of /accounts paths. A nil Rate defaults to 1; a rate of 0
+// keeps the rule without firing it.
+type FaultRule struct {
+	ID          string      `json:"id"`
+	Object      string      `json:"object"`
+	Route       string      `json:"route,omitempty"`
+	Method      string      `json:"method,omitempty"`
+	PaymentType PaymentType `json:"payment_type,omitempty"`
+	Kind        FaultKind   `json:"kind"`
+	Rate        *float64    `json:"rate,omitempty"`
+	Status      int         `json:"status,omitempty"`
+	Latency     *Latency    `json:"latency,omitempty"`
+	TimeoutMS   int         `json:"timeout_ms,omitempty"`
+}
+
+// FaultRules wraps a list of fault rules.
+type FaultRules struct {
+	Data []FaultRule `json:"data"`
 }
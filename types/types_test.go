@@ -0,0 +1,25 @@
+package types
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestAccountJSONBalances(t *testing.T) {
+	raw, err := json.Marshal(Account{CustomerID: "cus_1", Type: PaymentTypeMeowthWallet, Balance: 1000, Held: 600, Currency: "thb"})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var got struct {
+		Balance   Amount `json:"balance"`
+		Held      Amount `json:"held"`
+		Ledger    Amount `json:"ledger"`
+		Available Amount `json:"available"`
+	}
+	if err := json.Unmarshal(raw, &got); err != nil {
+		t.Fatalf("unmarshal %s: %v", raw, err)
+	}
+	if got.Balance != 1000 || got.Held != 600 || got.Ledger != 1000 || got.Available != 400 {
+		t.Errorf("account JSON = %s, want ledger 1000 and available 400", raw)
+	}
+}
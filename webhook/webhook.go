@@ -0,0 +1,184 @@
+// Package webhook delivers mock payment events to registered webhook endpoints.
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nerdgarten/mock-payment-service/data"
+	"github.com/nerdgarten/mock-payment-service/types"
+)
+
+// SignatureHeader carries the delivery signature, formatted as
+// "t=<unix timestamp>,v1=<hex HMAC-SHA256 of "<timestamp>.<payload>">".
+const SignatureHeader = "Signature"
+
+// Default delivery settings used by NewDispatcher.
+const (
+	DefaultMaxAttempts    = 5
+	DefaultInitialBackoff = 500 * time.Millisecond
+	DefaultTimeout        = 5 * time.Second
+)
+
+// Dispatcher posts events to the webhook endpoints registered in a store,
+// retrying failed deliveries with exponential backoff.
+type Dispatcher struct {
+	store data.Store
+
+	// Client performs the deliveries.
+	Client *http.Client
+	// MaxAttempts bounds the number of deliveries per endpoint and event.
+	MaxAttempts int
+	// InitialBackoff is the wait before the first retry; it doubles on every
+	// subsequent retry.
+	InitialBackoff time.Duration
+
+	wg sync.WaitGroup
+}
+
+// NewDispatcher creates a Dispatcher for the endpoints registered in store.
+// Call Start to begin delivering the store's events.
+func NewDispatcher(store data.Store) *Dispatcher {
+	return &Dispatcher{
+		store:          store,
+		Client:         &http.Client{Timeout: DefaultTimeout},
+		MaxAttempts:    DefaultMaxAttempts,
+		InitialBackoff: DefaultInitialBackoff,
+	}
+}
+
+// Start subscribes the dispatcher to the store's events.
+func (d *Dispatcher) Start() {
+	d.store.Subscribe(d.Dispatch)
+}
+
+// Dispatch delivers event asynchronously to every endpoint enabled for its type.
+func (d *Dispatcher) Dispatch(event types.Event) {
+	var endpoints []types.WebhookEndpoint
+	_ = d.store.View(func(tx data.ReadTx) error {
+		for _, endpoint := range tx.WebhookEndpoints() {
+			if data.WebhookEndpointEnabled(endpoint, event.Type) {
+				endpoints = append(endpoints, *endpoint)
+			}
+		}
+		return nil
+	})
+	if len(endpoints) == 0 {
+		return
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("webhook: failed to encode event %s: %v", event.ID, err)
+		return
+	}
+	for _, endpoint := range endpoints {
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			d.deliver(endpoint, event, payload)
+		}()
+	}
+}
+
+// Wait blocks until all in-flight deliveries, including retries, have finished.
+func (d *Dispatcher) Wait() {
+	d.wg.Wait()
+}
+
+func (d *Dispatcher) deliver(endpoint types.WebhookEndpoint, event types.Event, payload []byte) {
+	backoff := d.InitialBackoff
+	for attempt := 1; attempt <= d.MaxAttempts; attempt++ {
+		err := d.post(endpoint, payload)
+		if err == nil {
+			log.Printf("webhook: delivered %s (%s) to %s on attempt %d", event.ID, event.Type, endpoint.URL, attempt)
+			return
+		}
+		log.Printf("webhook: attempt %d delivering %s to %s failed: %v", attempt, event.ID, endpoint.URL, err)
+		if attempt < d.MaxAttempts {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+	log.Printf("webhook: giving up on %s for %s after %d attempts", event.ID, endpoint.URL, d.MaxAttempts)
+}
+
+func (d *Dispatcher) post(endpoint types.WebhookEndpoint, payload []byte) error {
+	req, err := http.NewRequest(http.MethodPost, endpoint.URL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, SignatureHeaderValue(endpoint.Secret, time.Now().Unix(), payload))
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("endpoint responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Sign returns the hex HMAC-SHA256 of "<timestamp>.<payload>" keyed by secret.
+func Sign(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignatureHeaderValue formats the Signature header for a delivery.
+func SignatureHeaderValue(secret string, timestamp int64, payload []byte) string {
+	return fmt.Sprintf("t=%d,v1=%s", timestamp, Sign(secret, timestamp, payload))
+}
+
+// VerifySignature checks a Signature header against payload and secret.
+// A positive tolerance rejects timestamps older than the tolerance.
+func VerifySignature(header string, payload []byte, secret string, tolerance time.Duration) error {
+	var (
+		timestamp  int64
+		signatures []string
+	)
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "t":
+			ts, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid signature timestamp: %w", err)
+			}
+			timestamp = ts
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	if timestamp == 0 || len(signatures) == 0 {
+		return errors.New("signature header is missing a timestamp or v1 signature")
+	}
+	if tolerance > 0 && time.Since(time.Unix(timestamp, 0)) > tolerance {
+		return errors.New("signature timestamp is outside the tolerance")
+	}
+	expected := Sign(secret, timestamp, payload)
+	for _, signature := range signatures {
+		if hmac.Equal([]byte(signature), []byte(expected)) {
+			return nil
+		}
+	}
+	return errors.New("no signature matches the expected signature")
+}
@@ -0,0 +1,129 @@
+package webhook
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nerdgarten/mock-payment-service/data"
+)
+
+func TestVerifySignature(t *testing.T) {
+	payload := []byte(`{"id":"evt_1"}`)
+	now := time.Now().Unix()
+	header := SignatureHeaderValue("whsec_test", now, payload)
+	if err := VerifySignature(header, payload, "whsec_test", time.Minute); err != nil {
+		t.Fatalf("VerifySignature: %v", err)
+	}
+	// A rotated secret may send several v1 signatures.
+	rotated := header + ",v1=" + Sign("whsec_old", now, payload)
+	if err := VerifySignature(rotated, payload, "whsec_test", time.Minute); err != nil {
+		t.Errorf("VerifySignature with two signatures: %v", err)
+	}
+
+	old := time.Now().Add(-time.Hour).Unix()
+	tests := []struct {
+		name    string
+		header  string
+		payload []byte
+		secret  string
+	}{
+		{"tampered payload", header, []byte(`{"id":"evt_2"}`), "whsec_test"},
+		{"wrong secret", header, payload, "whsec_other"},
+		{"missing timestamp", "v1=" + Sign("whsec_test", now, payload), payload, "whsec_test"},
+		{"missing signature", "t=1", payload, "whsec_test"},
+		{"invalid timestamp", "t=abc,v1=00", payload, "whsec_test"},
+		{"outside tolerance", SignatureHeaderValue("whsec_test", old, payload), payload, "whsec_test"},
+	}
+	for _, tt := range tests {
+		if err := VerifySignature(tt.header, tt.payload, tt.secret, time.Minute); err == nil {
+			t.Errorf("%s: VerifySignature accepted %q", tt.name, tt.header)
+		}
+	}
+	if err := VerifySignature(SignatureHeaderValue("whsec_test", old, payload), payload, "whsec_test", 0); err != nil {
+		t.Errorf("VerifySignature without tolerance: %v", err)
+	}
+}
+
+func TestDispatcherRetriesSignedDeliveries(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		attempts int
+		verified []error
+	)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		attempts++
+		verified = append(verified, VerifySignature(r.Header.Get(SignatureHeader), payload, "whsec_test", time.Minute))
+		if attempts < 3 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer receiver.Close()
+
+	store := data.NewEmptyMemoryStore()
+	if _, err := data.CreateWebhookEndpoint(store, receiver.URL, []string{"test.event"}, "whsec_test"); err != nil {
+		t.Fatalf("CreateWebhookEndpoint: %v", err)
+	}
+	dispatcher := NewDispatcher(store)
+	dispatcher.InitialBackoff = time.Millisecond
+	dispatcher.Start()
+
+	if _, err := data.EmitTestEvent(store, "test.event", `{"ok":true}`); err != nil {
+		t.Fatalf("EmitTestEvent: %v", err)
+	}
+	// Endpoints not subscribed to an event type receive nothing.
+	if _, err := data.EmitTestEvent(store, "other.event", `{}`); err != nil {
+		t.Fatalf("EmitTestEvent: %v", err)
+	}
+	dispatcher.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if attempts != 3 {
+		t.Fatalf("attempts = %d, want 3", attempts)
+	}
+	for i, err := range verified {
+		if err != nil {
+			t.Errorf("attempt %d: %v", i+1, err)
+		}
+	}
+}
+
+func TestDispatcherGivesUpAfterMaxAttempts(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		attempts int
+	)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		attempts++
+		mu.Unlock()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer receiver.Close()
+
+	store := data.NewEmptyMemoryStore()
+	if _, err := data.CreateWebhookEndpoint(store, receiver.URL, []string{"*"}, "whsec_test"); err != nil {
+		t.Fatalf("CreateWebhookEndpoint: %v", err)
+	}
+	dispatcher := NewDispatcher(store)
+	dispatcher.MaxAttempts = 2
+	dispatcher.InitialBackoff = time.Millisecond
+	dispatcher.Start()
+	if _, err := data.EmitTestEvent(store, "test.event", `{}`); err != nil {
+		t.Fatalf("EmitTestEvent: %v", err)
+	}
+	dispatcher.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if attempts != 2 {
+		t.Errorf("attempts = %d, want 2", attempts)
+	}
+}